@@ -0,0 +1,321 @@
+package automate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/watch"
+)
+
+const (
+	seedTimeout   = 5 * time.Second
+	actionTimeout = 30 * time.Second
+)
+
+type Config struct {
+	Rules     *Rules
+	Inventory *inventory.Inventory
+	// DryRun logs the actions instead of executing them
+	DryRun bool
+	// Log is called for each action; executed, failed or dry run
+	Log func(*Record) error
+	// Errorf is called with connection errors
+	Errorf func(format string, args ...any)
+}
+
+// Record is the log record of an action
+type Record struct {
+	Time    string  `json:"time"`
+	Rule    string  `json:"rule"`
+	Trigger string  `json:"trigger"`
+	Action  *Action `json:"action"`
+	DryRun  bool    `json:"dry_run,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Engine matches the notifications of the trigger devices against the rules
+// and runs the actions
+type Engine struct {
+	config   *Config
+	executor *executor
+	// values are the last values of the field triggers by device, component
+	// and field
+	values map[string]any
+	fired  map[string]time.Time
+	// mutex guards values and fired, which are updated by the notifications
+	// and by the seeds that run in the background
+	mutex sync.Mutex
+	wg    sync.WaitGroup
+}
+
+func New(config *Config) *Engine {
+
+	if config.Errorf == nil {
+		config.Errorf = func(string, ...any) {}
+	}
+
+	return &Engine{
+		config:   config,
+		executor: newExecutor(config.Inventory),
+		values:   make(map[string]any),
+		fired:    make(map[string]time.Time),
+	}
+}
+
+// Run watches the trigger devices until ctx is done. Actions that are running
+// when ctx is done are waited for.
+func (t *Engine) Run(ctx context.Context) error {
+
+	devices, err := t.config.Inventory.Select(t.config.Rules.Devices())
+	if err != nil {
+		return err
+	}
+
+	// Actions and seeds started by the notifications are waited for
+	defer t.wg.Wait()
+
+	return watch.Run(ctx, &watch.Config{Devices: devices}, func(event *watch.Event) error {
+		t.handle(ctx, event)
+		return nil
+	})
+}
+
+func (t *Engine) handle(ctx context.Context, event *watch.Event) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch event.Method {
+
+	case watch.MethodConnected:
+		// The values from before the connection may be stale. The seed runs in
+		// the background so that a slow device does not hold up the
+		// notifications of the other devices.
+		t.forget(event.Device)
+		t.wg.Add(1)
+		go t.seed(ctx, event.Device)
+
+	case watch.MethodDisconnected:
+		t.config.Errorf("device %s: %s", event.Device, event.Error)
+
+	case "NotifyEvent":
+		params := &struct {
+			Events []struct {
+				Component string `json:"component"`
+				Event     string `json:"event"`
+			} `json:"events"`
+		}{}
+		if json.Unmarshal(event.Params, params) != nil {
+			return
+		}
+		for _, e := range params.Events {
+			for _, rule := range t.config.Rules.Rules {
+				trigger := rule.Trigger
+				if trigger.Event == e.Event && strings.EqualFold(trigger.Device, event.Device) &&
+					(trigger.Component == "" || trigger.Component == e.Component) {
+					t.fire(rule, fmt.Sprintf("%s %s %s", event.Device, e.Component, e.Event))
+				}
+			}
+		}
+
+	case "NotifyStatus", "NotifyFullStatus":
+		var params map[string]any
+		if json.Unmarshal(event.Params, &params) != nil {
+			return
+		}
+		t.update(event.Device, params, true)
+	}
+}
+
+// seed sets the last values from the full status so that the first
+// notification of a field can be compared. Values that a notification set in
+// the meantime are kept.
+func (t *Engine) seed(ctx context.Context, name string) {
+
+	defer t.wg.Done()
+
+	d := t.config.Inventory.Get(name)
+	if d == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, seedTimeout)
+	defer cancel()
+
+	var status map[string]any
+
+	err := d.Client().Call(ctx, "Shelly.GetStatus", nil, &status)
+	if err != nil {
+		t.config.Errorf("device %s: unable to get status: %s", name, err)
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(name, status, false)
+}
+
+// forget removes the last values of device
+func (t *Engine) forget(device string) {
+	prefix := strings.ToLower(device) + "/"
+	for key := range t.values {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.values, key)
+		}
+	}
+}
+
+// update compares the component status values with the last values and fires
+// the matching field triggers if fire is set. If fire is not set only unknown
+// values are set.
+func (t *Engine) update(device string, status map[string]any, fire bool) {
+
+	// Values are set after all rules so that rules on the same field compare
+	// with the same previous value
+	values := make(map[string]any)
+
+	for _, rule := range t.config.Rules.Rules {
+
+		trigger := rule.Trigger
+
+		if trigger.Field == "" || !strings.EqualFold(trigger.Device, device) {
+			continue
+		}
+
+		component, ok := status[trigger.Component].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		value, ok := lookup(component, trigger.Field)
+		if !ok {
+			continue
+		}
+
+		key := strings.ToLower(device) + "/" + trigger.Component + "/" + trigger.Field
+		values[key] = value
+
+		prev, known := t.values[key]
+
+		if fire && known && trigger.matches(prev, value) {
+			t.fire(rule, fmt.Sprintf("%s %s %s %v -> %v", device, trigger.Component, trigger.Field, prev, value))
+		}
+	}
+
+	for key, value := range values {
+		if _, known := t.values[key]; known && !fire {
+			continue
+		}
+		t.values[key] = value
+	}
+}
+
+// matches returns true if the change from prev to value fires the trigger
+func (t *Trigger) matches(prev, value any) bool {
+
+	if t.Equals != nil {
+		return !equal(prev, t.Equals) && equal(value, t.Equals)
+	}
+
+	if t.Above == nil && t.Below == nil {
+		return !reflect.DeepEqual(prev, value)
+	}
+
+	p, ok1 := prev.(float64)
+	v, ok2 := value.(float64)
+	if !ok1 || !ok2 {
+		return false
+	}
+
+	if t.Above != nil && p <= *t.Above && v > *t.Above {
+		return true
+	}
+
+	return t.Below != nil && p >= *t.Below && v < *t.Below
+}
+
+// fire runs the actions of rule unless a condition does not hold or the rule
+// fired within its debounce duration. Actions run in the background in order.
+func (t *Engine) fire(rule *Rule, trigger string) {
+
+	now := time.Now()
+
+	if !rule.When.holds(now) {
+		return
+	}
+
+	if last, ok := t.fired[rule.Name]; ok && now.Sub(last) < rule.debounce {
+		return
+	}
+
+	t.fired[rule.Name] = now
+
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		for _, action := range rule.Actions {
+
+			record := &Record{
+				Time:    time.Now().Format(time.RFC3339),
+				Rule:    rule.Name,
+				Trigger: trigger,
+				Action:  action,
+				DryRun:  t.config.DryRun,
+			}
+
+			if !t.config.DryRun {
+				// Actions are completed even if the engine is stopped
+				ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+				err := t.executor.run(ctx, action)
+				cancel()
+				if err != nil {
+					record.Error = err.Error()
+				}
+			}
+
+			if t.config.Log(record) != nil || record.Error != "" {
+				return
+			}
+		}
+	}()
+}
+
+// lookup returns the value of the dot separated field path
+func lookup(status map[string]any, field string) (any, bool) {
+
+	var value any = status
+
+	for _, key := range strings.Split(field, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// equal compares a status value with a rules file value. Numbers are compared
+// as float64.
+func equal(value, expected any) bool {
+
+	switch v := expected.(type) {
+	case int:
+		expected = float64(v)
+	}
+
+	return reflect.DeepEqual(value, expected)
+}
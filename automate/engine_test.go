@@ -0,0 +1,186 @@
+package automate
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/watch"
+)
+
+func TestTriggerMatches(t *testing.T) {
+
+	above := 100.0
+	below := 10.0
+
+	tests := []struct {
+		name    string
+		trigger *Trigger
+		prev    any
+		value   any
+		want    bool
+	}{
+		{name: "any change", trigger: &Trigger{}, prev: false, value: true, want: true},
+		{name: "no change", trigger: &Trigger{}, prev: 1.0, value: 1.0, want: false},
+		{name: "equals", trigger: &Trigger{Equals: true}, prev: false, value: true, want: true},
+		{name: "already equal", trigger: &Trigger{Equals: true}, prev: true, value: true, want: false},
+		{name: "equals int", trigger: &Trigger{Equals: 2}, prev: 1.0, value: 2.0, want: true},
+		{name: "crosses above", trigger: &Trigger{Above: &above}, prev: 100.0, value: 100.5, want: true},
+		{name: "stays above", trigger: &Trigger{Above: &above}, prev: 101.0, value: 150.0, want: false},
+		{name: "crosses below", trigger: &Trigger{Below: &below}, prev: 10.0, value: 9.0, want: true},
+		{name: "rises below", trigger: &Trigger{Below: &below}, prev: 5.0, value: 9.0, want: false},
+		{name: "band", trigger: &Trigger{Above: &above, Below: &below}, prev: 50.0, value: 5.0, want: true},
+		{name: "not a number", trigger: &Trigger{Above: &above}, prev: "50", value: 150.0, want: false},
+	}
+
+	for _, test := range tests {
+		if got := test.trigger.matches(test.prev, test.value); got != test.want {
+			t.Errorf("%s: matches(%v, %v) = %v; want %v", test.name, test.prev, test.value, got, test.want)
+		}
+	}
+}
+
+func TestConditionHolds(t *testing.T) {
+
+	// 2024-01-01 is a Monday
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name      string
+		condition *Condition
+		now       time.Time
+		want      bool
+	}{
+		{name: "nil", condition: nil, now: at(3, 0), want: true},
+		{name: "window", condition: &Condition{After: "08:00", Before: "18:00"}, now: at(8, 0), want: true},
+		{name: "window end", condition: &Condition{After: "08:00", Before: "18:00"}, now: at(18, 0), want: false},
+		{name: "midnight", condition: &Condition{After: "22:00", Before: "06:00"}, now: at(23, 30), want: true},
+		{name: "after midnight", condition: &Condition{After: "22:00", Before: "06:00"}, now: at(5, 59), want: true},
+		{name: "outside midnight", condition: &Condition{After: "22:00", Before: "06:00"}, now: at(12, 0), want: false},
+		{name: "after only", condition: &Condition{After: "20:00"}, now: at(19, 59), want: false},
+		{name: "before only", condition: &Condition{Before: "07:00"}, now: at(6, 0), want: true},
+		{name: "day", condition: &Condition{Days: []string{"Mon", "tue"}}, now: at(12, 0), want: true},
+		{name: "other day", condition: &Condition{Days: []string{"sat", "sun"}}, now: at(12, 0), want: false},
+	}
+
+	for _, test := range tests {
+
+		if test.condition != nil {
+			if errs := test.condition.validate(); len(errs) > 0 {
+				t.Fatalf("%s: %v", test.name, errs)
+			}
+		}
+
+		if got := test.condition.holds(test.now); got != test.want {
+			t.Errorf("%s: holds = %v; want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	inv := &inventory.Inventory{Devices: []*inventory.Device{
+		{Name: "plug", Hostname: "10.0.0.2"},
+		{Name: "relay", Hostname: "10.0.0.3", Gen: 1},
+	}}
+
+	action := []*Action{{Switch: &OutputAction{Device: "relay", Action: "on"}}}
+
+	valid := &Rules{Rules: []*Rule{
+		{Name: "a", Trigger: &Trigger{Device: "plug", Event: "single_push"}, Actions: action},
+	}}
+
+	if err := valid.Validate(inv); err != nil {
+		t.Errorf("error = %v", err)
+	}
+
+	tests := map[string]*Rule{
+		"Gen1 device":              {Name: "a", Trigger: &Trigger{Device: "relay", Event: "single_push"}, Actions: action},
+		"not found in inventory":   {Name: "a", Trigger: &Trigger{Device: "x", Event: "single_push"}, Actions: action},
+		"mutually exclusive":       {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x", Field: "y"}, Actions: action},
+		"component is required":    {Name: "a", Trigger: &Trigger{Device: "plug", Field: "apower"}, Actions: action},
+		"actions are required":     {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}},
+		"debounce 1 is invalid":    {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, Debounce: "1", Actions: action},
+		"when after 25:00":         {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, When: &Condition{After: "25:00"}, Actions: action},
+		"expect exactly one":       {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, Actions: []*Action{{}}},
+		"action must be on, off":   {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, Actions: []*Action{{Light: &OutputAction{Device: "plug", Action: "up"}}}},
+		"expect Component.Method":  {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, Actions: []*Action{{RPC: &RPCAction{Device: "plug", Method: "x"}}}},
+		"expect http(s)://host/..": {Name: "a", Trigger: &Trigger{Device: "plug", Event: "x"}, Actions: []*Action{{Webhook: &WebhookAction{URL: "ftp://x"}}}},
+	}
+
+	for want, rule := range tests {
+		err := (&Rules{Rules: []*Rule{rule}}).Validate(inv)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v; want %q", err, want)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+
+	release := make(chan struct{})
+
+	device := testdevice.New(t, &testdevice.Config{
+		RPC:  testdevice.Results(map[string]string{"Shelly.GetStatus": `{"switch:0":{"id":0,"apower":5}}`}),
+		Hold: release,
+	})
+
+	above := 100.0
+
+	engine := New(&Config{
+		Rules: &Rules{Rules: []*Rule{{
+			Name:    "high",
+			Trigger: &Trigger{Device: "plug", Component: "switch:0", Field: "apower", Above: &above},
+			Actions: []*Action{{Webhook: &WebhookAction{URL: "http://127.0.0.1:1/"}}},
+		}}},
+		Inventory: &inventory.Inventory{Devices: []*inventory.Device{
+			device.Inventory("plug"),
+		}},
+		DryRun: true,
+		Log:    func(*Record) error { return nil },
+	})
+
+	status := func(apower float64) *watch.Event {
+		params, _ := json.Marshal(map[string]any{"switch:0": map[string]any{"apower": apower}})
+		return &watch.Event{Device: "plug", Method: "NotifyStatus", Params: params}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		engine.handle(context.Background(), &watch.Event{Device: "plug", Method: watch.MethodConnected})
+		// The first notification has no previous value to compare with
+		engine.handle(context.Background(), status(50))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the seed blocked the notifications")
+	}
+
+	close(release)
+	engine.wg.Wait()
+
+	engine.mutex.Lock()
+	value := engine.values["plug/switch:0/apower"]
+	engine.mutex.Unlock()
+
+	if value != 50.0 {
+		t.Errorf("apower = %v; want the notified 50, not the seeded 5", value)
+	}
+
+	engine.handle(context.Background(), status(150))
+	engine.wg.Wait()
+
+	if _, ok := engine.fired["high"]; !ok {
+		t.Errorf("rule high did not fire")
+	}
+}
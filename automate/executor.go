@@ -0,0 +1,158 @@
+package automate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+// executor runs actions. The devices are created once so that the generation
+// is detected once.
+type executor struct {
+	inventory  *inventory.Inventory
+	httpClient *http.Client
+	mutex      sync.Mutex
+	devices    map[string]*deviceEntry
+}
+
+// deviceEntry caches the device of an inventory entry. Its mutex is held while
+// the generation is detected so that a slow device only holds up its own
+// actions.
+type deviceEntry struct {
+	mutex  sync.Mutex
+	device device.Device
+}
+
+func newExecutor(inv *inventory.Inventory) *executor {
+	return &executor{
+		inventory:  inv,
+		httpClient: &http.Client{},
+		devices:    make(map[string]*deviceEntry),
+	}
+}
+
+func (t *executor) run(ctx context.Context, action *Action) error {
+
+	switch {
+
+	case action.Switch != nil:
+		return t.output(ctx, "switch", action.Switch)
+
+	case action.Light != nil:
+		return t.output(ctx, "light", action.Light)
+
+	case action.RPC != nil:
+		d, err := t.inventoryDevice(action.RPC.Device)
+		if err != nil {
+			return err
+		}
+		return d.Client().Call(ctx, action.RPC.Method, action.RPC.Params, nil)
+
+	case action.Webhook != nil:
+		return t.webhook(ctx, action.Webhook)
+	}
+
+	return fmt.Errorf("action is empty")
+}
+
+func (t *executor) output(ctx context.Context, component string, action *OutputAction) error {
+
+	d, err := t.device(ctx, action.Device)
+	if err != nil {
+		return err
+	}
+
+	if action.Brightness == nil || action.Action != "on" {
+		_, err = d.Set(ctx, component, action.ID, device.Action(action.Action))
+		return err
+	}
+
+	_, err = d.SetBrightness(ctx, action.ID, *action.Brightness)
+	return err
+}
+
+func (t *executor) webhook(ctx context.Context, action *WebhookAction) error {
+
+	method := strings.ToUpper(action.Method)
+	if method == "" {
+		method = http.MethodGet
+		if action.Body != "" {
+			method = http.MethodPost
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, action.URL, strings.NewReader(action.Body))
+	if err != nil {
+		return err
+	}
+
+	if action.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned HTTP %d", action.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// device returns the device with name. A failed detection is retried with the
+// next action.
+func (t *executor) device(ctx context.Context, name string) (device.Device, error) {
+
+	inv, err := t.inventoryDevice(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	entry, ok := t.devices[name]
+	if !ok {
+		entry = &deviceEntry{}
+		t.devices[name] = entry
+	}
+	t.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if entry.device != nil {
+		return entry.device, nil
+	}
+
+	d, err := device.New(ctx, &device.Config{
+		Hostname: inv.Hostname,
+		Username: inv.Username,
+		Password: inv.Password,
+		Gen:      inv.Gen,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry.device = d
+	return d, nil
+}
+
+func (t *executor) inventoryDevice(name string) (*inventory.Device, error) {
+	d := t.inventory.Get(name)
+	if d == nil {
+		return nil, fmt.Errorf("device %s not found in inventory", name)
+	}
+	return d, nil
+}
@@ -0,0 +1,90 @@
+package automate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+func TestExecutorBrightness(t *testing.T) {
+
+	plus := testdevice.New(t, &testdevice.Config{RPC: testdevice.Results(map[string]string{
+		"Light.Set":       `null`,
+		"Light.GetStatus": `{"id":0,"output":true,"brightness":40}`,
+		"Light.GetConfig": `{"id":0,"name":"Hall"}`,
+	})})
+
+	gen1 := testdevice.New(t, &testdevice.Config{Shelly: testdevice.Gen1, Paths: map[string]string{
+		"/light/0":          `{"ison":true,"brightness":40}`,
+		"/settings/light/0": `{"name":"Hall"}`,
+	}})
+
+	executor := newExecutor(&inventory.Inventory{Devices: []*inventory.Device{
+		plus.Inventory("plus"),
+		gen1.Inventory("gen1"),
+	}})
+
+	brightness := 40
+
+	for _, name := range []string{"plus", "gen1"} {
+		err := executor.run(context.Background(), &Action{Light: &OutputAction{Device: name, Action: "on", Brightness: &brightness}})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	calls := plus.Calls()
+
+	if len(calls) == 0 || calls[0].Method != "Light.Set" || calls[0].Params["brightness"] != 40.0 || calls[0].Params["on"] != true {
+		t.Errorf("calls = %v; want Light.Set on at brightness 40 first", plus.Methods())
+	}
+
+	if len(executor.devices) != 2 {
+		t.Errorf("cached %d devices; want 2", len(executor.devices))
+	}
+}
+
+func TestExecutorDevice(t *testing.T) {
+
+	release := make(chan struct{})
+
+	slow := testdevice.New(t, &testdevice.Config{Hold: release})
+	fast := testdevice.New(t, &testdevice.Config{})
+
+	executor := newExecutor(&inventory.Inventory{Devices: []*inventory.Device{
+		slow.Inventory("slow"),
+		fast.Inventory("fast"),
+	}})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		executor.device(context.Background(), "slow")
+	}()
+
+	// Wait until the detection of the slow device holds its entry
+	for {
+		executor.mutex.Lock()
+		_, ok := executor.devices["slow"]
+		executor.mutex.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := executor.device(ctx, "fast")
+	if err != nil {
+		t.Errorf("the detection of a slow device blocked another device: %v", err)
+	}
+
+	close(release)
+	<-done
+}
@@ -0,0 +1,325 @@
+package automate
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+// Rules is the rules file
+type Rules struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule runs its actions when the trigger matches a device notification, the
+// conditions hold and the rule has not fired within the debounce duration
+type Rule struct {
+	Name     string     `json:"name" yaml:"name"`
+	Trigger  *Trigger   `json:"trigger" yaml:"trigger"`
+	When     *Condition `json:"when,omitempty" yaml:"when,omitempty"`
+	Debounce string     `json:"debounce,omitempty" yaml:"debounce,omitempty"`
+	Actions  []*Action  `json:"actions" yaml:"actions"`
+
+	debounce time.Duration
+}
+
+// Trigger matches an event (NotifyEvent) such as single_push or a change of a
+// status field (NotifyStatus) such as apower of switch:0. A field trigger
+// fires when the value crosses above or below, becomes equal to equals or, if
+// none of them are set, on any change.
+type Trigger struct {
+	Device    string   `json:"device" yaml:"device"`
+	Component string   `json:"component,omitempty" yaml:"component,omitempty"`
+	Event     string   `json:"event,omitempty" yaml:"event,omitempty"`
+	Field     string   `json:"field,omitempty" yaml:"field,omitempty"`
+	Above     *float64 `json:"above,omitempty" yaml:"above,omitempty"`
+	Below     *float64 `json:"below,omitempty" yaml:"below,omitempty"`
+	Equals    any      `json:"equals,omitempty" yaml:"equals,omitempty"`
+}
+
+// Condition limits a rule to a time window (HH:MM, local time; the window may
+// span midnight) and to days of the week (mon, tue, ...)
+type Condition struct {
+	After  string   `json:"after,omitempty" yaml:"after,omitempty"`
+	Before string   `json:"before,omitempty" yaml:"before,omitempty"`
+	Days   []string `json:"days,omitempty" yaml:"days,omitempty"`
+
+	after  *int
+	before *int
+	days   map[time.Weekday]bool
+}
+
+// Action is exactly one of switch, light, rpc or webhook
+type Action struct {
+	Switch  *OutputAction  `json:"switch,omitempty" yaml:"switch,omitempty"`
+	Light   *OutputAction  `json:"light,omitempty" yaml:"light,omitempty"`
+	RPC     *RPCAction     `json:"rpc,omitempty" yaml:"rpc,omitempty"`
+	Webhook *WebhookAction `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+}
+
+// OutputAction turns a switch or light of a Gen1 or Plus device on, off or
+// toggles it. Brightness applies to lights turned on.
+type OutputAction struct {
+	Device     string `json:"device" yaml:"device"`
+	ID         int    `json:"id" yaml:"id"`
+	Action     string `json:"action" yaml:"action"`
+	Brightness *int   `json:"brightness,omitempty" yaml:"brightness,omitempty"`
+}
+
+// RPCAction calls method on a Plus device
+type RPCAction struct {
+	Device string         `json:"device" yaml:"device"`
+	Method string         `json:"method" yaml:"method"`
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// WebhookAction sends an HTTP request. Method defaults to GET, or POST if body
+// is set.
+type WebhookAction struct {
+	URL    string `json:"url" yaml:"url"`
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	Body   string `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Validate validates the rules against the inventory and returns all errors
+func (t *Rules) Validate(inv *inventory.Inventory) error {
+
+	var errors *multierror.Error
+
+	if len(t.Rules) == 0 {
+		return fmt.Errorf("rules file has no rules")
+	}
+
+	names := make(map[string]bool)
+
+	for i, rule := range t.Rules {
+
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			errors = multierror.Append(errors, fmt.Errorf("rule %s: name is required", name))
+		} else if names[name] {
+			errors = multierror.Append(errors, fmt.Errorf("rule %s: name is not unique", name))
+		}
+
+		names[name] = true
+
+		for _, err := range rule.validate(inv) {
+			errors = multierror.Append(errors, fmt.Errorf("rule %s: %w", name, err))
+		}
+	}
+
+	return errors.ErrorOrNil()
+}
+
+func (t *Rule) validate(inv *inventory.Inventory) []error {
+
+	var errs []error
+
+	requireDevice := func(name string) {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("device is required"))
+			return
+		}
+		if inv.Get(name) == nil {
+			errs = append(errs, fmt.Errorf("device %s not found in inventory", name))
+		}
+	}
+
+	if t.Trigger == nil {
+		errs = append(errs, fmt.Errorf("trigger is required"))
+	} else {
+		requireDevice(t.Trigger.Device)
+		if d := inv.Get(t.Trigger.Device); d != nil && d.Gen == 1 {
+			errs = append(errs, fmt.Errorf("trigger device %s is a Gen1 device; triggers require websocket notifications of a Plus device", d.Name))
+		}
+		errs = append(errs, t.Trigger.validate()...)
+	}
+
+	if t.When != nil {
+		errs = append(errs, t.When.validate()...)
+	}
+
+	if t.Debounce != "" {
+		d, err := time.ParseDuration(t.Debounce)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("debounce %s is invalid; expect a duration such as 30s", t.Debounce))
+		}
+		t.debounce = d
+	}
+
+	if len(t.Actions) == 0 {
+		errs = append(errs, fmt.Errorf("actions are required"))
+	}
+
+	for i, action := range t.Actions {
+
+		count := 0
+
+		for _, output := range []*OutputAction{action.Switch, action.Light} {
+			if output == nil {
+				continue
+			}
+			count++
+			requireDevice(output.Device)
+			switch output.Action {
+			case "on", "off", "toggle":
+			default:
+				errs = append(errs, fmt.Errorf("action %d: action must be on, off or toggle", i+1))
+			}
+			if output.ID < 0 {
+				errs = append(errs, fmt.Errorf("action %d: id must not be negative", i+1))
+			}
+			if output.Brightness != nil && (output == action.Switch || *output.Brightness < 1 || *output.Brightness > 100) {
+				errs = append(errs, fmt.Errorf("action %d: brightness must be 1 to 100 and only applies to lights", i+1))
+			}
+		}
+
+		if action.RPC != nil {
+			count++
+			requireDevice(action.RPC.Device)
+			if !strings.Contains(action.RPC.Method, ".") {
+				errs = append(errs, fmt.Errorf("action %d: method %q is invalid; expect Component.Method", i+1, action.RPC.Method))
+			}
+		}
+
+		if action.Webhook != nil {
+			count++
+			u, err := url.Parse(action.Webhook.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("action %d: url %q is invalid; expect http(s)://host/...", i+1, action.Webhook.URL))
+			}
+		}
+
+		if count != 1 {
+			errs = append(errs, fmt.Errorf("action %d: expect exactly one of switch, light, rpc or webhook", i+1))
+		}
+	}
+
+	return errs
+}
+
+func (t *Trigger) validate() []error {
+
+	var errs []error
+
+	switch {
+
+	case t.Event != "" && t.Field != "":
+		errs = append(errs, fmt.Errorf("trigger event and field are mutually exclusive"))
+
+	case t.Event == "" && t.Field == "":
+		errs = append(errs, fmt.Errorf("trigger event or field is required"))
+
+	case t.Event != "":
+		if t.Above != nil || t.Below != nil || t.Equals != nil {
+			errs = append(errs, fmt.Errorf("trigger above, below and equals apply to field triggers"))
+		}
+
+	default:
+		if t.Component == "" {
+			errs = append(errs, fmt.Errorf("trigger component is required for field triggers"))
+		}
+		if t.Equals != nil && (t.Above != nil || t.Below != nil) {
+			errs = append(errs, fmt.Errorf("trigger equals and above / below are mutually exclusive"))
+		}
+		if t.Above != nil && t.Below != nil && *t.Below > *t.Above {
+			errs = append(errs, fmt.Errorf("trigger below must not be greater than above"))
+		}
+	}
+
+	return errs
+}
+
+func (t *Condition) validate() []error {
+
+	var errs []error
+
+	parse := func(name, s string) *int {
+		if s == "" {
+			return nil
+		}
+		v, err := time.Parse("15:04", s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("when %s %s is invalid; expect HH:MM", name, s))
+			return nil
+		}
+		minutes := v.Hour()*60 + v.Minute()
+		return &minutes
+	}
+
+	t.after = parse("after", t.After)
+	t.before = parse("before", t.Before)
+
+	if len(t.Days) > 0 {
+		t.days = make(map[time.Weekday]bool)
+		for _, day := range t.Days {
+			weekday, ok := weekdays[strings.ToLower(day)]
+			if !ok {
+				errs = append(errs, fmt.Errorf("when day %s is invalid; expect mon, tue, wed, thu, fri, sat or sun", day))
+				continue
+			}
+			t.days[weekday] = true
+		}
+	}
+
+	return errs
+}
+
+// holds returns true if now is within the time window and days
+func (t *Condition) holds(now time.Time) bool {
+
+	if t == nil {
+		return true
+	}
+
+	if t.days != nil && !t.days[now.Weekday()] {
+		return false
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+
+	switch {
+
+	case t.after != nil && t.before != nil:
+		if *t.after <= *t.before {
+			return minutes >= *t.after && minutes < *t.before
+		}
+		// The window spans midnight
+		return minutes >= *t.after || minutes < *t.before
+
+	case t.after != nil:
+		return minutes >= *t.after
+
+	case t.before != nil:
+		return minutes < *t.before
+	}
+
+	return true
+}
+
+// Devices returns the names of the trigger devices
+func (t *Rules) Devices() []string {
+
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, rule := range t.Rules {
+		if rule.Trigger != nil && !seen[rule.Trigger.Device] {
+			seen[rule.Trigger.Device] = true
+			names = append(names, rule.Trigger.Device)
+		}
+	}
+
+	return names
+}
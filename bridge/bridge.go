@@ -0,0 +1,555 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+	"github.com/jodydadescott/shelly-go-cli/watch"
+)
+
+const (
+	defaultPrefix          = "shelly"
+	defaultDiscoveryPrefix = "homeassistant"
+	defaultInterval        = 30 * time.Second
+	defaultTimeout         = 10 * time.Second
+
+	bridgeName = "bridge"
+	online     = "online"
+	offline    = "offline"
+	qos        = 1
+)
+
+// Config configures the bridge. The topics are relative to Prefix:
+//
+//	bridge/availability              online or offline (retained, last will)
+//	<device>/availability            online or offline (retained)
+//	<device>/status                  normalised device status (retained)
+//	<device>/<component>/<id>        normalised output status (retained)
+//	<device>/<component>/<id>/set    command: on, off or toggle
+//	<device>/rpc                     command: {"id", "method", "params"}
+//	<device>/rpc/response            RPC result or error
+//	<device>/events                  NotifyEvent params (stream only)
+type Config struct {
+	Broker   string
+	Username string
+	Password string
+	ClientID string
+	Devices  []*inventory.Device
+	// Prefix is the topic prefix; defaults to shelly
+	Prefix string
+	// Interval is the status poll interval
+	Interval time.Duration
+	// Timeout is the timeout of device requests
+	Timeout time.Duration
+	// Stream refreshes the status of Plus devices on their notifications and
+	// publishes their events
+	Stream bool
+	// Discovery publishes Home Assistant MQTT discovery payloads under
+	// DiscoveryPrefix; defaults to homeassistant
+	Discovery       bool
+	DiscoveryPrefix string
+	Errorf          func(format string, args ...any)
+}
+
+// Bridge publishes the status of devices to an MQTT broker and translates
+// command topics to device requests
+type Bridge struct {
+	config  *Config
+	client  paho.Client
+	devices map[string]*deviceState
+}
+
+type deviceState struct {
+	inventory *inventory.Device
+	// refresh requests a status poll; the capacity of one coalesces requests
+	refresh chan struct{}
+
+	mutex      sync.Mutex
+	device     device.Device
+	available  *bool
+	discovered bool
+}
+
+func New(config *Config) *Bridge {
+
+	if config.Prefix == "" {
+		config.Prefix = defaultPrefix
+	}
+
+	if config.DiscoveryPrefix == "" {
+		config.DiscoveryPrefix = defaultDiscoveryPrefix
+	}
+
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.ClientID == "" {
+		config.ClientID = fmt.Sprintf("shelly-cli-bridge-%d", time.Now().UnixNano())
+	}
+
+	if config.Errorf == nil {
+		config.Errorf = func(string, ...any) {}
+	}
+
+	devices := make(map[string]*deviceState)
+	for _, d := range config.Devices {
+		devices[strings.ToLower(d.Name)] = &deviceState{
+			inventory: d,
+			refresh:   make(chan struct{}, 1),
+		}
+	}
+
+	return &Bridge{
+		config:  config,
+		devices: devices,
+	}
+}
+
+// Run connects to the broker and bridges the devices until ctx is done. The
+// connection to the broker is retried until it succeeds and reestablished if
+// lost.
+func (t *Bridge) Run(ctx context.Context) error {
+
+	for _, d := range t.config.Devices {
+		if strings.ContainsAny(d.Name, "/+#") || strings.EqualFold(d.Name, bridgeName) {
+			return fmt.Errorf("device name %s can not be used in topics; it must not contain /, + or # and must not be %s", d.Name, bridgeName)
+		}
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(t.config.Broker).
+		SetClientID(t.config.ClientID).
+		SetUsername(t.config.Username).
+		SetPassword(t.config.Password).
+		SetWill(t.topic(bridgeName, "availability"), offline, qos, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOrderMatters(false).
+		SetOnConnectHandler(t.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			t.config.Errorf("connection to broker %s lost: %s", t.config.Broker, err)
+		})
+
+	t.client = paho.NewClient(options)
+
+	token := t.client.Connect()
+
+	select {
+	case <-token.Done():
+		if token.Error() != nil {
+			return fmt.Errorf("connection to broker %s failed: %w", t.config.Broker, token.Error())
+		}
+	case <-ctx.Done():
+		t.client.Disconnect(0)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+
+	for _, state := range t.devices {
+		wg.Add(1)
+		go func(state *deviceState) {
+			defer wg.Done()
+			t.pollDevice(ctx, state)
+		}(state)
+	}
+
+	if t.config.Stream {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.stream(ctx)
+		}()
+	}
+
+	wg.Wait()
+
+	t.client.Publish(t.topic(bridgeName, "availability"), qos, true, offline).WaitTimeout(time.Second)
+	t.client.Disconnect(250)
+
+	return nil
+}
+
+// onConnect announces the bridge, subscribes to the command topics and
+// republishes the discovery payloads as the broker may have lost them
+func (t *Bridge) onConnect(client paho.Client) {
+
+	client.Publish(t.topic(bridgeName, "availability"), qos, true, online)
+
+	client.SubscribeMultiple(map[string]byte{
+		t.topic("+", "+", "+", "set"): qos,
+		t.topic("+", "rpc"):           qos,
+	}, func(_ paho.Client, message paho.Message) {
+		go t.command(message.Topic(), message.Payload())
+	})
+
+	for _, state := range t.devices {
+		state.mutex.Lock()
+		state.discovered = false
+		state.available = nil
+		state.mutex.Unlock()
+		state.requestRefresh()
+	}
+}
+
+// pollDevice publishes the status of the device every interval and when a
+// refresh is requested
+func (t *Bridge) pollDevice(ctx context.Context, state *deviceState) {
+
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	// The refresh requested on connect is covered by the first poll
+	select {
+	case <-state.refresh:
+	default:
+	}
+
+	for {
+
+		t.poll(ctx, state)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-state.refresh:
+		}
+	}
+}
+
+// poll publishes the status of the device. The state mutex is only held to
+// read and update the state so that commands are not held up by a slow device.
+func (t *Bridge) poll(ctx context.Context, state *deviceState) {
+
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	name := state.inventory.Name
+
+	d, status, err := t.status(ctx, state)
+	if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+
+	state.mutex.Lock()
+	wasAvailable := state.available
+	state.setAvailable(err == nil)
+	discovered := state.discovered
+	state.mutex.Unlock()
+
+	if err != nil {
+		if wasAvailable == nil || *wasAvailable {
+			t.config.Errorf("device %s: %s", name, err)
+			t.publish(t.topic(name, "availability"), true, offline)
+		}
+		return
+	}
+
+	if wasAvailable == nil || !*wasAvailable {
+		t.publish(t.topic(name, "availability"), true, online)
+	}
+
+	t.publishJSON(t.topic(name, "status"), true, status)
+
+	for _, output := range status.Outputs {
+		t.publishJSON(t.topic(name, output.Component, strconv.Itoa(output.ID)), true, output)
+	}
+
+	if !t.config.Discovery || discovered {
+		return
+	}
+
+	info, err := d.Info(ctx)
+	if err != nil {
+		t.config.Errorf("device %s: unable to get info for discovery: %s", name, err)
+		return
+	}
+
+	for topic, payload := range t.discovery(state.inventory, info, status.Outputs) {
+		t.publishJSON(topic, true, payload)
+	}
+
+	state.mutex.Lock()
+	state.discovered = true
+	state.mutex.Unlock()
+}
+
+// status returns the device and its status. The device is created on first
+// use so that the generation is detected once.
+func (t *Bridge) status(ctx context.Context, state *deviceState) (device.Device, *device.Status, error) {
+
+	state.mutex.Lock()
+	d := state.device
+	state.mutex.Unlock()
+
+	if d == nil {
+
+		var err error
+
+		d, err = device.New(ctx, &device.Config{
+			Hostname: state.inventory.Hostname,
+			Username: state.inventory.Username,
+			Password: state.inventory.Password,
+			Gen:      state.inventory.Gen,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		state.mutex.Lock()
+		state.device = d
+		state.mutex.Unlock()
+	}
+
+	status, err := d.Status(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, status, nil
+}
+
+// stream refreshes the status of Plus devices on NotifyStatus and publishes
+// their NotifyEvent params
+func (t *Bridge) stream(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for _, state := range t.devices {
+		wg.Add(1)
+		go func(state *deviceState) {
+			defer wg.Done()
+			t.streamDevice(ctx, state)
+		}(state)
+	}
+
+	wg.Wait()
+}
+
+// streamDevice watches the device once its generation is known. The detection
+// is retried every interval; Gen1 devices are not watched as they do not
+// support notifications.
+func (t *Bridge) streamDevice(ctx context.Context, state *deviceState) {
+
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+
+		gen, err := t.gen(ctx, state)
+		if err == nil && gen == 1 {
+			return
+		}
+
+		if err == nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	watch.Run(ctx, &watch.Config{Devices: []*inventory.Device{state.inventory}}, func(event *watch.Event) error {
+
+		switch event.Method {
+		case "NotifyStatus", "NotifyFullStatus":
+			state.requestRefresh()
+		case "NotifyEvent":
+			t.publish(t.topic(state.inventory.Name, "events"), false, []byte(event.Params))
+		case watch.MethodDisconnected:
+			t.config.Errorf("device %s: %s", event.Device, event.Error)
+		}
+
+		return nil
+	})
+}
+
+// command handles a message on a command topic
+func (t *Bridge) command(topic string, payload []byte) {
+
+	parts := strings.Split(strings.TrimPrefix(topic, t.config.Prefix+"/"), "/")
+
+	state := t.devices[strings.ToLower(parts[0])]
+	if state == nil {
+		t.config.Errorf("command %s: device %s not found", topic, parts[0])
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.config.Timeout)
+	defer cancel()
+
+	if len(parts) == 2 {
+		t.rpc(ctx, state, payload)
+		return
+	}
+
+	err := t.set(ctx, state, parts[1], parts[2], string(payload))
+	if err != nil {
+		t.config.Errorf("command %s: %s", topic, err)
+	}
+}
+
+func (t *Bridge) set(ctx context.Context, state *deviceState, component, id, payload string) error {
+
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 0 {
+		return fmt.Errorf("id %s is invalid", id)
+	}
+
+	action := device.Action(strings.ToLower(strings.TrimSpace(payload)))
+
+	switch action {
+	case device.On, device.Off, device.Toggle:
+	default:
+		return fmt.Errorf("payload %q is invalid; expect on, off or toggle", payload)
+	}
+
+	state.mutex.Lock()
+	d := state.device
+	state.mutex.Unlock()
+
+	if d == nil {
+		return fmt.Errorf("device %s is not available", state.inventory.Name)
+	}
+
+	_, err = d.Set(ctx, component, i, action)
+	if err != nil {
+		return err
+	}
+
+	// The refresh publishes the output with its name and the device status
+	state.requestRefresh()
+
+	return nil
+}
+
+// rpcRequest is the payload of the rpc command topic
+type rpcRequest struct {
+	ID     any             `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is the payload of the rpc response topic
+type rpcResponse struct {
+	ID     any             `json:"id,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the device error or, with code zero, an error of the bridge
+type rpcError struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func (t *Bridge) rpc(ctx context.Context, state *deviceState, payload []byte) {
+
+	request := &rpcRequest{}
+	response := &rpcResponse{}
+
+	err := json.Unmarshal(payload, request)
+
+	switch {
+	case err != nil:
+		err = fmt.Errorf("payload is invalid: %w", err)
+	case request.Method == "":
+		err = fmt.Errorf("method is required")
+	default:
+		var gen int
+		gen, err = t.gen(ctx, state)
+		if err == nil && gen == 1 {
+			err = fmt.Errorf("device %s is a Gen1 device and does not support RPC", state.inventory.Name)
+		}
+	}
+
+	if err == nil {
+		response.ID = request.ID
+		var params any
+		if len(request.Params) > 0 {
+			params = request.Params
+		}
+		err = state.inventory.Client().Call(ctx, request.Method, params, &response.Result)
+	}
+
+	if err != nil {
+		response.Result = nil
+		response.Error = &rpcError{Message: err.Error()}
+		var rpcErr *rpc.Error
+		if errors.As(err, &rpcErr) {
+			response.Error = &rpcError{Code: rpcErr.Code, Message: rpcErr.Message}
+		}
+	}
+
+	t.publishJSON(t.topic(state.inventory.Name, "rpc", "response"), false, response)
+}
+
+// gen returns the generation of the device from the inventory, the device of
+// the last poll or by detection
+func (t *Bridge) gen(ctx context.Context, state *deviceState) (int, error) {
+
+	if state.inventory.Gen != 0 {
+		return state.inventory.Gen, nil
+	}
+
+	state.mutex.Lock()
+	d := state.device
+	state.mutex.Unlock()
+
+	if d != nil {
+		return d.Gen(), nil
+	}
+
+	return device.Detect(ctx, state.inventory.Hostname)
+}
+
+func (t *Bridge) topic(levels ...string) string {
+	return t.config.Prefix + "/" + strings.Join(levels, "/")
+}
+
+func (t *Bridge) publish(topic string, retain bool, payload any) {
+	t.client.Publish(topic, qos, retain, payload)
+}
+
+func (t *Bridge) publishJSON(topic string, retain bool, v any) {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.config.Errorf("topic %s: %s", topic, err)
+		return
+	}
+
+	t.publish(topic, retain, data)
+}
+
+func (t *deviceState) setAvailable(available bool) {
+	t.available = &available
+}
+
+func (t *deviceState) requestRefresh() {
+	select {
+	case t.refresh <- struct{}{}:
+	default:
+	}
+}
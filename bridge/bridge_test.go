@@ -0,0 +1,215 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gorilla/websocket"
+
+	"github.com/jodydadescott/shelly-go-cli/broker"
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+// newPlusDevice returns a fake Plus device with one switch. Its websocket
+// sends one NotifyEvent after the registration.
+func newPlusDevice(t *testing.T) *testdevice.Device {
+
+	on := false
+
+	return testdevice.New(t, &testdevice.Config{
+		RPC: func(method string, params map[string]any) (any, error) {
+			switch method {
+			case "Shelly.GetStatus":
+				return map[string]any{"switch:0": map[string]any{"id": 0, "output": on}}, nil
+			case "Shelly.GetConfig":
+				return map[string]any{"switch:0": map[string]any{"id": 0, "name": "lamp"}}, nil
+			case "Switch.Set":
+				on = params["on"].(bool)
+				return map[string]any{"was_on": !on}, nil
+			case "Switch.GetStatus":
+				return map[string]any{"id": 0, "output": on}, nil
+			case "Switch.GetConfig":
+				return map[string]any{"id": 0, "name": "lamp"}, nil
+			case "Shelly.GetDeviceInfo":
+				return map[string]any{"id": "shellyplus1-test", "gen": 2}, nil
+			}
+			return nil, testdevice.NotFound(method)
+		},
+		Websocket: func(path string, conn *websocket.Conn) {
+			request := &struct {
+				ID int `json:"id"`
+			}{}
+			if conn.ReadJSON(request) != nil {
+				return
+			}
+			conn.WriteJSON(map[string]any{"id": request.ID, "result": map[string]any{}})
+			conn.WriteJSON(map[string]any{"method": "NotifyEvent", "params": map[string]any{"events": []any{map[string]any{"event": "single_push"}}}})
+			conn.ReadMessage()
+		},
+	})
+}
+
+// newGen1Device returns a fake Gen1 device with one relay
+func newGen1Device(t *testing.T) *testdevice.Device {
+	return testdevice.New(t, &testdevice.Config{
+		Shelly: testdevice.Gen1,
+		Paths: map[string]string{
+			"/status":   `{"relays":[{"ison":true}]}`,
+			"/settings": `{"name":"relay","relays":[{"name":"pump"}]}`,
+		},
+	})
+}
+
+// messages collects the messages of a subscription by topic
+type messages struct {
+	mutex    sync.Mutex
+	payloads map[string][]string
+}
+
+// wait returns the last payload of topic that contains want
+func (t *messages) wait(tt *testing.T, topic, want string) string {
+
+	tt.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		t.mutex.Lock()
+		payloads := t.payloads[topic]
+		t.mutex.Unlock()
+		for i := len(payloads) - 1; i >= 0; i-- {
+			if strings.Contains(payloads[i], want) {
+				return payloads[i]
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tt.Fatalf("no message on %s containing %s", topic, want)
+	return ""
+}
+
+func TestBridge(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go broker.New(t.Logf).Serve(ctx, listener)
+
+	url := "tcp://" + listener.Addr().String()
+
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(url).SetClientID("test"))
+
+	token := client.Connect()
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("connect: %v", token.Error())
+	}
+
+	defer client.Disconnect(0)
+
+	received := &messages{payloads: make(map[string][]string)}
+
+	token = client.Subscribe("shelly/#", 1, func(_ paho.Client, message paho.Message) {
+		received.mutex.Lock()
+		defer received.mutex.Unlock()
+		received.payloads[message.Topic()] = append(received.payloads[message.Topic()], string(message.Payload()))
+	})
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe: %v", token.Error())
+	}
+
+	plug := newPlusDevice(t)
+
+	var errorsMutex sync.Mutex
+	var errors []string
+
+	// The relay has no generation in the inventory
+	bridge := New(&Config{
+		Broker:   url,
+		Devices:  []*inventory.Device{plug.Inventory("plug"), newGen1Device(t).Inventory("relay")},
+		Interval: time.Hour,
+		Stream:   true,
+		Errorf: func(format string, args ...any) {
+			errorsMutex.Lock()
+			defer errorsMutex.Unlock()
+			errors = append(errors, fmt.Sprintf(format, args...))
+		},
+	})
+
+	bridgeCtx, stopBridge := context.WithCancel(ctx)
+	stopped := make(chan error)
+
+	go func() {
+		stopped <- bridge.Run(bridgeCtx)
+	}()
+
+	received.wait(t, "shelly/bridge/availability", "online")
+	received.wait(t, "shelly/plug/availability", "online")
+	received.wait(t, "shelly/plug/switch/0", `"name":"lamp","on":false`)
+	received.wait(t, "shelly/relay/switch/0", `"name":"pump","on":true`)
+	received.wait(t, "shelly/plug/events", "single_push")
+
+	client.Publish("shelly/plug/switch/0/set", 1, false, "on").WaitTimeout(time.Second)
+
+	received.wait(t, "shelly/plug/switch/0", `"name":"lamp","on":true`)
+
+	client.Publish("shelly/plug/rpc", 1, false, `{"id":7,"method":"Shelly.GetDeviceInfo"}`).WaitTimeout(time.Second)
+
+	response := received.wait(t, "shelly/plug/rpc/response", `"id":7`)
+	if !strings.Contains(response, `"result":{"gen":2,"id":"shellyplus1-test"}`) {
+		t.Errorf("rpc response = %s", response)
+	}
+
+	client.Publish("shelly/plug/rpc", 1, false, `{"id":8,"method":"Foo.Bar"}`).WaitTimeout(time.Second)
+
+	response = received.wait(t, "shelly/plug/rpc/response", `"id":8`)
+	if !strings.Contains(response, `"error":{"code":404,"message":"No handler for Foo.Bar"}`) {
+		t.Errorf("rpc error response = %s", response)
+	}
+
+	client.Publish("shelly/relay/rpc", 1, false, `{"id":9,"method":"Shelly.GetStatus"}`).WaitTimeout(time.Second)
+
+	response = received.wait(t, "shelly/relay/rpc/response", "Gen1")
+	if !strings.Contains(response, "relay is a Gen1 device") {
+		t.Errorf("Gen1 rpc response = %s", response)
+	}
+
+	stopBridge()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("run error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge did not stop")
+	}
+
+	received.wait(t, "shelly/bridge/availability", "offline")
+
+	if methods := plug.Methods(); !strings.Contains(strings.Join(methods, ","), "Switch.Set") {
+		t.Errorf("device methods %v; want Switch.Set", methods)
+	}
+
+	// The detected Gen1 device is not watched
+	errorsMutex.Lock()
+	defer errorsMutex.Unlock()
+
+	for _, err := range errors {
+		if strings.HasPrefix(err, "device relay") {
+			t.Errorf("unexpected error %s", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+var unsafeID = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// sensors are the Home Assistant sensors of the output status fields
+var sensors = []struct {
+	field       string
+	unit        string
+	deviceClass string
+	stateClass  string
+	present     func(*device.Output) bool
+}{
+	{"power", "W", "power", "measurement", func(o *device.Output) bool { return o.Power != nil }},
+	{"energy", "Wh", "energy", "total_increasing", func(o *device.Output) bool { return o.Energy != nil }},
+	{"temperature", "°C", "temperature", "measurement", func(o *device.Output) bool { return o.Temperature != nil }},
+}
+
+// discovery returns the Home Assistant MQTT discovery payloads by topic for
+// the outputs of the device. Switches and lights are controllable entities;
+// power, energy and temperature are sensors. Covers are not supported as the
+// bridge can not control them.
+func (t *Bridge) discovery(d *inventory.Device, info *device.Info, outputs device.Outputs) map[string]map[string]any {
+
+	nodeID := "shelly_cli_" + unsafeID.ReplaceAllString(d.Name, "_")
+
+	haDevice := map[string]any{
+		"identifiers":  []string{nodeID},
+		"name":         d.Name,
+		"manufacturer": "Shelly",
+		"model":        info.Model,
+		"sw_version":   info.Firmware,
+	}
+
+	availability := []map[string]string{
+		{"topic": t.topic(bridgeName, "availability")},
+		{"topic": t.topic(d.Name, "availability")},
+	}
+
+	payloads := make(map[string]map[string]any)
+
+	add := func(entity, objectID string, payload map[string]any) {
+		payload["unique_id"] = nodeID + "_" + objectID
+		payload["device"] = haDevice
+		payload["availability"] = availability
+		payload["availability_mode"] = "all"
+		payloads[fmt.Sprintf("%s/%s/%s/%s/config", t.config.DiscoveryPrefix, entity, nodeID, objectID)] = payload
+	}
+
+	for _, output := range outputs {
+
+		base := t.topic(d.Name, output.Component, strconv.Itoa(output.ID))
+		objectID := unsafeID.ReplaceAllString(output.Component+"_"+strconv.Itoa(output.ID), "_")
+
+		name := output.Name
+		if name == "" {
+			name = output.Component + " " + strconv.Itoa(output.ID)
+		}
+
+		switch output.Component {
+
+		case "switch":
+			add("switch", objectID, map[string]any{
+				"name":           name,
+				"state_topic":    base,
+				"value_template": "{{ 'on' if value_json.on else 'off' }}",
+				"state_on":       "on",
+				"state_off":      "off",
+				"command_topic":  base + "/set",
+				"payload_on":     "on",
+				"payload_off":    "off",
+			})
+
+		case "light", "rgb", "rgbw", "cct":
+			add("light", objectID, map[string]any{
+				"name":                 name,
+				"state_topic":          base,
+				"state_value_template": "{{ 'on' if value_json.on else 'off' }}",
+				"command_topic":        base + "/set",
+				"payload_on":           "on",
+				"payload_off":          "off",
+			})
+		}
+
+		for _, sensor := range sensors {
+			if !sensor.present(output) {
+				continue
+			}
+			add("sensor", objectID+"_"+sensor.field, map[string]any{
+				"name":                name + " " + sensor.field,
+				"state_topic":         base,
+				"value_template":      fmt.Sprintf("{{ value_json.%s }}", sensor.field),
+				"unit_of_measurement": sensor.unit,
+				"device_class":        sensor.deviceClass,
+				"state_class":         sensor.stateClass,
+			})
+		}
+	}
+
+	return payloads
+}
@@ -0,0 +1,336 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+const writeTimeout = 5 * time.Second
+
+// Broker is a minimal MQTT 3.1.1 broker for local testing. It supports
+// retained messages, wildcards and last will. Messages are delivered with
+// QoS 0 and there are no persistent sessions or authentication.
+type Broker struct {
+	mutex    sync.Mutex
+	clients  map[*client]bool
+	retained map[string]*packets.PublishPacket
+	errorf   func(format string, args ...any)
+}
+
+type client struct {
+	conn    net.Conn
+	id      string
+	mutex   sync.Mutex
+	filters map[string]bool
+}
+
+// New returns a new broker. Errorf is called with client errors and may be nil.
+func New(errorf func(format string, args ...any)) *Broker {
+
+	if errorf == nil {
+		errorf = func(string, ...any) {}
+	}
+
+	return &Broker{
+		clients:  make(map[*client]bool),
+		retained: make(map[string]*packets.PublishPacket),
+		errorf:   errorf,
+	}
+}
+
+// Serve accepts connections on listener until ctx is done
+func (t *Broker) Serve(ctx context.Context, listener net.Listener) error {
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		t.mutex.Lock()
+		for c := range t.clients {
+			c.conn.Close()
+		}
+		t.mutex.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go t.serveConn(conn)
+	}
+}
+
+func (t *Broker) serveConn(conn net.Conn) {
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	packet, err := packets.ReadPacket(reader)
+	if err != nil {
+		return
+	}
+
+	connect, ok := packet.(*packets.ConnectPacket)
+	if !ok {
+		return
+	}
+
+	c := &client{
+		conn:    conn,
+		id:      connect.ClientIdentifier,
+		filters: make(map[string]bool),
+	}
+
+	connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+	connack.ReturnCode = connect.Validate()
+
+	if c.write(connack) != nil || connack.ReturnCode != packets.Accepted {
+		return
+	}
+
+	t.mutex.Lock()
+	t.clients[c] = true
+	t.mutex.Unlock()
+
+	// The will is published unless the client disconnects cleanly
+	will := connect.WillFlag
+
+	defer func() {
+		t.mutex.Lock()
+		delete(t.clients, c)
+		t.mutex.Unlock()
+		if will {
+			publish := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+			publish.TopicName = connect.WillTopic
+			publish.Payload = connect.WillMessage
+			publish.Retain = connect.WillRetain
+			t.publish(publish)
+		}
+	}()
+
+	for {
+
+		if connect.Keepalive > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(connect.Keepalive) * 1500 * time.Millisecond))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+
+		packet, err := packets.ReadPacket(reader)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !strings.Contains(err.Error(), "EOF") {
+				t.errorf("client %s: %s", c.id, err)
+			}
+			return
+		}
+
+		err = t.handle(c, packet)
+		if err != nil {
+			t.errorf("client %s: %s", c.id, err)
+			return
+		}
+
+		if _, ok := packet.(*packets.DisconnectPacket); ok {
+			will = false
+			return
+		}
+	}
+}
+
+func (t *Broker) handle(c *client, packet packets.ControlPacket) error {
+
+	switch p := packet.(type) {
+
+	case *packets.PublishPacket:
+		if strings.ContainsAny(p.TopicName, "+#") {
+			return fmt.Errorf("publish topic %s must not contain wildcards", p.TopicName)
+		}
+		switch p.Qos {
+		case 1:
+			puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+			puback.MessageID = p.MessageID
+			if err := c.write(puback); err != nil {
+				return err
+			}
+		case 2:
+			pubrec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+			pubrec.MessageID = p.MessageID
+			if err := c.write(pubrec); err != nil {
+				return err
+			}
+		}
+		t.publish(p)
+
+	case *packets.PubrelPacket:
+		pubcomp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+		pubcomp.MessageID = p.MessageID
+		return c.write(pubcomp)
+
+	case *packets.SubscribePacket:
+		suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+		suback.MessageID = p.MessageID
+		for _, filter := range p.Topics {
+			if validFilter(filter) {
+				suback.ReturnCodes = append(suback.ReturnCodes, 0)
+			} else {
+				suback.ReturnCodes = append(suback.ReturnCodes, 0x80)
+			}
+		}
+		if err := c.write(suback); err != nil {
+			return err
+		}
+		for _, filter := range p.Topics {
+			if validFilter(filter) {
+				t.subscribe(c, filter)
+			}
+		}
+
+	case *packets.UnsubscribePacket:
+		t.mutex.Lock()
+		for _, filter := range p.Topics {
+			delete(c.filters, filter)
+		}
+		t.mutex.Unlock()
+		unsuback := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+		unsuback.MessageID = p.MessageID
+		return c.write(unsuback)
+
+	case *packets.PingreqPacket:
+		return c.write(packets.NewControlPacket(packets.Pingresp))
+
+	case *packets.PubackPacket, *packets.PubrecPacket, *packets.PubcompPacket, *packets.DisconnectPacket:
+
+	default:
+		return fmt.Errorf("unexpected packet %s", packet)
+	}
+
+	return nil
+}
+
+// subscribe adds filter to the client and sends the matching retained messages
+func (t *Broker) subscribe(c *client, filter string) {
+
+	t.mutex.Lock()
+	c.filters[filter] = true
+	var retained []*packets.PublishPacket
+	for topic, p := range t.retained {
+		if match(filter, topic) {
+			retained = append(retained, p)
+		}
+	}
+	t.mutex.Unlock()
+
+	for _, p := range retained {
+		c.write(outgoing(p, true))
+	}
+}
+
+// publish stores retained messages and sends p to the subscribed clients
+func (t *Broker) publish(p *packets.PublishPacket) {
+
+	t.mutex.Lock()
+
+	if p.Retain {
+		if len(p.Payload) == 0 {
+			delete(t.retained, p.TopicName)
+		} else {
+			t.retained[p.TopicName] = outgoing(p, true)
+		}
+	}
+
+	var subscribers []*client
+	for c := range t.clients {
+		for filter := range c.filters {
+			if match(filter, p.TopicName) {
+				subscribers = append(subscribers, c)
+				break
+			}
+		}
+	}
+
+	t.mutex.Unlock()
+
+	for _, c := range subscribers {
+		// A client that can not be written to is closed by its reader
+		if c.write(outgoing(p, false)) != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// outgoing returns p as QoS 0 message with the retain flag
+func outgoing(p *packets.PublishPacket, retain bool) *packets.PublishPacket {
+	out := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	out.TopicName = p.TopicName
+	out.Payload = p.Payload
+	out.Retain = retain
+	return out
+}
+
+func (t *client) write(packet packets.ControlPacket) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return packet.Write(t.conn)
+}
+
+// validFilter returns true if the wildcards of filter are valid
+func validFilter(filter string) bool {
+
+	if filter == "" {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// match returns true if topic matches filter. Topics starting with $ are not
+// matched by a wildcard at the first level.
+func match(filter, topic string) bool {
+
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filters := strings.Split(filter, "/")
+	topics := strings.Split(topic, "/")
+
+	for i, f := range filters {
+		if f == "#" {
+			return true
+		}
+		if i >= len(topics) {
+			return false
+		}
+		if f != "+" && f != topics[i] {
+			return false
+		}
+	}
+
+	return len(filters) == len(topics)
+}
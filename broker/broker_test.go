@@ -0,0 +1,206 @@
+package broker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestMatch(t *testing.T) {
+
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{filter: "a/b", topic: "a/b", want: true},
+		{filter: "a/b", topic: "a/c", want: false},
+		{filter: "a/+", topic: "a/b", want: true},
+		{filter: "a/+", topic: "a/b/c", want: false},
+		{filter: "a/+/c", topic: "a/b/c", want: true},
+		{filter: "+/+", topic: "a/b", want: true},
+		{filter: "a/#", topic: "a", want: true},
+		{filter: "a/#", topic: "a/b/c", want: true},
+		{filter: "#", topic: "a/b", want: true},
+		{filter: "a/b/c", topic: "a/b", want: false},
+		{filter: "a/+", topic: "a/", want: true},
+		{filter: "#", topic: "$SYS/uptime", want: false},
+		{filter: "+/uptime", topic: "$SYS/uptime", want: false},
+		{filter: "$SYS/#", topic: "$SYS/uptime", want: true},
+	}
+
+	for _, test := range tests {
+		if got := match(test.filter, test.topic); got != test.want {
+			t.Errorf("match(%q, %q) = %v; want %v", test.filter, test.topic, got, test.want)
+		}
+	}
+}
+
+func TestValidFilter(t *testing.T) {
+
+	tests := map[string]bool{
+		"a/b":   true,
+		"a/+/c": true,
+		"a/#":   true,
+		"#":     true,
+		"+":     true,
+		"":      false,
+		"a/#/c": false,
+		"a#":    false,
+		"a/b+":  false,
+		"a/++":  false,
+	}
+
+	for filter, want := range tests {
+		if got := validFilter(filter); got != want {
+			t.Errorf("validFilter(%q) = %v; want %v", filter, got, want)
+		}
+	}
+}
+
+// newBroker starts a broker and returns it and its URL
+func newBroker(t *testing.T) (*Broker, string) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	broker := New(t.Logf)
+
+	go broker.Serve(ctx, listener)
+
+	return broker, "tcp://" + listener.Addr().String()
+}
+
+// closeClient closes the connection of the client with id as a network
+// failure would
+func closeClient(t *testing.T, broker *Broker, id string) {
+
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+
+	for c := range broker.clients {
+		if c.id == id {
+			c.conn.Close()
+			return
+		}
+	}
+
+	t.Fatalf("client %s not found", id)
+}
+
+// connect returns a connected client. options may be nil.
+func connect(t *testing.T, broker, id string, options *paho.ClientOptions) paho.Client {
+
+	if options == nil {
+		options = paho.NewClientOptions()
+	}
+
+	client := paho.NewClient(options.AddBroker(broker).SetClientID(id).SetAutoReconnect(false))
+
+	token := client.Connect()
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("%s: connect: %v", id, token.Error())
+	}
+
+	return client
+}
+
+// subscribe subscribes client to filter and returns the received payloads
+func subscribe(t *testing.T, client paho.Client, filter string) <-chan string {
+
+	messages := make(chan string, 10)
+
+	token := client.Subscribe(filter, 1, func(_ paho.Client, message paho.Message) {
+		messages <- message.Topic() + " " + string(message.Payload())
+	})
+
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe %s: %v", filter, token.Error())
+	}
+
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-messages:
+		if got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no message; want %q", want)
+	}
+}
+
+func TestRetain(t *testing.T) {
+
+	_, broker := newBroker(t)
+
+	publisher := connect(t, broker, "publisher", nil)
+	defer publisher.Disconnect(0)
+
+	publisher.Publish("dev/status", 1, true, "on").WaitTimeout(time.Second)
+	publisher.Publish("dev/other", 1, false, "not retained").WaitTimeout(time.Second)
+
+	subscriber := connect(t, broker, "subscriber", nil)
+	defer subscriber.Disconnect(0)
+
+	messages := subscribe(t, subscriber, "dev/#")
+
+	receive(t, messages, "dev/status on")
+
+	publisher.Publish("dev/status", 1, true, "off").WaitTimeout(time.Second)
+	receive(t, messages, "dev/status off")
+
+	// An empty retained message removes the retained message
+	publisher.Publish("dev/status", 1, true, "").WaitTimeout(time.Second)
+	receive(t, messages, "dev/status ")
+
+	late := connect(t, broker, "late", nil)
+	defer late.Disconnect(0)
+
+	lateMessages := subscribe(t, late, "dev/#")
+
+	select {
+	case got := <-lateMessages:
+		t.Errorf("got %q; want no retained message", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWill(t *testing.T) {
+
+	b, broker := newBroker(t)
+
+	subscriber := connect(t, broker, "subscriber", nil)
+	defer subscriber.Disconnect(0)
+
+	messages := subscribe(t, subscriber, "+/availability")
+
+	// A clean disconnect does not publish the will
+	clean := connect(t, broker, "clean", paho.NewClientOptions().SetWill("clean/availability", "offline", 1, true))
+	clean.Disconnect(100)
+
+	// A lost connection does
+	lost := connect(t, broker, "lost", paho.NewClientOptions().SetWill("lost/availability", "offline", 1, true))
+
+	closeClient(t, b, "lost")
+	defer lost.Disconnect(0)
+
+	receive(t, messages, "lost/availability offline")
+
+	select {
+	case got := <-messages:
+		t.Errorf("got %q; want no other will", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
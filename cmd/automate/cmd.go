@@ -0,0 +1,101 @@
+package automate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/automate"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/types"
+)
+
+type callback interface {
+	WriteStderr(string)
+	GetFiles() (*types.Files, error)
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var dryRunArg bool
+	var checkArg bool
+
+	cmd := &cobra.Command{
+		Use:   "automate",
+		Short: "Runs the actions of a rules file on device notifications",
+		Long: "Reads the rules file (-f or STDIN) and watches the trigger devices of the inventory. When a " +
+			"notification matches a trigger (an input event such as single_push or a status field crossing a " +
+			"threshold), the conditions hold and the rule is not debounced, its actions (switch, light, rpc or " +
+			"webhook) are run in order. Each action is written to STDOUT as NDJSON. Runs until interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			files, err := callback.GetFiles()
+			if err != nil {
+				return err
+			}
+
+			file := files.GetSingleFile()
+			if file == nil {
+				return fmt.Errorf("expected a single rules file")
+			}
+
+			if !file.STDIN {
+				callback.WriteStderr(fmt.Sprintf("Using file %s", file.FullName))
+			}
+
+			rules := &automate.Rules{}
+
+			err = file.Unmarshal(rules)
+			if err != nil {
+				return err
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			err = rules.Validate(inv)
+			if err != nil {
+				return err
+			}
+
+			if checkArg {
+				callback.WriteStderr(fmt.Sprintf("%d rules are valid", len(rules.Rules)))
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			var mutex sync.Mutex
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetEscapeHTML(false)
+
+			return automate.New(&automate.Config{
+				Rules:     rules,
+				Inventory: inv,
+				DryRun:    dryRunArg,
+				Log: func(record *automate.Record) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					return encoder.Encode(record)
+				},
+				Errorf: func(format string, args ...any) {
+					callback.WriteStderr(fmt.Sprintf(format, args...))
+				},
+			}).Run(ctx)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRunArg, "dry-run", false, "write the actions instead of running them")
+	cmd.Flags().BoolVar(&checkArg, "check", false, "validate the rules file and exit")
+
+	return cmd
+}
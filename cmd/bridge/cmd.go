@@ -0,0 +1,140 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/bridge"
+	"github.com/jodydadescott/shelly-go-cli/broker"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+type callback interface {
+	WriteStderr(string)
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "bridge",
+		Short: "Bridges inventory devices to other systems",
+	}
+
+	rootCmd.AddCommand(newMQTTCmd(callback))
+	return rootCmd
+}
+
+func newMQTTCmd(callback callback) *cobra.Command {
+
+	var brokerArg string
+	var brokerUsernameArg string
+	var brokerPasswordArg string
+	var clientIDArg string
+	var embeddedBrokerArg string
+	var devicesArg []string
+	var prefixArg string
+	var intervalArg time.Duration
+	var timeoutArg time.Duration
+	var streamArg bool
+	var discoveryArg bool
+	var discoveryPrefixArg string
+
+	cmd := &cobra.Command{
+		Use:   "mqtt",
+		Short: "Bridges inventory devices to an MQTT broker",
+		Long: "Publishes the status of the selected inventory devices (Gen1 and Plus) to <prefix>/<device>/status and " +
+			"<prefix>/<device>/<component>/<id> every interval, and with --stream also on each notification of Plus " +
+			"devices. Commands published to <prefix>/<device>/<component>/<id>/set (on, off or toggle) and " +
+			"<prefix>/<device>/rpc ({\"id\", \"method\", \"params\"}, response on <prefix>/<device>/rpc/response) are " +
+			"executed on the device. With --discovery Home Assistant MQTT discovery payloads are published. " +
+			"--embedded-broker starts a minimal local broker for testing. Runs until interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if brokerArg == "" && embeddedBrokerArg == "" {
+				return fmt.Errorf("broker or embedded-broker is required")
+			}
+
+			if intervalArg < time.Second {
+				return fmt.Errorf("interval must be at least 1s")
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			devices, err := inv.Select(devicesArg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			errorf := func(format string, args ...any) {
+				callback.WriteStderr(fmt.Sprintf(format, args...))
+			}
+
+			if embeddedBrokerArg != "" {
+
+				listener, err := net.Listen("tcp", embeddedBrokerArg)
+				if err != nil {
+					return err
+				}
+
+				// The broker is stopped after the bridge so that the bridge can
+				// publish that it is offline
+				brokerCtx, stopBroker := context.WithCancel(context.Background())
+				defer stopBroker()
+
+				go broker.New(errorf).Serve(brokerCtx, listener)
+
+				callback.WriteStderr(fmt.Sprintf("embedded broker listening on %s", listener.Addr()))
+
+				if brokerArg == "" {
+					brokerArg = "tcp://" + listener.Addr().String()
+				}
+			}
+
+			callback.WriteStderr(fmt.Sprintf("bridging %d devices to %s", len(devices), brokerArg))
+
+			return bridge.New(&bridge.Config{
+				Broker:          brokerArg,
+				Username:        brokerUsernameArg,
+				Password:        brokerPasswordArg,
+				ClientID:        clientIDArg,
+				Devices:         devices,
+				Prefix:          prefixArg,
+				Interval:        intervalArg,
+				Timeout:         timeoutArg,
+				Stream:          streamArg,
+				Discovery:       discoveryArg,
+				DiscoveryPrefix: discoveryPrefixArg,
+				Errorf:          errorf,
+			}).Run(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&brokerArg, "broker", "", "broker URL such as tcp://broker:1883; defaults to the embedded broker")
+	cmd.Flags().StringVar(&brokerUsernameArg, "broker-username", "", "broker username")
+	cmd.Flags().StringVar(&brokerPasswordArg, "broker-password", "", "broker password")
+	cmd.Flags().StringVar(&clientIDArg, "client-id", "", "MQTT client id; defaults to a unique id")
+	cmd.Flags().StringVar(&embeddedBrokerArg, "embedded-broker", "", "listen address of an embedded broker for testing such as 127.0.0.1:1883")
+	cmd.Flags().StringSliceVar(&devicesArg, "devices", nil, "inventory device names; defaults to all devices")
+	cmd.Flags().StringVar(&prefixArg, "topic-prefix", "shelly", "topic prefix")
+	cmd.Flags().DurationVar(&intervalArg, "interval", 30*time.Second, "status poll interval")
+	cmd.Flags().DurationVar(&timeoutArg, "timeout", 10*time.Second, "timeout of device requests")
+	cmd.Flags().BoolVar(&streamArg, "stream", false, "also publish the status of Plus devices on their notifications and their events")
+	cmd.Flags().BoolVar(&discoveryArg, "discovery", false, "publish Home Assistant MQTT discovery payloads")
+	cmd.Flags().StringVar(&discoveryPrefixArg, "discovery-prefix", "homeassistant", "Home Assistant discovery prefix")
+
+	return cmd
+}
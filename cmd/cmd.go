@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/PaesslerAG/jsonpath"
 	"github.com/hokaccha/go-prettyjson"
@@ -16,8 +19,21 @@ import (
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 
+	automatecmd "github.com/jodydadescott/shelly-go-cli/cmd/automate"
+	bridgecmd "github.com/jodydadescott/shelly-go-cli/cmd/bridge"
+	devicecmd "github.com/jodydadescott/shelly-go-cli/cmd/device"
+	exportercmd "github.com/jodydadescott/shelly-go-cli/cmd/exporter"
+	gen1cmd "github.com/jodydadescott/shelly-go-cli/cmd/gen1"
+	inventorycmd "github.com/jodydadescott/shelly-go-cli/cmd/inventory"
 	pluscmd "github.com/jodydadescott/shelly-go-cli/cmd/plus"
+	scenecmd "github.com/jodydadescott/shelly-go-cli/cmd/scene"
+	servecmd "github.com/jodydadescott/shelly-go-cli/cmd/serve"
+	watchcmd "github.com/jodydadescott/shelly-go-cli/cmd/watch"
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/gen1"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
 	"github.com/jodydadescott/shelly-go-cli/logging"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
 	"github.com/jodydadescott/shelly-go-cli/types"
 )
 
@@ -26,11 +42,18 @@ type Cmd struct {
 	*cobra.Command
 	_client         *shelly.Client
 	_plusClient     *plus.Client
+	_rpcClient      *rpc.Client
+	_gen1Client     *gen1.Client
 	hostnameArg     string
+	usernameArg     string
+	gen             int
 	passwordArg     string
+	deviceArg       string
 	outputArg       string
 	filenameArg     string
 	debugEnabledArg bool
+	// session is the shell session the command runs in, if any
+	session *session
 }
 
 func NewCmd() *Cmd {
@@ -41,13 +64,14 @@ func NewCmd() *Cmd {
 
 		Use: BinaryName,
 
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			if t.debugEnabledArg {
 				zap.ReplaceGlobals(logging.GetDebugZapLogger())
 				zap.L().Debug("debug is enabled")
 			} else {
 				zap.ReplaceGlobals(logging.GetDefaultZapLogger())
 			}
+			return t.resolveDevice()
 		},
 
 		SilenceUsage: true,
@@ -64,33 +88,121 @@ func NewCmd() *Cmd {
 	t.Command = command
 
 	t.PersistentFlags().StringVarP(&t.hostnameArg, "hostname", "H", "", fmt.Sprintf("Hostname; optionally use env var '%s'", ShellyHostnameEnvVar))
-	t.PersistentFlags().StringVarP(&t.hostnameArg, "password", "p", "", fmt.Sprintf("Password; optionally use env var '%s'", ShellyPasswordEnvVar))
-	t.PersistentFlags().StringVarP(&t.outputArg, "output", "o", ShellyOutputDefault, fmt.Sprintf("Output format. One of: prettyjson | json | jsonpath | yaml ; Optionally use env var '%s'", ShellyOutputEnvVar))
+	t.PersistentFlags().StringVarP(&t.usernameArg, "username", "u", "", fmt.Sprintf("Username (Gen1 devices only; default %s); optionally use env var '%s'", gen1.DefaultUsername, ShellyUsernameEnvVar))
+	t.PersistentFlags().StringVarP(&t.passwordArg, "password", "p", "", fmt.Sprintf("Password; optionally use env var '%s'", ShellyPasswordEnvVar))
+	t.PersistentFlags().StringVarP(&t.outputArg, "output", "o", ShellyOutputDefault, fmt.Sprintf("Output format. One of: prettyjson | json | jsonpath | yaml | table | csv ; Optionally use env var '%s'", ShellyOutputEnvVar))
 	t.PersistentFlags().StringVarP(&t.filenameArg, "filename", "f", "", "Filename or Dirname")
+	t.PersistentFlags().StringVarP(&t.deviceArg, "device", "D", "", "Device name from the inventory; sets hostname, username and password")
 	t.PersistentFlags().BoolVarP(&t.debugEnabledArg, "debug", "d", false, "debug to STDERR")
-	t.AddCommand(pluscmd.NewCmd(t))
+	t.AddCommand(pluscmd.NewCmd(t), gen1cmd.NewCmd(t), inventorycmd.NewCmd(t), scenecmd.NewCmd(t),
+		exportercmd.NewCmd(t), watchcmd.NewCmd(t), automatecmd.NewCmd(t), servecmd.NewCmd(t), bridgecmd.NewCmd(t), newShellCmd(t))
+	t.AddCommand(devicecmd.NewCmds(t)...)
 
 	return t
 }
 
+// resolveDevice sets the hostname and password from the inventory if the
+// device arg is set
+func (t *Cmd) resolveDevice() error {
+
+	if t.deviceArg == "" {
+		return nil
+	}
+
+	inv, err := inventory.Load()
+	if err != nil {
+		return err
+	}
+
+	device := inv.Get(t.deviceArg)
+	if device == nil {
+		return fmt.Errorf("device %s not found in inventory", t.deviceArg)
+	}
+
+	if t.hostnameArg == "" {
+		t.hostnameArg = device.Hostname
+	}
+
+	if t.usernameArg == "" {
+		t.usernameArg = device.Username
+	}
+
+	// The generation only applies to the inventory hostname
+	if t.hostnameArg == device.Hostname {
+		t.gen = device.Gen
+	}
+
+	if t.passwordArg == "" {
+		t.passwordArg = device.Password
+	}
+
+	return nil
+}
+
+// sessionTarget returns true if the command runs in a shell session and
+// targets the session device
+func (t *Cmd) sessionTarget() bool {
+	return t.session != nil && t.session.hostname != "" && t.hostnameArg == ""
+}
+
+// inSession returns true if the command uses the clients of the session. The
+// clients are not used if the credentials are overridden.
+func (t *Cmd) inSession() bool {
+	return t.sessionTarget() && t.usernameArg == "" && t.passwordArg == ""
+}
+
+// Hostname returns the hostname arg, the session hostname or env var
+func (t *Cmd) Hostname() string {
+	if t.hostnameArg != "" {
+		return t.hostnameArg
+	}
+	if t.sessionTarget() {
+		return t.session.hostname
+	}
+	return os.Getenv(ShellyHostnameEnvVar)
+}
+
+// Username returns the username arg, the session username or env var
+func (t *Cmd) Username() string {
+	if t.usernameArg != "" {
+		return t.usernameArg
+	}
+	if t.sessionTarget() {
+		return t.session.username
+	}
+	return os.Getenv(ShellyUsernameEnvVar)
+}
+
+// Password returns the password arg, the session password or env var
+func (t *Cmd) Password() string {
+	if t.passwordArg != "" {
+		return t.passwordArg
+	}
+	if t.sessionTarget() {
+		return t.session.password
+	}
+	return os.Getenv(ShellyPasswordEnvVar)
+}
+
+// Inventory loads the device inventory
+func (t *Cmd) Inventory() (*inventory.Inventory, error) {
+	return inventory.Load()
+}
+
 func (t *Cmd) client() *shelly.Client {
 
+	if t.inSession() {
+		return t.session.client()
+	}
+
 	if t._client != nil {
 		return t._client
 	}
 
 	config := &shelly.Config{
 		DebugEnabled: t.debugEnabledArg,
-		Hostname:     t.hostnameArg,
-		Password:     t.passwordArg,
-	}
-
-	if config.Hostname == "" {
-		config.Hostname = os.Getenv(ShellyHostnameEnvVar)
-	}
-
-	if config.Password == "" {
-		config.Password = os.Getenv(ShellyPasswordEnvVar)
+		Hostname:     t.Hostname(),
+		Password:     t.Password(),
 	}
 
 	t._client = shelly.New(config)
@@ -106,6 +218,69 @@ func (t *Cmd) PlusClient() (*plus.Client, error) {
 	return t.client().PlusClient()
 }
 
+// RPC returns the RPC client of the device; see package rpc
+func (t *Cmd) RPC() (*rpc.Client, error) {
+
+	if t.inSession() {
+		return t.session.rpc(), nil
+	}
+
+	if t._rpcClient != nil {
+		return t._rpcClient, nil
+	}
+
+	hostname := t.Hostname()
+	if hostname == "" {
+		return nil, fmt.Errorf("hostname is required")
+	}
+
+	t._rpcClient = rpc.New(&rpc.Config{
+		Hostname: hostname,
+		Password: t.Password(),
+	})
+
+	return t._rpcClient, nil
+}
+
+// Gen1 returns a client for the HTTP API of Gen1 devices
+func (t *Cmd) Gen1() (*gen1.Client, error) {
+
+	if t.inSession() {
+		return t.session.gen1(), nil
+	}
+
+	if t._gen1Client != nil {
+		return t._gen1Client, nil
+	}
+
+	hostname := t.Hostname()
+	if hostname == "" {
+		return nil, fmt.Errorf("hostname is required")
+	}
+
+	t._gen1Client = gen1.New(&gen1.Config{
+		Hostname: hostname,
+		Username: t.Username(),
+		Password: t.Password(),
+	})
+
+	return t._gen1Client, nil
+}
+
+// Device returns the generation agnostic device. The generation is taken from
+// the inventory or detected.
+func (t *Cmd) Device(ctx context.Context) (device.Device, error) {
+	if t.inSession() {
+		return t.session.device(ctx)
+	}
+	return device.New(ctx, &device.Config{
+		Hostname: t.Hostname(),
+		Username: t.Username(),
+		Password: t.Password(),
+		Gen:      t.gen,
+	})
+}
+
 // WriteObject writes object in desired format to STDOUT
 func (t *Cmd) WriteStdout(input any) error {
 
@@ -131,6 +306,34 @@ func (t *Cmd) WriteStdout(input any) error {
 
 	switch strings.ToLower(outputArgSplit[0]) {
 
+	case "table":
+		table, ok := input.(types.Table)
+		if !ok {
+			return fmt.Errorf("format type table is not supported by this command")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(table.Header(), "\t"))
+		for _, row := range table.Rows() {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+
+	case "csv":
+		table, ok := input.(types.Table)
+		if !ok {
+			return fmt.Errorf("format type csv is not supported by this command")
+		}
+		w := csv.NewWriter(os.Stdout)
+		err := w.Write(table.Header())
+		if err != nil {
+			return err
+		}
+		err = w.WriteAll(table.Rows())
+		if err != nil {
+			return err
+		}
+		return nil
+
 	case "prettyjson":
 		data, err := prettyjson.Marshal(input)
 		if err != nil {
@@ -0,0 +1,112 @@
+package device
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	Device(ctx context.Context) (device.Device, error)
+}
+
+// NewCmds returns the generation agnostic on, off, toggle, status, reboot and
+// info commands. The device generation is taken from the inventory or detected.
+func NewCmds(callback callback) []*cobra.Command {
+
+	newSetCmd := func(action device.Action, short string) *cobra.Command {
+
+		var idArg int
+		var componentArg string
+
+		cmd := &cobra.Command{
+			Use:   string(action),
+			Short: short,
+			RunE: func(cmd *cobra.Command, args []string) error {
+
+				if idArg < 0 {
+					return fmt.Errorf("id must not be negative")
+				}
+
+				d, err := callback.Device(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				output, err := d.Set(cmd.Context(), componentArg, idArg, action)
+				if err != nil {
+					return err
+				}
+
+				return callback.WriteStdout(output)
+			},
+		}
+
+		cmd.Flags().IntVar(&idArg, "id", 0, "output ID integer")
+		cmd.Flags().StringVar(&componentArg, "component", "", "output component. One of: switch (relay) | light | rgb | rgbw | cct ; defaults to the first on the device")
+		return cmd
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns the normalised status of Gen1 and Plus devices; outputs, power, energy and temperature",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			d, err := callback.Device(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			status, err := d.Status(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(status)
+		},
+	}
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Returns the normalised device info of Gen1 and Plus devices; generation, model, MAC and firmware",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			d, err := callback.Device(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			info, err := d.Info(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(info)
+		},
+	}
+
+	rebootCmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "Reboots Gen1 or Plus device",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			d, err := callback.Device(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return d.Reboot(cmd.Context())
+		},
+	}
+
+	return []*cobra.Command{
+		newSetCmd(device.On, "Turns output of Gen1 or Plus device on"),
+		newSetCmd(device.Off, "Turns output of Gen1 or Plus device off"),
+		newSetCmd(device.Toggle, "Toggles output of Gen1 or Plus device"),
+		statusCmd, infoCmd, rebootCmd,
+	}
+}
@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/exporter"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+type callback interface {
+	WriteStderr(string)
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var listenArg string
+	var intervalArg time.Duration
+	var timeoutArg time.Duration
+	var devicesArg []string
+
+	cmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Exposes the status of inventory devices as Prometheus metrics",
+		Long: "Collects the status of every selected inventory device (Gen1 and Plus) every interval and exposes " +
+			"power, energy, output state, temperature, Wi-Fi RSSI and uptime with device and component labels on " +
+			"/metrics, together with collection duration and error metrics. Runs until interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if intervalArg < time.Second {
+				return fmt.Errorf("interval must be at least 1s")
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			devices, err := inv.Select(devicesArg)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			e := exporter.New(&exporter.Config{
+				Devices:  devices,
+				Interval: intervalArg,
+				Timeout:  timeoutArg,
+				Errorf: func(format string, args ...any) {
+					callback.WriteStderr(fmt.Sprintf(format, args...))
+				},
+			})
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+			err = e.Register(registry)
+			if err != nil {
+				return err
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/" {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintln(w, `<html><body><a href="/metrics">metrics</a></body></html>`)
+			})
+
+			server := &http.Server{
+				Addr:              listenArg,
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			errs := make(chan error, 1)
+
+			go func() {
+				errs <- server.ListenAndServe()
+			}()
+
+			callback.WriteStderr(fmt.Sprintf("exporting %d devices on %s every %s", len(devices), listenArg, intervalArg))
+
+			go e.Run(ctx)
+
+			select {
+
+			case err := <-errs:
+				return err
+
+			case <-ctx.Done():
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err = server.Shutdown(shutdownCtx)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listenArg, "listen", ":9811", "listen address")
+	cmd.Flags().DurationVar(&intervalArg, "interval", 30*time.Second, "collection interval")
+	cmd.Flags().DurationVar(&timeoutArg, "timeout", 10*time.Second, "collection timeout per device")
+	cmd.Flags().StringSliceVar(&devicesArg, "devices", nil, "inventory device names; defaults to all devices")
+
+	return cmd
+}
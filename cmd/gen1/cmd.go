@@ -0,0 +1,247 @@
+package gen1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	lightcmd "github.com/jodydadescott/shelly-go-cli/cmd/gen1/light"
+	relaycmd "github.com/jodydadescott/shelly-go-cli/cmd/gen1/relay"
+	rollercmd "github.com/jodydadescott/shelly-go-cli/cmd/gen1/roller"
+	"github.com/jodydadescott/shelly-go-cli/gen1"
+	"github.com/jodydadescott/shelly-go-cli/types"
+)
+
+type callback interface {
+	Gen1() (*gen1.Client, error)
+	WriteStdout(any) error
+	WriteStderr(string)
+	GetFiles() (*types.Files, error)
+}
+
+type Cmd struct {
+	*cobra.Command
+	callback
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	t := &Cmd{}
+
+	t.Command = &cobra.Command{
+		Use:   "gen1",
+		Short: "Shelly Gen1 (Shelly 1, 2.5, Dimmer 2 and so on)",
+	}
+
+	t.callback = callback
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Returns device info; type, MAC and firmware (/shelly)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return t.Call(cmd.Context(), "/shelly", nil)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns device status (/status)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return t.Call(cmd.Context(), "/status", nil)
+		},
+	}
+
+	settingsCmd := &cobra.Command{
+		Use:   "settings",
+		Short: "Device settings (/settings)",
+	}
+
+	getSettingsCmd := &cobra.Command{
+		Use:   "get",
+		Short: "Returns settings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return t.Call(cmd.Context(), "/settings", nil)
+		},
+	}
+
+	var setArg []string
+
+	setSettingsCmd := &cobra.Command{
+		Use:   "set",
+		Short: "Sets settings from --set key=value args or from file / STDIN",
+		Long: "Sets settings from --set key=value args or from file / STDIN. Nested file values are flattened " +
+			"with an underscore (mqtt: {enable: true} is sent as mqtt_enable=true). Read-only keys such as device " +
+			"and fw, keys with their own endpoint such as wifi_sta, login and cloud, and lists of objects such as " +
+			"relays are skipped, so the output of get may be used as input; relays, lights and rollers are set " +
+			"with the relay, light and roller commands.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params, err := ParseSet(setArg)
+			if err != nil {
+				return err
+			}
+
+			if len(params) == 0 {
+				params, err = t.ReadSettingsFile()
+				if err != nil {
+					return err
+				}
+			}
+
+			return t.Call(cmd.Context(), "/settings", params)
+		},
+	}
+
+	setSettingsCmd.Flags().StringArrayVar(&setArg, "set", nil, "setting as key=value such as name=kitchen; may be repeated")
+
+	settingsCmd.AddCommand(getSettingsCmd, setSettingsCmd)
+
+	otaCmd := &cobra.Command{
+		Use:   "ota",
+		Short: "Firmware update (/ota)",
+	}
+
+	otaStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns update status and the new version if one is available",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return t.Call(cmd.Context(), "/ota", nil)
+		},
+	}
+
+	otaCheckCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Checks for a new firmware version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return t.Call(cmd.Context(), "/ota/check", nil)
+		},
+	}
+
+	var urlArg string
+	var betaArg bool
+
+	otaUpdateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Updates the firmware to the latest stable or beta version or from url",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params := url.Values{}
+
+			switch {
+
+			case urlArg != "" && betaArg:
+				return fmt.Errorf("url and beta are mutually exclusive")
+
+			case urlArg != "":
+				params.Set("url", urlArg)
+
+			case betaArg:
+				params.Set("beta", "true")
+
+			default:
+				params.Set("update", "true")
+			}
+
+			return t.Call(cmd.Context(), "/ota", params)
+		},
+	}
+
+	otaUpdateCmd.Flags().StringVar(&urlArg, "url", "", "firmware URL")
+	otaUpdateCmd.Flags().BoolVar(&betaArg, "beta", false, "update to the latest beta version")
+
+	otaCmd.AddCommand(otaStatusCmd, otaCheckCmd, otaUpdateCmd)
+
+	rebootCmd := &cobra.Command{
+		Use:   "reboot",
+		Short: "Reboots device",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := t.Gen1()
+			if err != nil {
+				return err
+			}
+
+			return client.Get(cmd.Context(), "/reboot", nil, nil)
+		},
+	}
+
+	t.AddCommand(infoCmd, statusCmd, settingsCmd, otaCmd, rebootCmd,
+		relaycmd.NewCmd(t), lightcmd.NewCmd(t), rollercmd.NewCmd(t))
+
+	return t.Command
+}
+
+// Call requests path with params and writes the result
+func (t *Cmd) Call(ctx context.Context, path string, params url.Values) error {
+
+	client, err := t.Gen1()
+	if err != nil {
+		return err
+	}
+
+	var result map[string]any
+
+	err = client.Get(ctx, path, params, &result)
+	if err != nil {
+		return err
+	}
+
+	return t.WriteStdout(result)
+}
+
+// ReadSettingsFile decodes the single file or STDIN input as JSON or YAML and
+// returns it as query params. Skipped keys are written to STDERR.
+func (t *Cmd) ReadSettingsFile() (url.Values, error) {
+
+	files, err := t.GetFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	file := files.GetSingleFile()
+	if file == nil {
+		return nil, fmt.Errorf("expected a single file")
+	}
+
+	if !file.STDIN {
+		t.WriteStderr(fmt.Sprintf("Using file %s", file.FullName))
+	}
+
+	var settings map[string]any
+
+	err = file.Unmarshal(&settings)
+	if err != nil {
+		return nil, err
+	}
+
+	params, skipped := gen1.Params(settings)
+
+	if len(skipped) > 0 {
+		t.WriteStderr(fmt.Sprintf("skipping %s", strings.Join(skipped, ", ")))
+	}
+
+	if len(params) == 0 {
+		return nil, fmt.Errorf("no settings found in file")
+	}
+
+	return params, nil
+}
+
+// ParseSet parses key=value args
+func ParseSet(args []string) (url.Values, error) {
+
+	params := url.Values{}
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("set %s is invalid; expect key=value", arg)
+		}
+		params.Set(key, value)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,191 @@
+package light
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	Call(ctx context.Context, path string, params url.Values) error
+	ReadSettingsFile() (url.Values, error)
+}
+
+// defaultStates are the valid power on states of a light
+var defaultStates = map[string]bool{"off": true, "on": true, "last": true, "switch": true}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var lightIDArg string
+
+	getLightID := func() (*int, error) {
+
+		if lightIDArg == "" {
+			return nil, fmt.Errorf("lightID is required")
+		}
+
+		lightID, err := strconv.Atoi(lightIDArg)
+		if err == nil && lightID >= 0 {
+			return &lightID, nil
+		}
+
+		return nil, fmt.Errorf("lightID must be a non negative integer")
+	}
+
+	// call requests the light path with prefix and params
+	call := func(ctx context.Context, prefix string, params url.Values) error {
+
+		lightID, err := getLightID()
+		if err != nil {
+			return err
+		}
+
+		return callback.Call(ctx, fmt.Sprintf("%s/light/%d", prefix, *lightID), params)
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "light",
+		Short: "Light; dimmers and bulbs (/light/N)",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&lightIDArg, "id", "0", "light ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "", nil)
+		},
+	}
+
+	var brightnessArg int
+	var transitionArg int
+	var timerArg int
+
+	turn := func(cmd *cobra.Command, turn string) error {
+
+		flags := cmd.Flags()
+		params := url.Values{"turn": {turn}}
+
+		if flags.Changed("brightness") {
+			if brightnessArg < 1 || brightnessArg > 100 {
+				return fmt.Errorf("brightness must be 1 to 100")
+			}
+			params.Set("brightness", strconv.Itoa(brightnessArg))
+		}
+
+		if flags.Changed("transition") {
+			if transitionArg < 0 || transitionArg > 5000 {
+				return fmt.Errorf("transition must be 0 to 5000 ms")
+			}
+			params.Set("transition", strconv.Itoa(transitionArg))
+		}
+
+		if timerArg < 0 {
+			return fmt.Errorf("timer must not be negative")
+		}
+
+		if timerArg > 0 {
+			params.Set("timer", strconv.Itoa(timerArg))
+		}
+
+		return call(cmd.Context(), "", params)
+	}
+
+	onCmd := &cobra.Command{
+		Use:   "on",
+		Short: "Turns light on",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd, "on")
+		},
+	}
+
+	offCmd := &cobra.Command{
+		Use:   "off",
+		Short: "Turns light off",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd, "off")
+		},
+	}
+
+	toggleCmd := &cobra.Command{
+		Use:   "toggle",
+		Short: "Toggles light",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd, "toggle")
+		},
+	}
+
+	onCmd.Flags().IntVar(&brightnessArg, "brightness", 0, "brightness percent 1 to 100")
+
+	for _, cmd := range []*cobra.Command{onCmd, offCmd, toggleCmd} {
+		cmd.Flags().IntVar(&transitionArg, "transition", 0, "transition time in ms (dimmers)")
+		cmd.Flags().IntVar(&timerArg, "timer", 0, "seconds after which the action is reverted")
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config (/settings/light/N)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "/settings", nil)
+		},
+	}
+
+	var nameArg string
+	var defaultStateArg string
+	var autoOnArg float64
+	var autoOffArg float64
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			params := url.Values{}
+
+			if flags.Changed("name") {
+				params.Set("name", nameArg)
+			}
+
+			if flags.Changed("default-state") {
+				if !defaultStates[defaultStateArg] {
+					return fmt.Errorf("default-state must be one of off, on, last or switch")
+				}
+				params.Set("default_state", defaultStateArg)
+			}
+
+			for flag, value := range map[string]float64{"auto-on": autoOnArg, "auto-off": autoOffArg} {
+				if !flags.Changed(flag) {
+					continue
+				}
+				if value < 0 {
+					return fmt.Errorf("%s must not be negative", flag)
+				}
+				params.Set(strings.ReplaceAll(flag, "-", "_"), strconv.FormatFloat(value, 'f', -1, 64))
+			}
+
+			if len(params) == 0 {
+				var err error
+				params, err = callback.ReadSettingsFile()
+				if err != nil {
+					return err
+				}
+			}
+
+			return call(cmd.Context(), "/settings", params)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "light name")
+	setConfigCmd.Flags().StringVar(&defaultStateArg, "default-state", "", "power on state. One of: off | on | last | switch")
+	setConfigCmd.Flags().Float64Var(&autoOnArg, "auto-on", 0, "seconds after which the light is turned on; 0 to disable")
+	setConfigCmd.Flags().Float64Var(&autoOffArg, "auto-off", 0, "seconds after which the light is turned off; 0 to disable")
+
+	rootCmd.AddCommand(statusCmd, onCmd, offCmd, toggleCmd, getConfigCmd, setConfigCmd)
+	return rootCmd
+}
@@ -0,0 +1,171 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	Call(ctx context.Context, path string, params url.Values) error
+	ReadSettingsFile() (url.Values, error)
+}
+
+// defaultStates are the valid power on states of a relay
+var defaultStates = map[string]bool{"off": true, "on": true, "last": true, "switch": true}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var relayIDArg string
+
+	getRelayID := func() (*int, error) {
+
+		if relayIDArg == "" {
+			return nil, fmt.Errorf("relayID is required")
+		}
+
+		relayID, err := strconv.Atoi(relayIDArg)
+		if err == nil && relayID >= 0 {
+			return &relayID, nil
+		}
+
+		return nil, fmt.Errorf("relayID must be a non negative integer")
+	}
+
+	// call requests the relay path with prefix and params
+	call := func(ctx context.Context, prefix string, params url.Values) error {
+
+		relayID, err := getRelayID()
+		if err != nil {
+			return err
+		}
+
+		return callback.Call(ctx, fmt.Sprintf("%s/relay/%d", prefix, *relayID), params)
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "relay",
+		Short: "Relay (/relay/N)",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&relayIDArg, "id", "0", "relay ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "", nil)
+		},
+	}
+
+	var timerArg int
+
+	turn := func(ctx context.Context, turn string) error {
+
+		params := url.Values{"turn": {turn}}
+
+		if timerArg < 0 {
+			return fmt.Errorf("timer must not be negative")
+		}
+
+		if timerArg > 0 {
+			params.Set("timer", strconv.Itoa(timerArg))
+		}
+
+		return call(ctx, "", params)
+	}
+
+	onCmd := &cobra.Command{
+		Use:   "on",
+		Short: "Turns relay on",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd.Context(), "on")
+		},
+	}
+
+	offCmd := &cobra.Command{
+		Use:   "off",
+		Short: "Turns relay off",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd.Context(), "off")
+		},
+	}
+
+	toggleCmd := &cobra.Command{
+		Use:   "toggle",
+		Short: "Toggles relay",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return turn(cmd.Context(), "toggle")
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{onCmd, offCmd, toggleCmd} {
+		cmd.Flags().IntVar(&timerArg, "timer", 0, "seconds after which the action is reverted")
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config (/settings/relay/N)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "/settings", nil)
+		},
+	}
+
+	var nameArg string
+	var defaultStateArg string
+	var autoOnArg float64
+	var autoOffArg float64
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			params := url.Values{}
+
+			if flags.Changed("name") {
+				params.Set("name", nameArg)
+			}
+
+			if flags.Changed("default-state") {
+				if !defaultStates[defaultStateArg] {
+					return fmt.Errorf("default-state must be one of off, on, last or switch")
+				}
+				params.Set("default_state", defaultStateArg)
+			}
+
+			for flag, value := range map[string]float64{"auto-on": autoOnArg, "auto-off": autoOffArg} {
+				if !flags.Changed(flag) {
+					continue
+				}
+				if value < 0 {
+					return fmt.Errorf("%s must not be negative", flag)
+				}
+				params.Set(strings.ReplaceAll(flag, "-", "_"), strconv.FormatFloat(value, 'f', -1, 64))
+			}
+
+			if len(params) == 0 {
+				var err error
+				params, err = callback.ReadSettingsFile()
+				if err != nil {
+					return err
+				}
+			}
+
+			return call(cmd.Context(), "/settings", params)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "relay name")
+	setConfigCmd.Flags().StringVar(&defaultStateArg, "default-state", "", "power on state. One of: off | on | last | switch")
+	setConfigCmd.Flags().Float64Var(&autoOnArg, "auto-on", 0, "seconds after which the relay is turned on; 0 to disable")
+	setConfigCmd.Flags().Float64Var(&autoOffArg, "auto-off", 0, "seconds after which the relay is turned off; 0 to disable")
+
+	rootCmd.AddCommand(statusCmd, onCmd, offCmd, toggleCmd, getConfigCmd, setConfigCmd)
+	return rootCmd
+}
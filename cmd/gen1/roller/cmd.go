@@ -0,0 +1,162 @@
+package roller
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	Call(ctx context.Context, path string, params url.Values) error
+	ReadSettingsFile() (url.Values, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var rollerIDArg string
+
+	getRollerID := func() (*int, error) {
+
+		if rollerIDArg == "" {
+			return nil, fmt.Errorf("rollerID is required")
+		}
+
+		rollerID, err := strconv.Atoi(rollerIDArg)
+		if err == nil && rollerID >= 0 {
+			return &rollerID, nil
+		}
+
+		return nil, fmt.Errorf("rollerID must be a non negative integer")
+	}
+
+	// call requests the roller path with prefix, suffix and params
+	call := func(ctx context.Context, prefix, suffix string, params url.Values) error {
+
+		rollerID, err := getRollerID()
+		if err != nil {
+			return err
+		}
+
+		return callback.Call(ctx, fmt.Sprintf("%s/roller/%d%s", prefix, *rollerID, suffix), params)
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "roller",
+		Short: "Roller shutter; Shelly 2.5 in roller mode (/roller/N)",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&rollerIDArg, "id", "0", "roller ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status; state, position and power",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "", "", nil)
+		},
+	}
+
+	var durationArg float64
+
+	move := func(ctx context.Context, direction string) error {
+
+		params := url.Values{"go": {direction}}
+
+		if durationArg < 0 {
+			return fmt.Errorf("duration must not be negative")
+		}
+
+		if durationArg > 0 {
+			params.Set("duration", strconv.FormatFloat(durationArg, 'f', -1, 64))
+		}
+
+		return call(ctx, "", "", params)
+	}
+
+	openCmd := &cobra.Command{
+		Use:   "open",
+		Short: "Opens roller",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return move(cmd.Context(), "open")
+		},
+	}
+
+	closeCmd := &cobra.Command{
+		Use:   "close",
+		Short: "Closes roller",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return move(cmd.Context(), "close")
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{openCmd, closeCmd} {
+		cmd.Flags().Float64Var(&durationArg, "duration", 0, "seconds to move; default is until fully open or closed")
+	}
+
+	stopCmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stops roller",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "", "", url.Values{"go": {"stop"}})
+		},
+	}
+
+	var posArg int
+
+	toPosCmd := &cobra.Command{
+		Use:   "to-pos",
+		Short: "Moves roller to position; requires calibration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if !cmd.Flags().Changed("pos") {
+				return fmt.Errorf("pos is required")
+			}
+
+			if posArg < 0 || posArg > 100 {
+				return fmt.Errorf("pos must be 0 (closed) to 100 (open)")
+			}
+
+			return call(cmd.Context(), "", "", url.Values{
+				"go":         {"to_pos"},
+				"roller_pos": {strconv.Itoa(posArg)},
+			})
+		},
+	}
+
+	toPosCmd.Flags().IntVar(&posArg, "pos", 0, "position 0 (closed) to 100 (open)")
+
+	calibrateCmd := &cobra.Command{
+		Use:   "calibrate",
+		Short: "Starts calibration; the roller moves fully open and closed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "", "/calibrate", nil)
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config (/settings/roller/N)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "/settings", "", nil)
+		},
+	}
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params, err := callback.ReadSettingsFile()
+			if err != nil {
+				return err
+			}
+
+			return call(cmd.Context(), "/settings", "", params)
+		},
+	}
+
+	rootCmd.AddCommand(statusCmd, openCmd, closeCmd, stopCmd, toPosCmd, calibrateCmd, getConfigCmd, setConfigCmd)
+	return rootCmd
+}
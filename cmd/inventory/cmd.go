@@ -0,0 +1,136 @@
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	Hostname() string
+	Username() string
+	Password() string
+}
+
+type devices []*inventory.Device
+
+func (t devices) Header() []string {
+	return []string{"NAME", "HOSTNAME", "GEN"}
+}
+
+func (t devices) Rows() [][]string {
+	var rows [][]string
+	for _, device := range t {
+		gen := ""
+		if device.Gen > 0 {
+			gen = strconv.Itoa(device.Gen)
+		}
+		rows = append(rows, []string{device.Name, device.Hostname, gen})
+	}
+	return rows
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Manage the named device inventory",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists devices",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inv, err := inventory.Load()
+			if err != nil {
+				return err
+			}
+
+			// Passwords are not written
+			var result devices
+			for _, device := range inv.Devices {
+				result = append(result, &inventory.Device{Name: device.Name, Hostname: device.Hostname, Gen: device.Gen})
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var genArg int
+
+	addCmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Adds or replaces a device using the hostname, username and password args",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if callback.Hostname() == "" {
+				return fmt.Errorf("hostname is required")
+			}
+
+			if strings.ContainsAny(args[0], ",: ") {
+				return fmt.Errorf("device name must not contain commas, colons or spaces")
+			}
+
+			if genArg < 0 {
+				return fmt.Errorf("gen must not be negative")
+			}
+
+			// The generation is detected so that commands do not have to
+			if genArg == 0 {
+				gen, err := device.Detect(cmd.Context(), callback.Hostname())
+				if err != nil {
+					callback.WriteStderr(fmt.Sprintf("%s; the generation will be detected when used", err))
+				}
+				genArg = gen
+			}
+
+			inv, err := inventory.Load()
+			if err != nil {
+				return err
+			}
+
+			inv.Add(&inventory.Device{
+				Name:     args[0],
+				Hostname: callback.Hostname(),
+				Username: callback.Username(),
+				Password: callback.Password(),
+				Gen:      genArg,
+			})
+
+			return inv.Save()
+		},
+	}
+
+	addCmd.Flags().IntVar(&genArg, "gen", 0, "device generation; 1 for Gen1 devices. Detected if not set")
+
+	removeCmd := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Removes a device",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inv, err := inventory.Load()
+			if err != nil {
+				return err
+			}
+
+			if !inv.Remove(args[0]) {
+				return fmt.Errorf("device %s not found in inventory", args[0])
+			}
+
+			return inv.Save()
+		},
+	}
+
+	rootCmd.AddCommand(listCmd, addCmd, removeCmd)
+	return rootCmd
+}
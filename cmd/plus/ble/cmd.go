@@ -0,0 +1,83 @@
+package ble
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "ble",
+		Short: "Bluetooth Low Energy Component",
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "BLE.GetConfig", nil)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "BLE.GetStatus", nil)
+		},
+	}
+
+	var enableArg bool
+	var rpcArg bool
+	var observerArg bool
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("enable") {
+				config["enable"] = enableArg
+			}
+
+			if flags.Changed("rpc") {
+				config["rpc"] = map[string]any{"enable": rpcArg}
+			}
+
+			if flags.Changed("observer") {
+				config["observer"] = map[string]any{"enable": observerArg}
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "BLE.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().BoolVar(&enableArg, "enable", false, "enable bluetooth; --enable=false to disable")
+	setConfigCmd.Flags().BoolVar(&rpcArg, "rpc", false, "accept RPC over bluetooth")
+	setConfigCmd.Flags().BoolVar(&observerArg, "observer", false, "enable observer mode (receive BLE advertisements)")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(getConfigCmd, setConfigCmd, statusCmd)
+	return rootCmd
+}
@@ -0,0 +1,323 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// State is the subset of the switch, light and color component status that is
+// reported before and after an action
+type State struct {
+	Output     *bool    `json:"output,omitempty" yaml:"output,omitempty"`
+	Brightness *float64 `json:"brightness,omitempty" yaml:"brightness,omitempty"`
+	RGB        []int    `json:"rgb,omitempty" yaml:"rgb,omitempty"`
+	White      *float64 `json:"white,omitempty" yaml:"white,omitempty"`
+	CT         *int     `json:"ct,omitempty" yaml:"ct,omitempty"`
+}
+
+func (t *State) String() string {
+
+	if t == nil || t.Output == nil {
+		return "-"
+	}
+
+	s := "off"
+	if *t.Output {
+		s = "on"
+	}
+
+	if t.Brightness != nil {
+		s = fmt.Sprintf("%s (%g%%)", s, *t.Brightness)
+	}
+
+	if len(t.RGB) == 3 {
+		s = fmt.Sprintf("%s #%02x%02x%02x", s, t.RGB[0], t.RGB[1], t.RGB[2])
+	}
+
+	if t.White != nil {
+		s = fmt.Sprintf("%s w:%g", s, *t.White)
+	}
+
+	if t.CT != nil {
+		s = fmt.Sprintf("%s %dK", s, *t.CT)
+	}
+
+	return s
+}
+
+type Result struct {
+	ID    int    `json:"id" yaml:"id"`
+	Prior *State `json:"prior,omitempty" yaml:"prior,omitempty"`
+	New   *State `json:"new,omitempty" yaml:"new,omitempty"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type Results []*Result
+
+func (t Results) Header() []string {
+	return []string{"ID", "PRIOR", "NEW", "ERROR"}
+}
+
+func (t Results) Rows() [][]string {
+	var rows [][]string
+	for _, result := range t {
+		rows = append(rows, []string{strconv.Itoa(result.ID), result.Prior.String(), result.New.String(), result.Error})
+	}
+	return rows
+}
+
+// maxRange is the largest number of IDs a range may expand to. Devices have at
+// most a few dozen instances of a component.
+const maxRange = 100
+
+// ParseID parses the id arg of a single component such as a meter or script
+func ParseID(component string, arg string) (int, error) {
+
+	if arg == "" {
+		return 0, fmt.Errorf("%s ID is required", component)
+	}
+
+	id, err := strconv.Atoi(arg)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("%s ID %s is invalid; expect a non negative integer", component, arg)
+	}
+
+	return id, nil
+}
+
+// ParseIDs resolves the id arg for component (switch, light, ...) into a sorted
+// list of channel IDs. The arg is a comma separated list where each item is a
+// non negative integer, a range such as 0-3, a component key such as switch:1,
+// a component name as configured on the device or the keyword all.
+func ParseIDs(ctx context.Context, client *rpc.Client, component string, arg string) ([]int, error) {
+
+	if arg == "" {
+		return nil, fmt.Errorf("%s ID is required", component)
+	}
+
+	ids := make(map[int]bool)
+	var names []string
+
+	for _, item := range strings.Split(arg, ",") {
+
+		item = strings.TrimSpace(item)
+
+		if item == "" {
+			continue
+		}
+
+		if strings.EqualFold(item, "all") {
+			all, err := getIDs(ctx, client, component)
+			if err != nil {
+				return nil, err
+			}
+			if len(all) == 0 {
+				return nil, fmt.Errorf("device has no %s components", component)
+			}
+			for _, id := range all {
+				ids[id] = true
+			}
+			continue
+		}
+
+		if id, err := strconv.Atoi(item); err == nil {
+			if id < 0 {
+				return nil, fmt.Errorf("%s ID %d is invalid; expect a non negative integer", component, id)
+			}
+			ids[id] = true
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToLower(item), component+":") {
+			id, err := strconv.Atoi(item[len(component)+1:])
+			if err != nil || id < 0 {
+				return nil, fmt.Errorf("%s is not a valid %s key", item, component)
+			}
+			ids[id] = true
+			continue
+		}
+
+		if split := strings.SplitN(item, "-", 2); len(split) == 2 {
+			from, err1 := strconv.Atoi(split[0])
+			to, err2 := strconv.Atoi(split[1])
+			if err1 == nil && err2 == nil {
+				if from > to {
+					return nil, fmt.Errorf("range %s is invalid", item)
+				}
+				if to-from >= maxRange {
+					return nil, fmt.Errorf("range %s is too large; expect at most %d IDs", item, maxRange)
+				}
+				for id := from; id <= to; id++ {
+					ids[id] = true
+				}
+				continue
+			}
+		}
+
+		names = append(names, item)
+	}
+
+	if len(names) > 0 {
+
+		byName, err := getNames(ctx, client, component)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			id, ok := byName[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("%s %s not found", component, name)
+			}
+			ids[id] = true
+		}
+	}
+
+	var result []int
+	for id := range ids {
+		result = append(result, id)
+	}
+
+	sort.Ints(result)
+	return result, nil
+}
+
+// getIDs returns the IDs of all instances of component using the keys of the
+// device status
+func getIDs(ctx context.Context, client *rpc.Client, component string) ([]int, error) {
+
+	var status map[string]json.RawMessage
+
+	err := client.Call(ctx, "Shelly.GetStatus", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+
+	for key := range status {
+		if !strings.HasPrefix(key, component+":") {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(key, component+":"))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// getNames returns a map of lower case component name to ID
+func getNames(ctx context.Context, client *rpc.Client, component string) (map[string]int, error) {
+
+	var config map[string]json.RawMessage
+
+	err := client.Call(ctx, "Shelly.GetConfig", nil, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]int)
+
+	for key, raw := range config {
+
+		if !strings.HasPrefix(key, component+":") {
+			continue
+		}
+
+		var c struct {
+			ID   int     `json:"id"`
+			Name *string `json:"name"`
+		}
+
+		err := json.Unmarshal(raw, &c)
+		if err != nil || c.Name == nil {
+			continue
+		}
+
+		names[strings.ToLower(*c.Name)] = c.ID
+	}
+
+	return names, nil
+}
+
+// GetState returns the state of component with id
+func GetState(ctx context.Context, client *rpc.Client, component string, id int) (*State, error) {
+
+	state := &State{}
+
+	err := client.Call(ctx, rpc.Method(component, "GetStatus"), map[string]int{"id": id}, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Run executes action concurrently for each id and returns the state of each
+// channel before and after the action. The returned error is not nil if any of
+// the actions failed.
+func Run(ctx context.Context, client *rpc.Client, component string, ids []int, action func(ctx context.Context, id int) error) (Results, error) {
+
+	results := make(Results, len(ids))
+
+	var wg sync.WaitGroup
+
+	for i, id := range ids {
+
+		result := &Result{ID: id}
+		results[i] = result
+
+		wg.Add(1)
+
+		go func(result *Result) {
+
+			defer wg.Done()
+
+			prior, err := GetState(ctx, client, component, result.ID)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.Prior = prior
+
+			err = action(ctx, result.ID)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+
+			state, err := GetState(ctx, client, component, result.ID)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.New = state
+
+		}(result)
+	}
+
+	wg.Wait()
+
+	var errors *multierror.Error
+
+	for _, result := range results {
+		if result.Error != "" {
+			errors = multierror.Append(errors, fmt.Errorf("%s %d: %s", component, result.ID, result.Error))
+		}
+	}
+
+	return results, errors.ErrorOrNil()
+}
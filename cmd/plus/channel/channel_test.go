@@ -0,0 +1,107 @@
+package channel
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// newDevice returns a client for a fake device with two switches, the first
+// one named Kitchen
+func newDevice(t *testing.T) *rpc.Client {
+	return testdevice.New(t, &testdevice.Config{RPC: testdevice.Results(map[string]string{
+		"Shelly.GetStatus": `{"switch:0":{},"switch:1":{},"input:0":{}}`,
+		"Shelly.GetConfig": `{"switch:0":{"id":0,"name":"Kitchen"},"switch:1":{"id":1,"name":null}}`,
+	})}).Client()
+}
+
+func TestParseIDs(t *testing.T) {
+
+	client := newDevice(t)
+
+	tests := []struct {
+		arg  string
+		want []int
+		err  string
+	}{
+		{arg: "0", want: []int{0}},
+		{arg: "0,2", want: []int{0, 2}},
+		{arg: "2, 0-1 ,1", want: []int{0, 1, 2}},
+		{arg: "0-2", want: []int{0, 1, 2}},
+		{arg: "switch:1", want: []int{1}},
+		{arg: "SWITCH:1", want: []int{1}},
+		{arg: "all", want: []int{0, 1}},
+		{arg: "kitchen", want: []int{0}},
+		{arg: "Kitchen,1", want: []int{0, 1}},
+		{arg: "", err: "switch ID is required"},
+		{arg: "-1", err: "invalid"},
+		{arg: "switch:-1", err: "not a valid switch key"},
+		{arg: "switch:x", err: "not a valid switch key"},
+		{arg: "3-1", err: "range 3-1 is invalid"},
+		{arg: "0-100000000", err: "too large"},
+		{arg: "garage", err: "switch garage not found"},
+	}
+
+	for _, test := range tests {
+
+		got, err := ParseIDs(context.Background(), client, "switch", test.arg)
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseIDs(%q) error = %v; want %q", test.arg, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseIDs(%q) error = %v", test.arg, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseIDs(%q) = %v; want %v", test.arg, got, test.want)
+		}
+	}
+}
+
+func TestParseIDsRangeLimit(t *testing.T) {
+
+	ids, err := ParseIDs(context.Background(), nil, "switch", "0-99")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != maxRange {
+		t.Errorf("got %d IDs; want %d", len(ids), maxRange)
+	}
+
+	_, err = ParseIDs(context.Background(), nil, "switch", "0-100")
+	if err == nil {
+		t.Errorf("expected an error for a range of %d IDs", maxRange+1)
+	}
+}
+
+func TestParseID(t *testing.T) {
+
+	if id, err := ParseID("meter", "2"); err != nil || id != 2 {
+		t.Errorf("ParseID(2) = %d, %v; want 2", id, err)
+	}
+
+	invalid := map[string]string{
+		"":    "meter ID is required",
+		"-1":  "meter ID -1 is invalid",
+		"x":   "meter ID x is invalid",
+		"0-3": "meter ID 0-3 is invalid",
+	}
+
+	for arg, want := range invalid {
+		_, err := ParseID("meter", arg)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseID(%q) error = %v; want %q", arg, err, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cloud
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "cloud",
+		Short: "Cloud Component",
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "Cloud.GetConfig", nil)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status; whether the device is connected to the cloud",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "Cloud.GetStatus", nil)
+		},
+	}
+
+	var enableArg bool
+	var serverArg string
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("enable") {
+				config["enable"] = enableArg
+			}
+
+			if flags.Changed("server") {
+				config["server"] = serverArg
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Cloud.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().BoolVar(&enableArg, "enable", false, "enable the cloud connection; --enable=false to disable")
+	setConfigCmd.Flags().StringVar(&serverArg, "server", "", "cloud server host:port")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(getConfigCmd, setConfigCmd, statusCmd)
+	return rootCmd
+}
@@ -2,29 +2,44 @@ package plus
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/spf13/cobra"
 
 	"github.com/jodydadescott/shelly-go-sdk/plus"
-	"github.com/jodydadescott/shelly-go-sdk/plus/input"
-	"github.com/jodydadescott/shelly-go-sdk/plus/light"
 	"github.com/jodydadescott/shelly-go-sdk/plus/shelly"
-	"github.com/jodydadescott/shelly-go-sdk/plus/switchx"
-	"github.com/jodydadescott/shelly-go-sdk/plus/system"
 	"github.com/jodydadescott/shelly-go-sdk/plus/wifi"
 
+	blecmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/ble"
+	cloudcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/cloud"
+	colorcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/color"
+	covercmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/cover"
+	energycmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/energy"
+	ethcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/eth"
+	inputcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/input"
+	kvscmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/kvs"
 	lightcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/light"
+	mqttcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/mqtt"
+	schedulecmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/schedule"
+	scriptcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/script"
+	sensorcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/sensor"
 	shellycmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/shelly"
 	switchxcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/switchx"
+	systemcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/system"
+	webhookcmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/webhook"
 	wificmd "github.com/jodydadescott/shelly-go-cli/cmd/plus/wifi"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
 	"github.com/jodydadescott/shelly-go-cli/types"
 )
 
 type callback interface {
 	PlusClient() (*plus.Client, error)
+	RPC() (*rpc.Client, error)
 	WriteStdout(any) error
 	WriteStderr(string)
 	GetFiles() (*types.Files, error)
+	Inventory() (*inventory.Inventory, error)
 }
 
 type Cmd struct {
@@ -44,7 +59,14 @@ func NewCmd(callback callback) *cobra.Command {
 
 	t.callback = callback
 
-	t.AddCommand(shellycmd.NewCmd(t), wificmd.NewCmd(t), switchxcmd.NewCmd(t), lightcmd.NewCmd(t))
+	t.AddCommand(shellycmd.NewCmd(t), wificmd.NewCmd(t), switchxcmd.NewCmd(t), lightcmd.NewCmd(t),
+		colorcmd.NewRGBCmd(t), colorcmd.NewRGBWCmd(t), colorcmd.NewCCTCmd(t),
+		inputcmd.NewCmd(t), systemcmd.NewCmd(t), covercmd.NewCmd(t),
+		scriptcmd.NewCmd(t), schedulecmd.NewCmd(t), webhookcmd.NewCmd(t), kvscmd.NewCmd(t),
+		mqttcmd.NewCmd(t), cloudcmd.NewCmd(t), blecmd.NewCmd(t), ethcmd.NewCmd(t),
+		energycmd.NewEMCmd(t), energycmd.NewEM1Cmd(t), energycmd.NewPM1Cmd(t), energycmd.NewCmd(t),
+		sensorcmd.NewTemperatureCmd(t), sensorcmd.NewHumidityCmd(t), sensorcmd.NewVoltmeterCmd(t),
+		sensorcmd.NewDevicePowerCmd(t), sensorcmd.NewSensorsCmd(t))
 	return t.Command
 }
 
@@ -52,14 +74,6 @@ func (t *Cmd) LogDebug(s string) {
 	t.WriteStderr(s)
 }
 
-func (t *Cmd) System() (*system.Client, error) {
-	client, err := t.callback.PlusClient()
-	if err != nil {
-		return nil, err
-	}
-	return client.System(), nil
-}
-
 func (t *Cmd) Shelly() (*shelly.Client, error) {
 	client, err := t.callback.PlusClient()
 	if err != nil {
@@ -76,34 +90,79 @@ func (t *Cmd) Wifi() (*wifi.Client, error) {
 	return client.Wifi(), nil
 }
 
-func (t *Cmd) Switch() (*switchx.Client, error) {
-	client, err := t.callback.PlusClient()
+func (t *Cmd) RebootDevice(ctx context.Context) error {
+	shelly, err := t.Shelly()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return client.Switch(), nil
+	return shelly.Reboot(ctx)
 }
 
-func (t *Cmd) Light() (*light.Client, error) {
-	client, err := t.callback.PlusClient()
+// ReadConfigFile decodes the single file or STDIN input as JSON or YAML into v
+func (t *Cmd) ReadConfigFile(v any) error {
+
+	files, err := t.GetFiles()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return client.Light(), nil
+
+	file := files.GetSingleFile()
+	if file == nil {
+		return fmt.Errorf("expected a single file")
+	}
+
+	if !file.STDIN {
+		t.WriteStderr(fmt.Sprintf("Using file %s", file.FullName))
+	}
+
+	return file.Unmarshal(v)
 }
 
-func (t *Cmd) Input() (*input.Client, error) {
-	client, err := t.callback.PlusClient()
+// CallAndWrite calls method with params and writes the result
+func (t *Cmd) CallAndWrite(ctx context.Context, method string, params any) error {
+
+	client, err := t.RPC()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return client.Input(), nil
+
+	var result map[string]any
+
+	err = client.Call(ctx, method, params, &result)
+	if err != nil {
+		return err
+	}
+
+	return t.WriteStdout(result)
 }
 
-func (t *Cmd) RebootDevice(ctx context.Context) error {
-	shelly, err := t.Shelly()
+// SetConfig calls the SetConfig RPC method with params. If the device reports
+// that a restart is required it is rebooted unless disableAutoReboot is set.
+func (t *Cmd) SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error {
+
+	client, err := t.RPC()
 	if err != nil {
 		return err
 	}
-	return shelly.Reboot(ctx)
+
+	result := &struct {
+		RestartRequired bool `json:"restart_required"`
+	}{}
+
+	err = client.Call(ctx, method, params, result)
+	if err != nil {
+		return err
+	}
+
+	if result.RestartRequired {
+		if disableAutoReboot {
+			t.WriteStderr("reboot is required; autoreboot is disabled")
+			return nil
+		}
+
+		t.WriteStderr("rebooting")
+		return t.RebootDevice(ctx)
+	}
+
+	return nil
 }
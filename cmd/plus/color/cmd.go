@@ -0,0 +1,270 @@
+package color
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	defaultMinKelvin = 2700
+	defaultMaxKelvin = 6500
+)
+
+var (
+	truex  = true
+	falsex = false
+)
+
+type callback interface {
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
+}
+
+type setParams struct {
+	ID                 int      `json:"id"`
+	On                 *bool    `json:"on,omitempty"`
+	Brightness         *float64 `json:"brightness,omitempty"`
+	RGB                []int    `json:"rgb,omitempty"`
+	White              *int     `json:"white,omitempty"`
+	CT                 *int     `json:"ct,omitempty"`
+	TransitionDuration *float64 `json:"transition_duration,omitempty"`
+}
+
+// NewRGBCmd returns the command for the RGB component
+func NewRGBCmd(callback callback) *cobra.Command {
+	return newCmd(callback, "rgb", "RGB light control")
+}
+
+// NewRGBWCmd returns the command for the RGBW component
+func NewRGBWCmd(callback callback) *cobra.Command {
+	return newCmd(callback, "rgbw", "RGBW light control")
+}
+
+// NewCCTCmd returns the command for the CCT (color temperature) component
+func NewCCTCmd(callback callback) *cobra.Command {
+	return newCmd(callback, "cct", "Color temperature light control")
+}
+
+func newCmd(callback callback, component string, short string) *cobra.Command {
+
+	var idArg string
+	var colorArg string
+	var brightnessArg float64
+	var whiteArg int
+	var tempArg string
+	var transitionArg time.Duration
+
+	run := func(ctx context.Context, action func(ctx context.Context, client *rpc.Client, id int) error) error {
+
+		client, err := callback.RPC()
+		if err != nil {
+			return err
+		}
+
+		ids, err := channel.ParseIDs(ctx, client, component, idArg)
+		if err != nil {
+			return err
+		}
+
+		results, runErr := channel.Run(ctx, client, component, ids, func(ctx context.Context, id int) error {
+			return action(ctx, client, id)
+		})
+
+		err = callback.WriteStdout(results)
+		if err != nil {
+			return err
+		}
+
+		return runErr
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   component,
+		Short: short,
+	}
+
+	rootCmd.PersistentFlags().StringVar(&idArg, "id", "", component+" IDs; comma separated integers, ranges (0-2), names or 'all'")
+
+	setOnCmd := &cobra.Command{
+		Use:   "on",
+		Short: "Turn " + component + " on",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, id int) error {
+				return client.Call(ctx, rpc.Method(component, "Set"), &setParams{ID: id, On: &truex}, nil)
+			})
+		},
+	}
+
+	setOffCmd := &cobra.Command{
+		Use:   "off",
+		Short: "Turn " + component + " off",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, id int) error {
+				return client.Call(ctx, rpc.Method(component, "Set"), &setParams{ID: id, On: &falsex}, nil)
+			})
+		},
+	}
+
+	toggleCmd := &cobra.Command{
+		Use:   "toggle",
+		Short: "Toggles " + component,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, id int) error {
+				return client.Call(ctx, rpc.Method(component, "Toggle"), map[string]int{"id": id}, nil)
+			})
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set",
+		Short: "Sets color, brightness or temperature and turns " + component + " on",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params := setParams{On: &truex}
+
+			if cmd.Flags().Changed("brightness") {
+				if brightnessArg < 0 || brightnessArg > 100 {
+					return fmt.Errorf("brightness must be 0 to 100")
+				}
+				params.Brightness = &brightnessArg
+			}
+
+			if colorArg != "" {
+				rgb, brightness, err := Parse(colorArg)
+				if err != nil {
+					return err
+				}
+				params.RGB = rgb.Slice()
+				if brightness != nil && params.Brightness == nil {
+					params.Brightness = brightness
+				}
+			}
+
+			if tempArg != "" {
+
+				kelvin, err := ParseKelvin(tempArg)
+				if err != nil {
+					return err
+				}
+
+				if component == "cct" {
+					params.CT = &kelvin
+				} else {
+					if params.RGB != nil {
+						return fmt.Errorf("color and temp are mutually exclusive")
+					}
+					params.RGB = FromKelvin(kelvin).Slice()
+				}
+			}
+
+			if cmd.Flags().Changed("white") {
+				if whiteArg < 0 || whiteArg > 255 {
+					return fmt.Errorf("white must be 0 to 255")
+				}
+				params.White = &whiteArg
+			}
+
+			if transitionArg < 0 {
+				return fmt.Errorf("transition must not be negative")
+			}
+
+			if transitionArg > 0 {
+				seconds := transitionArg.Seconds()
+				params.TransitionDuration = &seconds
+			}
+
+			if params.Brightness == nil && params.RGB == nil && params.White == nil && params.CT == nil {
+				return fmt.Errorf("nothing to set; use one or more of the flags")
+			}
+
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, id int) error {
+
+				err := validate(ctx, client, component, id, &params)
+				if err != nil {
+					return err
+				}
+
+				p := params
+				p.ID = id
+				return client.Call(ctx, rpc.Method(component, "Set"), &p, nil)
+			})
+		},
+	}
+
+	setCmd.Flags().Float64Var(&brightnessArg, "brightness", 0, "brightness 0 to 100")
+	setCmd.Flags().StringVar(&tempArg, "temp", "", "color temperature in Kelvin such as 2700K")
+	setCmd.Flags().DurationVar(&transitionArg, "transition", 0, "transition duration such as 2s")
+
+	if component != "cct" {
+		setCmd.Flags().StringVar(&colorArg, "color", "", "color as hex (#ff8800), name ("+strings.Join(Names(), ", ")+"), rgb(r,g,b) or hsv(h,s,v)")
+	}
+
+	if component == "rgbw" {
+		setCmd.Flags().IntVar(&whiteArg, "white", 0, "white channel 0 to 255")
+	}
+
+	rootCmd.AddCommand(setOnCmd, setOffCmd, toggleCmd, setCmd)
+	return rootCmd
+}
+
+// validate verifies that the component with id supports the values of params.
+// A value is supported if the component reports it in its status; the color
+// temperature must also be within the range of the component config.
+func validate(ctx context.Context, client *rpc.Client, component string, id int, params *setParams) error {
+
+	state, err := channel.GetState(ctx, client, component, id)
+	if err != nil {
+		return err
+	}
+
+	unsupported := func(value string) error {
+		return fmt.Errorf("%s %d does not support %s", component, id, value)
+	}
+
+	if params.Brightness != nil && state.Brightness == nil {
+		return unsupported("brightness")
+	}
+
+	if params.RGB != nil && state.RGB == nil {
+		return unsupported("color")
+	}
+
+	if params.White != nil && state.White == nil {
+		return unsupported("white")
+	}
+
+	if params.CT == nil {
+		return nil
+	}
+
+	if state.CT == nil {
+		return unsupported("color temperature")
+	}
+
+	config := &struct {
+		CTRange []int `json:"ct_range"`
+	}{}
+
+	err = client.Call(ctx, rpc.Method(component, "GetConfig"), map[string]int{"id": id}, config)
+	if err != nil {
+		return err
+	}
+
+	min, max := defaultMinKelvin, defaultMaxKelvin
+	if len(config.CTRange) == 2 {
+		min, max = config.CTRange[0], config.CTRange[1]
+	}
+
+	if *params.CT < min || *params.CT > max {
+		return fmt.Errorf("temperature %dK is not supported; device range is %dK to %dK", *params.CT, min, max)
+	}
+
+	return nil
+}
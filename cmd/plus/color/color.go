@@ -0,0 +1,214 @@
+package color
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RGB is a color with 0-255 channels
+type RGB struct {
+	R, G, B int
+}
+
+func (t *RGB) Slice() []int {
+	return []int{t.R, t.G, t.B}
+}
+
+func (t *RGB) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", t.R, t.G, t.B)
+}
+
+var namedColors = map[string]*RGB{
+	"red":       {255, 0, 0},
+	"green":     {0, 255, 0},
+	"blue":      {0, 0, 255},
+	"white":     {255, 255, 255},
+	"warmwhite": {255, 180, 107},
+	"coolwhite": {235, 238, 255},
+	"yellow":    {255, 255, 0},
+	"orange":    {255, 128, 0},
+	"amber":     {255, 191, 0},
+	"purple":    {128, 0, 255},
+	"violet":    {238, 130, 238},
+	"pink":      {255, 105, 180},
+	"magenta":   {255, 0, 255},
+	"cyan":      {0, 255, 255},
+	"teal":      {0, 128, 128},
+	"lime":      {50, 205, 50},
+}
+
+// Names returns the sorted names of the named colors
+func Names() []string {
+	var names []string
+	for name := range namedColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses a color. Supported formats are hex (#ff8800, ff8800 or #f80),
+// named colors (red, warmwhite, ...), rgb(255,136,0) and hsv(32,100,100). For
+// HSV the value is returned as brightness (0-100) and the color is returned at
+// full value. Brightness is nil for other formats.
+func Parse(s string) (*RGB, *float64, error) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if c, ok := namedColors[s]; ok {
+		return &RGB{c.R, c.G, c.B}, nil, nil
+	}
+
+	if values, ok := parseFunc(s, "rgb"); ok {
+
+		if len(values) != 3 {
+			return nil, nil, fmt.Errorf("color %s is invalid; expect rgb(r,g,b)", s)
+		}
+
+		for _, v := range values {
+			if v < 0 || v > 255 {
+				return nil, nil, fmt.Errorf("color %s is invalid; rgb values must be 0 to 255", s)
+			}
+		}
+
+		return &RGB{int(values[0]), int(values[1]), int(values[2])}, nil, nil
+	}
+
+	if values, ok := parseFunc(s, "hsv"); ok {
+
+		if len(values) != 3 {
+			return nil, nil, fmt.Errorf("color %s is invalid; expect hsv(h,s,v)", s)
+		}
+
+		if values[0] < 0 || values[0] > 360 || values[1] < 0 || values[1] > 100 || values[2] < 0 || values[2] > 100 {
+			return nil, nil, fmt.Errorf("color %s is invalid; hue must be 0 to 360, saturation and value 0 to 100", s)
+		}
+
+		brightness := values[2]
+		return FromHSV(values[0], values[1]/100, 1), &brightness, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	if len(hex) == 6 {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil {
+			return &RGB{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, nil, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("color %s is invalid; expect hex, name, rgb(r,g,b) or hsv(h,s,v)", s)
+}
+
+// parseFunc parses s in the format name(a,b,c) or name:a,b,c
+func parseFunc(s string, name string) ([]float64, bool) {
+
+	var args string
+
+	switch {
+	case strings.HasPrefix(s, name+"(") && strings.HasSuffix(s, ")"):
+		args = s[len(name)+1 : len(s)-1]
+	case strings.HasPrefix(s, name+":"):
+		args = s[len(name)+1:]
+	default:
+		return nil, false
+	}
+
+	var values []float64
+
+	for _, arg := range strings.Split(args, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil, true
+		}
+		values = append(values, v)
+	}
+
+	return values, true
+}
+
+// FromHSV converts hue (0-360), saturation (0-1) and value (0-1) to RGB
+func FromHSV(h, s, v float64) *RGB {
+
+	h = math.Mod(h, 360)
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return &RGB{
+		R: int(math.Round((r + m) * 255)),
+		G: int(math.Round((g + m) * 255)),
+		B: int(math.Round((b + m) * 255)),
+	}
+}
+
+// FromKelvin approximates the RGB color of a black body at temperature kelvin.
+// Valid for 1000K to 40000K.
+func FromKelvin(kelvin int) *RGB {
+
+	t := float64(kelvin) / 100
+
+	var r, g, b float64
+
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	clamp := func(f float64) int {
+		return int(math.Round(math.Max(0, math.Min(255, f))))
+	}
+
+	return &RGB{clamp(r), clamp(g), clamp(b)}
+}
+
+// ParseKelvin parses a color temperature such as 2700 or 2700K
+func ParseKelvin(s string) (int, error) {
+
+	k, err := strconv.Atoi(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "K"))
+	if err != nil {
+		return 0, fmt.Errorf("temperature %s is invalid; expect Kelvin such as 2700K", s)
+	}
+
+	if k < 1000 || k > 40000 {
+		return 0, fmt.Errorf("temperature %s is out of range; expect 1000K to 40000K", s)
+	}
+
+	return k, nil
+}
@@ -0,0 +1,151 @@
+package color
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		s          string
+		want       RGB
+		brightness float64
+	}{
+		{s: "#ff8800", want: RGB{255, 136, 0}},
+		{s: "FF8800", want: RGB{255, 136, 0}},
+		{s: "#f80", want: RGB{255, 136, 0}},
+		{s: "red", want: RGB{255, 0, 0}},
+		{s: " WarmWhite ", want: RGB{255, 180, 107}},
+		{s: "rgb(1, 2, 3)", want: RGB{1, 2, 3}},
+		{s: "rgb:10,20,30", want: RGB{10, 20, 30}},
+		{s: "hsv(0,100,50)", want: RGB{255, 0, 0}, brightness: 50},
+		{s: "hsv(120,100,100)", want: RGB{0, 255, 0}, brightness: 100},
+		{s: "hsv(240,0,20)", want: RGB{255, 255, 255}, brightness: 20},
+	}
+
+	for _, test := range tests {
+
+		rgb, brightness, err := Parse(test.s)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", test.s, err)
+			continue
+		}
+
+		if *rgb != test.want {
+			t.Errorf("Parse(%q) = %v; want %v", test.s, rgb, &test.want)
+		}
+
+		switch {
+		case test.brightness == 0 && brightness != nil:
+			t.Errorf("Parse(%q) brightness = %v; want nil", test.s, *brightness)
+		case test.brightness != 0 && (brightness == nil || *brightness != test.brightness):
+			t.Errorf("Parse(%q) brightness = %v; want %v", test.s, brightness, test.brightness)
+		}
+	}
+
+	for _, s := range []string{"", "chartreuse", "#ff88", "#gg0000", "rgb(1,2)", "rgb(256,0,0)", "rgb(a,b,c)", "hsv(361,0,0)", "hsv(0,101,0)"} {
+		if _, _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) expected an error", s)
+		}
+	}
+}
+
+func TestParseKelvin(t *testing.T) {
+
+	tests := map[string]int{
+		"2700":   2700,
+		"2700K":  2700,
+		"6500k":  6500,
+		" 1000 ": 1000,
+		"40000":  40000,
+	}
+
+	for s, want := range tests {
+		got, err := ParseKelvin(s)
+		if err != nil || got != want {
+			t.Errorf("ParseKelvin(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "warm", "999", "40001", "2700KK"} {
+		if _, err := ParseKelvin(s); err == nil {
+			t.Errorf("ParseKelvin(%q) expected an error", s)
+		}
+	}
+}
+
+func TestFromKelvin(t *testing.T) {
+
+	warm := FromKelvin(2700)
+	cool := FromKelvin(10000)
+
+	if warm.R != 255 || warm.B >= warm.G {
+		t.Errorf("FromKelvin(2700) = %v; want a warm color", warm)
+	}
+
+	if cool.B != 255 || cool.R >= cool.B {
+		t.Errorf("FromKelvin(10000) = %v; want a cool color", cool)
+	}
+}
+
+// newDevice returns a client for a fake device that returns status and
+// config for any GetStatus and GetConfig call
+func newDevice(t *testing.T, status string, config string) *rpc.Client {
+	return testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		switch {
+		case strings.HasSuffix(method, ".GetStatus"):
+			return json.RawMessage(status), nil
+		case strings.HasSuffix(method, ".GetConfig"):
+			return json.RawMessage(config), nil
+		}
+		return nil, testdevice.NotFound(method)
+	}}).Client()
+}
+
+func TestValidate(t *testing.T) {
+
+	brightness := 50.0
+	white := 100
+	kelvin := 3000
+	warm := 2000
+
+	rgb := newDevice(t, `{"id":0,"output":true,"brightness":40,"rgb":[255,0,0]}`, `{"id":0}`)
+	cct := newDevice(t, `{"id":0,"output":true,"brightness":40,"ct":4000}`, `{"id":0,"ct_range":[2700,6500]}`)
+
+	tests := []struct {
+		name      string
+		client    *rpc.Client
+		component string
+		params    *setParams
+		err       string
+	}{
+		{name: "rgb color", client: rgb, component: "rgb", params: &setParams{RGB: []int{1, 2, 3}, Brightness: &brightness}},
+		{name: "rgb white", client: rgb, component: "rgb", params: &setParams{White: &white}, err: "does not support white"},
+		{name: "rgb temperature", client: rgb, component: "rgb", params: &setParams{CT: &kelvin}, err: "does not support color temperature"},
+		{name: "cct temperature", client: cct, component: "cct", params: &setParams{CT: &kelvin}},
+		{name: "cct out of range", client: cct, component: "cct", params: &setParams{CT: &warm}, err: "device range is 2700K to 6500K"},
+		{name: "cct color", client: cct, component: "cct", params: &setParams{RGB: []int{1, 2, 3}}, err: "does not support color"},
+	}
+
+	for _, test := range tests {
+
+		err := validate(context.Background(), test.client, test.component, 0, test.params)
+
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: error = %v", test.name, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error = %v; want %q", test.name, err, test.err)
+		}
+	}
+}
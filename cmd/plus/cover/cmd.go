@@ -0,0 +1,423 @@
+package cover
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// waitPollInterval is the interval of status polls while waiting
+var waitPollInterval = 500 * time.Millisecond
+
+var inModes = map[string]bool{
+	"single":   true,
+	"dual":     true,
+	"detached": true,
+}
+
+var initialStates = map[string]bool{
+	"open":    true,
+	"closed":  true,
+	"stopped": true,
+}
+
+type callback interface {
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+// status is the subset of Cover.GetStatus used to wait for movement to finish
+type status struct {
+	ID         int      `json:"id" yaml:"id"`
+	State      string   `json:"state" yaml:"state"`
+	CurrentPos *float64 `json:"current_pos,omitempty" yaml:"current_pos,omitempty"`
+	TargetPos  *float64 `json:"target_pos,omitempty" yaml:"target_pos,omitempty"`
+	PosControl bool     `json:"pos_control" yaml:"pos_control"`
+}
+
+func (t *status) moving() bool {
+	return t.State == "opening" || t.State == "closing" || t.State == "calibrating"
+}
+
+// goal is the final state or position of a movement. A nil goal is reached
+// once the cover has been seen moving.
+type goal struct {
+	state string
+	pos   *float64
+	// rel is resolved to pos from the position before the movement
+	rel *float64
+}
+
+func (t *goal) reached(s *status) bool {
+
+	if t == nil {
+		return false
+	}
+
+	if t.state != "" {
+		return s.State == t.state
+	}
+
+	return t.pos != nil && s.CurrentPos != nil && math.Abs(*s.CurrentPos-*t.pos) < 1
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var coverIDArg string
+	var waitArg bool
+	var timeoutArg time.Duration
+
+	// run calls method with params and if wait is set waits until the cover has
+	// reached goal or stopped after moving and writes the final status
+	run := func(ctx context.Context, method string, params map[string]any, g *goal) error {
+
+		coverID, err := channel.ParseID("cover", coverIDArg)
+		if err != nil {
+			return err
+		}
+
+		client, err := callback.RPC()
+		if err != nil {
+			return err
+		}
+
+		params["id"] = coverID
+
+		if waitArg && g != nil && g.rel != nil {
+			before := &status{}
+			err := client.Call(ctx, "Cover.GetStatus", map[string]int{"id": coverID}, before)
+			if err != nil {
+				return err
+			}
+			if before.CurrentPos != nil {
+				pos := math.Max(0, math.Min(100, *before.CurrentPos+*g.rel))
+				g.pos = &pos
+			}
+		}
+
+		err = client.Call(ctx, method, params, nil)
+		if err != nil {
+			return err
+		}
+
+		if !waitArg {
+			return nil
+		}
+
+		result, err := wait(ctx, client, coverID, g, timeoutArg)
+		if err != nil {
+			return err
+		}
+
+		return callback.WriteStdout(result)
+	}
+
+	addWaitFlags := func(cmd *cobra.Command) {
+		cmd.Flags().BoolVar(&waitArg, "wait", false, "wait until the cover has reached its target and stopped and return the final status")
+		cmd.Flags().DurationVar(&timeoutArg, "timeout", 2*time.Minute, "maximum time to wait")
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "cover",
+		Short: "Cover / roller shutter control",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&coverIDArg, "id", "", "cover ID integer")
+
+	var durationArg float64
+
+	addDurationFlag := func(cmd *cobra.Command) {
+		cmd.Flags().Float64Var(&durationArg, "duration", 0, "seconds to move; defaults to the configured max time")
+	}
+
+	movementParams := func(cmd *cobra.Command) map[string]any {
+		params := make(map[string]any)
+		if cmd.Flags().Changed("duration") {
+			params["duration"] = durationArg
+		}
+		return params
+	}
+
+	openCmd := &cobra.Command{
+		Use:   "open",
+		Short: "Opens the cover",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), "Cover.Open", movementParams(cmd), &goal{state: "open"})
+		},
+	}
+
+	addWaitFlags(openCmd)
+	addDurationFlag(openCmd)
+
+	closeCmd := &cobra.Command{
+		Use:   "close",
+		Short: "Closes the cover",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), "Cover.Close", movementParams(cmd), &goal{state: "closed"})
+		},
+	}
+
+	addWaitFlags(closeCmd)
+	addDurationFlag(closeCmd)
+
+	stopCmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stops the cover",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), "Cover.Stop", make(map[string]any), nil)
+		},
+	}
+
+	var posArg int
+	var relArg int
+
+	gotoCmd := &cobra.Command{
+		Use:   "goto",
+		Short: "Moves the cover to an absolute (--pos) or relative (--rel) position",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+
+			params := make(map[string]any)
+			g := &goal{}
+
+			switch {
+
+			case flags.Changed("pos") && flags.Changed("rel"):
+				return fmt.Errorf("pos and rel are mutually exclusive")
+
+			case flags.Changed("pos"):
+				if posArg < 0 || posArg > 100 {
+					return fmt.Errorf("pos must be 0 to 100")
+				}
+				params["pos"] = posArg
+				pos := float64(posArg)
+				g.pos = &pos
+
+			case flags.Changed("rel"):
+				if relArg < -100 || relArg > 100 {
+					return fmt.Errorf("rel must be -100 to 100")
+				}
+				params["rel"] = relArg
+				rel := float64(relArg)
+				g.rel = &rel
+
+			default:
+				return fmt.Errorf("pos or rel is required")
+			}
+
+			return run(cmd.Context(), "Cover.GoToPosition", params, g)
+		},
+	}
+
+	gotoCmd.Flags().IntVar(&posArg, "pos", 0, "target position 0 (closed) to 100 (open)")
+	gotoCmd.Flags().IntVar(&relArg, "rel", 0, "position change -100 to 100")
+	addWaitFlags(gotoCmd)
+
+	calibrateCmd := &cobra.Command{
+		Use:   "calibrate",
+		Short: "Starts calibration; the cover fully opens and closes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), "Cover.Calibrate", make(map[string]any), nil)
+		},
+	}
+
+	addWaitFlags(calibrateCmd)
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns cover status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			coverID, err := channel.ParseID("cover", coverIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Cover.GetStatus", map[string]int{"id": coverID}, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns cover config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			coverID, err := channel.ParseID("cover", coverIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Cover.GetConfig", map[string]int{"id": coverID}, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var nameArg string
+	var inModeArg string
+	var initialStateArg string
+	var invertDirectionsArg bool
+	var swapInputsArg bool
+	var maxtimeOpenArg float64
+	var maxtimeCloseArg float64
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets cover config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			coverID, err := channel.ParseID("cover", coverIDArg)
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if flags.Changed("in-mode") {
+				if !inModes[inModeArg] {
+					return fmt.Errorf("in-mode %s is invalid; expect single, dual or detached", inModeArg)
+				}
+				config["in_mode"] = inModeArg
+			}
+
+			if flags.Changed("initial-state") {
+				if !initialStates[initialStateArg] {
+					return fmt.Errorf("initial-state %s is invalid; expect open, closed or stopped", initialStateArg)
+				}
+				config["initial_state"] = initialStateArg
+			}
+
+			if flags.Changed("invert-directions") {
+				config["invert_directions"] = invertDirectionsArg
+			}
+
+			if flags.Changed("swap-inputs") {
+				config["swap_inputs"] = swapInputsArg
+			}
+
+			if flags.Changed("maxtime-open") {
+				if maxtimeOpenArg <= 0 {
+					return fmt.Errorf("maxtime-open must be positive")
+				}
+				config["maxtime_open"] = maxtimeOpenArg
+			}
+
+			if flags.Changed("maxtime-close") {
+				if maxtimeCloseArg <= 0 {
+					return fmt.Errorf("maxtime-close must be positive")
+				}
+				config["maxtime_close"] = maxtimeCloseArg
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Cover.SetConfig", map[string]any{
+				"id":     coverID,
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "cover name")
+	setConfigCmd.Flags().StringVar(&inModeArg, "in-mode", "", "input mode. One of: single | dual | detached")
+	setConfigCmd.Flags().StringVar(&initialStateArg, "initial-state", "", "state after power on. One of: open | closed | stopped")
+	setConfigCmd.Flags().BoolVar(&invertDirectionsArg, "invert-directions", false, "swap the open and close directions")
+	setConfigCmd.Flags().BoolVar(&swapInputsArg, "swap-inputs", false, "swap the open and close inputs")
+	setConfigCmd.Flags().Float64Var(&maxtimeOpenArg, "maxtime-open", 0, "seconds to fully open")
+	setConfigCmd.Flags().Float64Var(&maxtimeCloseArg, "maxtime-close", 0, "seconds to fully close")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(openCmd, closeCmd, stopCmd, gotoCmd, calibrateCmd, statusCmd, getConfigCmd, setConfigCmd)
+	return rootCmd
+}
+
+// wait polls the cover status until it has stopped and either reached g or
+// been seen moving, or until timeout expires. A cover that has not started
+// moving yet is not taken as done.
+func wait(ctx context.Context, client *rpc.Client, coverID int, g *goal, timeout time.Duration) (*status, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	seenMoving := false
+
+	timeoutErr := func() error {
+		if !seenMoving {
+			return fmt.Errorf("cover %d did not start moving within %s", coverID, timeout)
+		}
+		return fmt.Errorf("cover %d did not stop moving within %s", coverID, timeout)
+	}
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return nil, timeoutErr()
+		case <-ticker.C:
+		}
+
+		result := &status{}
+
+		err := client.Call(ctx, "Cover.GetStatus", map[string]int{"id": coverID}, result)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, timeoutErr()
+			}
+			return nil, err
+		}
+
+		if result.moving() {
+			seenMoving = true
+			continue
+		}
+
+		if seenMoving || g.reached(result) {
+			return result, nil
+		}
+	}
+}
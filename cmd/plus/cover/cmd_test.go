@@ -0,0 +1,128 @@
+package cover
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// newCover returns a client for a fake cover that reports statuses in turn and
+// then repeats the last one
+func newCover(t *testing.T, statuses ...string) *rpc.Client {
+
+	next := 0
+
+	return testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		status := statuses[next]
+		if next < len(statuses)-1 {
+			next++
+		}
+		return json.RawMessage(status), nil
+	}}).Client()
+}
+
+func TestWait(t *testing.T) {
+
+	waitPollInterval = 10 * time.Millisecond
+
+	pos := 50.0
+
+	tests := []struct {
+		name     string
+		statuses []string
+		goal     *goal
+		timeout  time.Duration
+		want     string
+		err      string
+	}{
+		{
+			name:     "motor starts late",
+			statuses: []string{`{"state":"closed","current_pos":0}`, `{"state":"opening","current_pos":10}`, `{"state":"open","current_pos":100}`},
+			goal:     &goal{state: "open"},
+			want:     "open",
+		},
+		{
+			name:     "already open",
+			statuses: []string{`{"state":"open","current_pos":100}`},
+			goal:     &goal{state: "open"},
+			want:     "open",
+		},
+		{
+			name:     "stopped at position",
+			statuses: []string{`{"state":"stopped","current_pos":20}`, `{"state":"opening","current_pos":30}`, `{"state":"stopped","current_pos":50}`},
+			goal:     &goal{pos: &pos},
+			want:     "stopped",
+		},
+		{
+			name:     "stopped early after moving",
+			statuses: []string{`{"state":"closing","current_pos":80}`, `{"state":"stopped","current_pos":70}`},
+			goal:     &goal{state: "closed"},
+			want:     "stopped",
+		},
+		{
+			name:     "never starts",
+			statuses: []string{`{"state":"stopped","current_pos":20}`},
+			goal:     &goal{pos: &pos},
+			timeout:  200 * time.Millisecond,
+			err:      "did not start moving",
+		},
+	}
+
+	for _, test := range tests {
+
+		timeout := test.timeout
+		if timeout == 0 {
+			timeout = time.Second
+		}
+
+		result, err := wait(context.Background(), newCover(t, test.statuses...), 0, test.goal, timeout)
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error = %v; want %q", test.name, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: error = %v", test.name, err)
+			continue
+		}
+
+		if result.State != test.want {
+			t.Errorf("%s: state = %s; want %s", test.name, result.State, test.want)
+		}
+	}
+}
+
+func TestGoalReached(t *testing.T) {
+
+	pos := 40.0
+	current := 40.4
+	far := 45.0
+
+	tests := []struct {
+		name   string
+		goal   *goal
+		status *status
+		want   bool
+	}{
+		{name: "nil goal", goal: nil, status: &status{State: "open"}, want: false},
+		{name: "state", goal: &goal{state: "closed"}, status: &status{State: "closed"}, want: true},
+		{name: "other state", goal: &goal{state: "closed"}, status: &status{State: "stopped"}, want: false},
+		{name: "position", goal: &goal{pos: &pos}, status: &status{State: "stopped", CurrentPos: &current}, want: true},
+		{name: "other position", goal: &goal{pos: &pos}, status: &status{State: "stopped", CurrentPos: &far}, want: false},
+		{name: "no position", goal: &goal{pos: &pos}, status: &status{State: "stopped"}, want: false},
+	}
+
+	for _, test := range tests {
+		if got := test.goal.reached(test.status); got != test.want {
+			t.Errorf("%s: reached = %v; want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package energy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+type callback interface {
+	WriteStderr(string)
+	RPC() (*rpc.Client, error)
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+// NewEMCmd returns the command for the EM (three phase meter) component
+func NewEMCmd(callback callback) *cobra.Command {
+	return newMeterCmd(callback, "em", "EM", "EMData.ResetCounters", "Three phase energy meter (EM) Component")
+}
+
+// NewEM1Cmd returns the command for the EM1 (single phase meter) component
+func NewEM1Cmd(callback callback) *cobra.Command {
+	return newMeterCmd(callback, "em1", "EM1", "EM1Data.ResetCounters", "Single phase energy meter (EM1) Component")
+}
+
+// NewPM1Cmd returns the command for the PM1 (power meter) component
+func NewPM1Cmd(callback callback) *cobra.Command {
+	return newMeterCmd(callback, "pm1", "PM1", "PM1.ResetCounters", "Power meter (PM1) Component")
+}
+
+func newMeterCmd(callback callback, use, component, resetMethod, short string) *cobra.Command {
+
+	var meterIDArg string
+
+	// call calls method with the meter id and writes the result
+	call := func(ctx context.Context, method string) error {
+		meterID, err := channel.ParseID("meter", meterIDArg)
+		if err != nil {
+			return err
+		}
+		return callback.CallAndWrite(ctx, method, map[string]int{"id": meterID})
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+	}
+
+	rootCmd.PersistentFlags().StringVar(&meterIDArg, "id", "0", "meter ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status; voltage, current, power and energy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), component+".GetStatus")
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), component+".GetConfig")
+		},
+	}
+
+	var nameArg string
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			meterID, err := channel.ParseID("meter", meterIDArg)
+			if err != nil {
+				return err
+			}
+
+			config := make(map[string]any)
+
+			if cmd.Flags().Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), component+".SetConfig", map[string]any{
+				"id":     meterID,
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "meter name")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	resetCountersCmd := &cobra.Command{
+		Use:   "reset-counters",
+		Short: "Resets the energy counters",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), resetMethod)
+		},
+	}
+
+	rootCmd.AddCommand(statusCmd, getConfigCmd, setConfigCmd, resetCountersCmd)
+	return rootCmd
+}
+
+// NewCmd returns the energy command
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "energy",
+		Short: "Energy data",
+	}
+
+	var idArg int
+	var componentArg string
+	var fromArg string
+	var toArg string
+	var formatArg string
+	var outArg string
+	var resumeArg bool
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports stored energy data as a CSV or JSON lines time series",
+		Long: "Exports stored energy data (EMData / EM1Data) as a CSV or JSON lines time series. Data is read in " +
+			"pages and written as it arrives; with --out and --resume an interrupted export continues after the " +
+			"last record in the file.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			ctx := cmd.Context()
+
+			dataComponent, ok := map[string]string{"em": "EMData", "em1": "EM1Data"}[componentArg]
+			if !ok {
+				return fmt.Errorf("component %s is invalid; expect em or em1", componentArg)
+			}
+
+			if formatArg != "csv" && formatArg != "json" {
+				return fmt.Errorf("format %s is invalid; expect csv or json", formatArg)
+			}
+
+			if resumeArg && outArg == "" {
+				return fmt.Errorf("resume requires out")
+			}
+
+			var from, to int64
+
+			if fromArg != "" {
+				t, err := parseTime(fromArg)
+				if err != nil {
+					return err
+				}
+				from = t
+			}
+
+			to = time.Now().Unix()
+
+			if toArg != "" {
+				t, err := parseTime(toArg)
+				if err != nil {
+					return err
+				}
+				to = t
+			}
+
+			var writer recordWriter
+			var resumeTS int64
+			var err error
+
+			if outArg == "" {
+				writer = newRecordWriter(os.Stdout, formatArg, nil)
+			} else {
+				writer, resumeTS, err = openRecordWriter(outArg, formatArg, resumeArg)
+				if err != nil {
+					return err
+				}
+				if resumeTS > 0 {
+					callback.WriteStderr(fmt.Sprintf("resuming after %s", time.Unix(resumeTS, 0).UTC().Format(time.RFC3339)))
+					from = resumeTS + 1
+				}
+			}
+
+			defer writer.Close()
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			if from == 0 {
+				from, err = firstRecord(ctx, client, dataComponent, idArg)
+				if err != nil {
+					return err
+				}
+			}
+
+			if from >= to {
+				if resumeTS > 0 {
+					callback.WriteStderr("nothing to export")
+					return nil
+				}
+				return fmt.Errorf("from must be before to")
+			}
+
+			count, err := export(ctx, client, &exportConfig{
+				component: dataComponent,
+				id:        idArg,
+				from:      from,
+				to:        to,
+				writer:    writer,
+				progress: func(ts int64, count int) {
+					if outArg != "" {
+						callback.WriteStderr(fmt.Sprintf("%s %d records", time.Unix(ts, 0).UTC().Format(time.RFC3339), count))
+					}
+				},
+			})
+
+			if outArg != "" {
+				callback.WriteStderr(fmt.Sprintf("exported %d records to %s", count, outArg))
+			}
+
+			return err
+		},
+	}
+
+	exportCmd.Flags().IntVar(&idArg, "id", 0, "EMData / EM1Data ID")
+	exportCmd.Flags().StringVar(&componentArg, "component", "em", "meter component. One of: em | em1")
+	exportCmd.Flags().StringVar(&fromArg, "from", "", "start as RFC3339, YYYY-MM-DD or unix time; defaults to the first stored record")
+	exportCmd.Flags().StringVar(&toArg, "to", "", "end (exclusive) as RFC3339, YYYY-MM-DD or unix time; defaults to now")
+	exportCmd.Flags().StringVar(&formatArg, "format", "csv", "output format. One of: csv | json (JSON lines)")
+	exportCmd.Flags().StringVar(&outArg, "out", "", "write to file instead of STDOUT")
+	exportCmd.Flags().BoolVar(&resumeArg, "resume", false, "continue an interrupted export to out")
+
+	rootCmd.AddCommand(exportCmd)
+	return rootCmd
+}
+
+// parseTime parses RFC3339, YYYY-MM-DD (UTC) or unix seconds
+func parseTime(s string) (int64, error) {
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Unix(), nil
+	}
+
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil && !strings.HasPrefix(s, "-") {
+		return v, nil
+	}
+
+	return 0, fmt.Errorf("time %s is invalid; expect RFC3339, YYYY-MM-DD or unix time", s)
+}
@@ -0,0 +1,346 @@
+package energy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// resumeTail is the number of bytes read from the end of a file to find the
+// last record
+const resumeTail = 64 * 1024
+
+// recordWriter writes one time series record per call. Flush is called after
+// each page so that an interrupted export can be resumed.
+type recordWriter interface {
+	Write(keys []string, ts int64, values []any) error
+	Flush() error
+	Close() error
+}
+
+// newRecordWriter returns a writer for format. header is the CSV header of the
+// file being resumed or nil for a new file.
+func newRecordWriter(w io.Writer, format string, header []string) recordWriter {
+
+	buffer := bufio.NewWriter(w)
+
+	if format == "json" {
+		return &jsonWriter{buffer: buffer, closer: w}
+	}
+
+	return &csvWriter{buffer: buffer, csv: csv.NewWriter(buffer), closer: w, header: header}
+}
+
+// openRecordWriter opens path for writing. If resume is set and the file exists
+// the writer appends to it and the time of the last record is returned.
+func openRecordWriter(path, format string, resume bool) (recordWriter, int64, error) {
+
+	_, err := os.Stat(path)
+	if err == nil && !resume {
+		return nil, 0, fmt.Errorf("file %s exists; use --resume to continue or remove it", path)
+	}
+
+	if err != nil || !resume {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, 0, err
+		}
+		return newRecordWriter(file, format, nil), 0, nil
+	}
+
+	header, lastLine, err := readEnds(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var lastTS int64
+
+	if lastLine != "" {
+		if format == "json" {
+			record := &struct {
+				TS int64 `json:"ts"`
+			}{}
+			err = json.Unmarshal([]byte(lastLine), record)
+			lastTS = record.TS
+		} else {
+			lastTS, err = strconv.ParseInt(strings.SplitN(lastLine, ",", 2)[0], 10, 64)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to resume %s; last record is invalid: %w", path, err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var csvHeader []string
+	if format == "csv" && header != "" {
+		csvHeader = strings.Split(header, ",")
+	}
+
+	return newRecordWriter(file, format, csvHeader), lastTS, nil
+}
+
+// readEnds returns the first and the last complete line of the file. A trailing
+// incomplete line left by an interruption is truncated. For CSV files the last
+// line is empty if it is the header.
+func readEnds(path string) (string, string, error) {
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", err
+	}
+
+	first, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+
+	offset := info.Size() - resumeTail
+	if offset < 0 {
+		offset = 0
+	}
+
+	tail := make([]byte, info.Size()-offset)
+
+	_, err = file.ReadAt(tail, offset)
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+
+	end := bytes.LastIndexByte(tail, '\n')
+
+	if end < 0 && offset > 0 {
+		return "", "", fmt.Errorf("unable to resume %s; no complete record in the last %d bytes", path, resumeTail)
+	}
+
+	if end+1 < len(tail) {
+		err = file.Truncate(offset + int64(end) + 1)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if end < 0 {
+		return "", "", nil
+	}
+
+	lines := strings.Split(string(tail[:end]), "\n")
+	last := lines[len(lines)-1]
+
+	if !strings.HasSuffix(first, "\n") {
+		first = ""
+	}
+
+	first = strings.TrimSuffix(first, "\n")
+
+	if last == first && strings.HasPrefix(first, "ts,") {
+		last = ""
+	}
+
+	return first, last, nil
+}
+
+type csvWriter struct {
+	buffer *bufio.Writer
+	csv    *csv.Writer
+	closer io.Writer
+	header []string
+}
+
+func (t *csvWriter) Write(keys []string, ts int64, values []any) error {
+
+	if t.header == nil {
+		t.header = append([]string{"ts", "time"}, keys...)
+		err := t.csv.Write(t.header)
+		if err != nil {
+			return err
+		}
+	} else if strings.Join(t.header[2:], ",") != strings.Join(keys, ",") {
+		return fmt.Errorf("device keys %v do not match the file header %v", keys, t.header[2:])
+	}
+
+	record := []string{strconv.FormatInt(ts, 10), time.Unix(ts, 0).UTC().Format(time.RFC3339)}
+
+	for _, value := range values {
+		record = append(record, formatValue(value))
+	}
+
+	return t.csv.Write(record)
+}
+
+func (t *csvWriter) Flush() error {
+	t.csv.Flush()
+	if err := t.csv.Error(); err != nil {
+		return err
+	}
+	return t.buffer.Flush()
+}
+
+func (t *csvWriter) Close() error {
+	err := t.Flush()
+	if closer, ok := t.closer.(io.Closer); ok && t.closer != os.Stdout {
+		closer.Close()
+	}
+	return err
+}
+
+type jsonWriter struct {
+	buffer *bufio.Writer
+	closer io.Writer
+}
+
+func (t *jsonWriter) Write(keys []string, ts int64, values []any) error {
+
+	record := map[string]any{
+		"ts":   ts,
+		"time": time.Unix(ts, 0).UTC().Format(time.RFC3339),
+	}
+
+	for i, key := range keys {
+		if i < len(values) {
+			record[key] = values[i]
+		}
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.buffer.Write(append(b, '\n'))
+	return err
+}
+
+func (t *jsonWriter) Flush() error {
+	return t.buffer.Flush()
+}
+
+func (t *jsonWriter) Close() error {
+	err := t.Flush()
+	if closer, ok := t.closer.(io.Closer); ok && t.closer != os.Stdout {
+		closer.Close()
+	}
+	return err
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// firstRecord returns the time of the first stored record
+func firstRecord(ctx context.Context, client *rpc.Client, component string, id int) (int64, error) {
+
+	result := &struct {
+		DataBlocks []struct {
+			TS int64 `json:"ts"`
+		} `json:"data_blocks"`
+	}{}
+
+	err := client.Call(ctx, component+".GetRecords", map[string]any{"id": id, "ts": 0}, result)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(result.DataBlocks) == 0 {
+		return 0, fmt.Errorf("device has no stored energy data")
+	}
+
+	return result.DataBlocks[0].TS, nil
+}
+
+type exportConfig struct {
+	component string
+	id        int
+	from      int64
+	to        int64
+	writer    recordWriter
+	progress  func(ts int64, count int)
+}
+
+// export reads the records from from (inclusive) to to (exclusive) a page at a
+// time, following next_record_ts, and returns the number of records written
+func export(ctx context.Context, client *rpc.Client, config *exportConfig) (int, error) {
+
+	count := 0
+	ts := config.from
+
+	for ts < config.to {
+
+		page := &struct {
+			Keys []string `json:"keys"`
+			Data []struct {
+				TS     int64   `json:"ts"`
+				Period int64   `json:"period"`
+				Values [][]any `json:"values"`
+			} `json:"data"`
+			NextRecordTS *int64 `json:"next_record_ts"`
+		}{}
+
+		err := client.Call(ctx, config.component+".GetData", map[string]any{
+			"id":     config.id,
+			"ts":     ts,
+			"end_ts": config.to,
+		}, page)
+		if err != nil {
+			return count, err
+		}
+
+		for _, block := range page.Data {
+			for i, values := range block.Values {
+
+				recordTS := block.TS + int64(i)*block.Period
+
+				if recordTS < ts || recordTS >= config.to {
+					continue
+				}
+
+				err = config.writer.Write(page.Keys, recordTS, values)
+				if err != nil {
+					return count, err
+				}
+
+				count++
+			}
+		}
+
+		err = config.writer.Flush()
+		if err != nil {
+			return count, err
+		}
+
+		config.progress(ts, count)
+
+		if page.NextRecordTS == nil || *page.NextRecordTS <= ts {
+			break
+		}
+
+		ts = *page.NextRecordTS
+	}
+
+	return count, nil
+}
@@ -0,0 +1,148 @@
+package energy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTime(t *testing.T) {
+
+	valid := map[string]int64{
+		"2024-01-02T03:04:05Z":      1704164645,
+		"2024-01-02T05:04:05+02:00": 1704164645,
+		"2024-01-02":                1704153600,
+		"1704164645":                1704164645,
+		"0":                         0,
+	}
+
+	for s, want := range valid {
+		got, err := parseTime(s)
+		if err != nil || got != want {
+			t.Errorf("parseTime(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "-1", "2024-13-01", "2024-01-02 03:04:05", "yesterday"} {
+		if _, err := parseTime(s); err == nil {
+			t.Errorf("parseTime(%q) expected an error", s)
+		}
+	}
+}
+
+func TestOpenRecordWriterResume(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		lastTS int64
+		want   string
+	}{
+		{
+			name:   "csv truncated line",
+			format: "csv",
+			data:   "ts,time,a\n60,1970-01-01T00:01:00Z,1\n120,1970-01",
+			lastTS: 60,
+			want:   "ts,time,a\n60,1970-01-01T00:01:00Z,1\n180,1970-01-01T00:03:00Z,2\n",
+		},
+		{
+			name:   "csv header only",
+			format: "csv",
+			data:   "ts,time,a\n",
+			want:   "ts,time,a\n180,1970-01-01T00:03:00Z,2\n",
+		},
+		{
+			name:   "csv truncated header",
+			format: "csv",
+			data:   "ts,ti",
+			want:   "ts,time,a\n180,1970-01-01T00:03:00Z,2\n",
+		},
+		{
+			name:   "json truncated line",
+			format: "json",
+			data:   "{\"a\":1,\"time\":\"1970-01-01T00:01:00Z\",\"ts\":60}\n{\"a\":",
+			lastTS: 60,
+			want:   "{\"a\":1,\"time\":\"1970-01-01T00:01:00Z\",\"ts\":60}\n{\"a\":2,\"time\":\"1970-01-01T00:03:00Z\",\"ts\":180}\n",
+		},
+	}
+
+	for _, test := range tests {
+
+		path := filepath.Join(t.TempDir(), "export."+test.format)
+
+		err := os.WriteFile(path, []byte(test.data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writer, lastTS, err := openRecordWriter(path, test.format, true)
+		if err != nil {
+			t.Errorf("%s: error = %v", test.name, err)
+			continue
+		}
+
+		if lastTS != test.lastTS {
+			t.Errorf("%s: last ts = %d; want %d", test.name, lastTS, test.lastTS)
+		}
+
+		err = writer.Write([]string{"a"}, 180, []any{2.0})
+		if err != nil {
+			t.Errorf("%s: write error = %v", test.name, err)
+		}
+
+		writer.Close()
+
+		data, _ := os.ReadFile(path)
+		if string(data) != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, data, test.want)
+		}
+	}
+}
+
+func TestOpenRecordWriterErrors(t *testing.T) {
+
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "exists.csv")
+	os.WriteFile(path, []byte("ts,time,a\n"), 0644)
+
+	_, _, err := openRecordWriter(path, "csv", false)
+	if err == nil || !strings.Contains(err.Error(), "use --resume") {
+		t.Errorf("existing file error = %v", err)
+	}
+
+	writer, _, err := openRecordWriter(path, "csv", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Write([]string{"b"}, 60, []any{1.0})
+	if err == nil || !strings.Contains(err.Error(), "do not match the file header") {
+		t.Errorf("header mismatch error = %v", err)
+	}
+
+	writer.Close()
+
+	path = filepath.Join(dir, "invalid.csv")
+	os.WriteFile(path, []byte("ts,time,a\nx,y,1\n"), 0644)
+
+	_, _, err = openRecordWriter(path, "csv", true)
+	if err == nil || !strings.Contains(err.Error(), "last record is invalid") {
+		t.Errorf("invalid record error = %v", err)
+	}
+
+	// A tail without a line break is an error and the file is left as is
+	path = filepath.Join(dir, "long.json")
+	os.WriteFile(path, []byte("{}\n"+strings.Repeat("x", resumeTail+1)), 0644)
+
+	_, _, err = openRecordWriter(path, "json", true)
+	if err == nil || !strings.Contains(err.Error(), "no complete record") {
+		t.Errorf("long line error = %v", err)
+	}
+
+	if info, _ := os.Stat(path); info.Size() != int64(resumeTail+4) {
+		t.Errorf("file was truncated to %d bytes", info.Size())
+	}
+}
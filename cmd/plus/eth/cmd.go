@@ -0,0 +1,110 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "eth",
+		Short: "Ethernet Component (Pro devices)",
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "Eth.GetConfig", nil)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status; the IP address",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "Eth.GetStatus", nil)
+		},
+	}
+
+	var enableArg bool
+	var ipv4modeArg string
+	var ipArg string
+	var netmaskArg string
+	var gwArg string
+	var nameserverArg string
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("enable") {
+				config["enable"] = enableArg
+			}
+
+			if flags.Changed("ipv4mode") {
+				if ipv4modeArg != "dhcp" && ipv4modeArg != "static" {
+					return fmt.Errorf("ipv4mode must be dhcp or static")
+				}
+				config["ipv4mode"] = ipv4modeArg
+			}
+
+			for key, value := range map[string]string{
+				"ip":         ipArg,
+				"netmask":    netmaskArg,
+				"gw":         gwArg,
+				"nameserver": nameserverArg,
+			} {
+				if !flags.Changed(key) {
+					continue
+				}
+				if ip := net.ParseIP(value); ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+					return fmt.Errorf("%s %s is not a valid IPv4 address", key, value)
+				}
+				config[key] = value
+			}
+
+			if config["ipv4mode"] == "static" && (config["ip"] == nil || config["netmask"] == nil) {
+				return fmt.Errorf("ip and netmask are required for static ipv4mode")
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Eth.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().BoolVar(&enableArg, "enable", false, "enable ethernet; --enable=false to disable")
+	setConfigCmd.Flags().StringVar(&ipv4modeArg, "ipv4mode", "", "IPv4 mode. One of: dhcp | static")
+	setConfigCmd.Flags().StringVar(&ipArg, "ip", "", "static IP")
+	setConfigCmd.Flags().StringVar(&netmaskArg, "netmask", "", "static netmask")
+	setConfigCmd.Flags().StringVar(&gwArg, "gw", "", "static gateway")
+	setConfigCmd.Flags().StringVar(&nameserverArg, "nameserver", "", "static nameserver")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(getConfigCmd, setConfigCmd, statusCmd)
+	return rootCmd
+}
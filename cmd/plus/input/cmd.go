@@ -0,0 +1,188 @@
+package input
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+var inputTypes = map[string]bool{
+	"button": true,
+	"switch": true,
+	"analog": true,
+}
+
+var eventTypes = map[string]bool{
+	"single_push": true,
+	"double_push": true,
+	"triple_push": true,
+	"long_push":   true,
+}
+
+type callback interface {
+	CallAndWrite(ctx context.Context, method string, params any) error
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var inputIDArg string
+
+	rootCmd := &cobra.Command{
+		Use:   "input",
+		Short: "Input status, config and event trigger",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&inputIDArg, "id", "", "input ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns input status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inputID, err := channel.ParseID("input", inputIDArg)
+			if err != nil {
+				return err
+			}
+
+			return callback.CallAndWrite(cmd.Context(), "Input.GetStatus", map[string]any{"id": inputID})
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns input config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inputID, err := channel.ParseID("input", inputIDArg)
+			if err != nil {
+				return err
+			}
+
+			return callback.CallAndWrite(cmd.Context(), "Input.GetConfig", map[string]any{"id": inputID})
+		},
+	}
+
+	var nameArg string
+	var typeArg string
+	var enableArg bool
+	var invertArg bool
+	var factoryResetArg bool
+	var reportThresholdArg float64
+	var rangeMapArg []float64
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets input config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inputID, err := channel.ParseID("input", inputIDArg)
+			if err != nil {
+				return err
+			}
+
+			config := make(map[string]any)
+
+			flags := cmd.Flags()
+
+			if flags.Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if flags.Changed("type") {
+				if !inputTypes[typeArg] {
+					return fmt.Errorf("type %s is invalid; expect button, switch or analog", typeArg)
+				}
+				config["type"] = typeArg
+			}
+
+			if flags.Changed("enable") {
+				config["enable"] = enableArg
+			}
+
+			if flags.Changed("invert") {
+				config["invert"] = invertArg
+			}
+
+			if flags.Changed("factory-reset") {
+				config["factory_reset"] = factoryResetArg
+			}
+
+			if flags.Changed("report-thr") {
+				if reportThresholdArg < 1 || reportThresholdArg > 50 {
+					return fmt.Errorf("report-thr must be 1 to 50 percent")
+				}
+				config["report_thr"] = reportThresholdArg
+			}
+
+			if flags.Changed("range-map") {
+				if len(rangeMapArg) != 2 || rangeMapArg[0] < 0 || rangeMapArg[1] > 100 || rangeMapArg[0] >= rangeMapArg[1] {
+					return fmt.Errorf("range-map expects min,max percentages with 0 <= min < max <= 100")
+				}
+				config["range_map"] = rangeMapArg
+			}
+
+			if len(config) == 0 {
+
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Input.SetConfig", map[string]any{
+				"id":     inputID,
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "input name")
+	setConfigCmd.Flags().StringVar(&typeArg, "type", "", "input type. One of: button | switch | analog")
+	setConfigCmd.Flags().BoolVar(&enableArg, "enable", true, "enable the input")
+	setConfigCmd.Flags().BoolVar(&invertArg, "invert", false, "invert the input logic (switch) or value (analog)")
+	setConfigCmd.Flags().BoolVar(&factoryResetArg, "factory-reset", false, "allow factory reset by toggling the input in the first 60s after boot")
+	setConfigCmd.Flags().Float64Var(&reportThresholdArg, "report-thr", 0, "analog report threshold in percent")
+	setConfigCmd.Flags().Float64SliceVar(&rangeMapArg, "range-map", nil, "analog input range as min,max percent")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	var eventArg string
+
+	triggerCmd := &cobra.Command{
+		Use:   "trigger",
+		Short: "Emits an input event as if the button was pressed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inputID, err := channel.ParseID("input", inputIDArg)
+			if err != nil {
+				return err
+			}
+
+			if !eventTypes[eventArg] {
+				return fmt.Errorf("event %s is invalid; expect single_push, double_push, triple_push or long_push", eventArg)
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "Input.Trigger", map[string]any{
+				"id":         inputID,
+				"event_type": eventArg,
+			}, nil)
+		},
+	}
+
+	triggerCmd.Flags().StringVar(&eventArg, "event", "single_push", "event type. One of: single_push | double_push | triple_push | long_push")
+
+	rootCmd.AddCommand(statusCmd, getConfigCmd, setConfigCmd, triggerCmd)
+	return rootCmd
+}
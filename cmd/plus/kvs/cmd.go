@@ -0,0 +1,338 @@
+package kvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+}
+
+// importResult is the result of importing a single key
+type importResult struct {
+	Key    string `json:"key" yaml:"key"`
+	Action string `json:"action" yaml:"action"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type importResults []*importResult
+
+func (t importResults) Header() []string {
+	return []string{"KEY", "ACTION", "ERROR"}
+}
+
+func (t importResults) Rows() [][]string {
+	var rows [][]string
+	for _, result := range t {
+		rows = append(rows, []string{result.Key, result.Action, result.Error})
+	}
+	return rows
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "kvs",
+		Short: "Key-value store",
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Returns the value and etag of key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			item := &Item{Key: args[0]}
+
+			err = client.Call(cmd.Context(), "KVS.Get", map[string]string{"key": args[0]}, item)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(item)
+		},
+	}
+
+	var etagArg string
+	var jsonArg bool
+
+	setCmd := &cobra.Command{
+		Use:   "set <key> [value]",
+		Short: "Sets key to value or to the JSON / YAML value from file / STDIN",
+		Long: "Sets key to value or to the JSON / YAML value from file / STDIN. With --etag the value is only set " +
+			"if the key was not changed since the etag was read.",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			var value any
+
+			switch {
+
+			case len(args) == 1:
+				err := callback.ReadConfigFile(&value)
+				if err != nil {
+					return err
+				}
+
+			case jsonArg:
+				err := json.Unmarshal([]byte(args[1]), &value)
+				if err != nil {
+					return fmt.Errorf("value is not valid JSON: %w", err)
+				}
+
+			default:
+				value = args[1]
+			}
+
+			params := map[string]any{
+				"key":   args[0],
+				"value": value,
+			}
+
+			if etagArg != "" {
+				params["etag"] = etagArg
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "KVS.Set", params, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	setCmd.Flags().StringVar(&etagArg, "etag", "", "only set if the current etag matches")
+	setCmd.Flags().BoolVar(&jsonArg, "json", false, "parse value as JSON")
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete <key>",
+		Short: "Deletes key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params := map[string]any{"key": args[0]}
+
+			if etagArg != "" {
+				params["etag"] = etagArg
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "KVS.Delete", params, nil)
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&etagArg, "etag", "", "only delete if the current etag matches")
+
+	var matchArg string
+
+	addMatchFlag := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&matchArg, "match", "", "only keys matching the glob pattern such as 'light_*'")
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists keys and etags",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			items, err := getMany(cmd.Context(), client, matchArg, false)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(items)
+		},
+	}
+
+	addMatchFlag(listCmd)
+
+	getManyCmd := &cobra.Command{
+		Use:   "get-many",
+		Short: "Returns keys, etags and values",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			items, err := getMany(cmd.Context(), client, matchArg, true)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(items)
+		},
+	}
+
+	addMatchFlag(getManyCmd)
+
+	var outArg string
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports the store; the output can be used with import",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			items, err := getMany(cmd.Context(), client, matchArg, true)
+			if err != nil {
+				return err
+			}
+
+			export := &Export{Items: make(map[string]any)}
+
+			for _, item := range items {
+				export.Items[item.Key] = item.Value
+			}
+
+			if outArg == "" {
+				return callback.WriteStdout(export)
+			}
+
+			data, err := yaml.Marshal(export)
+			if err != nil {
+				return err
+			}
+
+			err = os.WriteFile(outArg, data, 0600)
+			if err != nil {
+				return err
+			}
+
+			callback.WriteStderr(fmt.Sprintf("exported %d keys to %s", len(items), outArg))
+			return nil
+		},
+	}
+
+	addMatchFlag(exportCmd)
+	exportCmd.Flags().StringVar(&outArg, "out", "", "write YAML to file instead of STDOUT")
+
+	var skipExistingArg bool
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Imports keys from file / STDIN as written by export",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			matches, err := match(matchArg)
+			if err != nil {
+				return err
+			}
+
+			export := &Export{}
+
+			err = callback.ReadConfigFile(export)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			existing := make(map[string]bool)
+
+			if skipExistingArg {
+
+				items, err := getMany(cmd.Context(), client, matchArg, false)
+				if err != nil {
+					return err
+				}
+
+				for _, item := range items {
+					existing[item.Key] = true
+				}
+			}
+
+			var results importResults
+			var failed int
+
+			keys := make([]string, 0, len(export.Items))
+			for key := range export.Items {
+				keys = append(keys, key)
+			}
+
+			sort.Strings(keys)
+
+			for _, key := range keys {
+
+				if !matches(key) {
+					continue
+				}
+
+				result := &importResult{Key: key, Action: "set"}
+				results = append(results, result)
+
+				if existing[key] {
+					result.Action = "skipped"
+					continue
+				}
+
+				err := client.Call(cmd.Context(), "KVS.Set", map[string]any{
+					"key":   key,
+					"value": export.Items[key],
+				}, nil)
+				if err != nil {
+					result.Action = "failed"
+					result.Error = err.Error()
+					failed++
+				}
+			}
+
+			err = callback.WriteStdout(results)
+			if err != nil {
+				return err
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d keys failed", failed, len(results))
+			}
+
+			return nil
+		},
+	}
+
+	addMatchFlag(importCmd)
+	importCmd.Flags().BoolVar(&skipExistingArg, "skip-existing", false, "do not overwrite keys that exist on the device")
+
+	rootCmd.AddCommand(getCmd, setCmd, deleteCmd, listCmd, getManyCmd, exportCmd, importCmd)
+	return rootCmd
+}
@@ -0,0 +1,161 @@
+package kvs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// Item is a KVS entry
+type Item struct {
+	Key   string `json:"key" yaml:"key"`
+	Etag  string `json:"etag,omitempty" yaml:"etag,omitempty"`
+	Value any    `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+type Items []*Item
+
+func (t Items) Header() []string {
+	return []string{"KEY", "ETAG", "VALUE"}
+}
+
+func (t Items) Rows() [][]string {
+	var rows [][]string
+	for _, item := range t {
+		value := ""
+		if item.Value != nil {
+			b, _ := json.Marshal(item.Value)
+			value = string(b)
+		}
+		rows = append(rows, []string{item.Key, item.Etag, value})
+	}
+	return rows
+}
+
+// Export is the file format of export and import
+type Export struct {
+	Items map[string]any `json:"items" yaml:"items"`
+}
+
+// match validates pattern and returns a function reporting whether a key
+// matches it. An empty pattern matches all keys.
+func match(pattern string) (func(string) bool, error) {
+
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("match %s is invalid: %w", pattern, err)
+	}
+
+	return func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	}, nil
+}
+
+// decodeItems decodes the items or keys of KVS.GetMany and KVS.List. Newer
+// firmware returns an array of items, older firmware an object keyed by key.
+func decodeItems(raw json.RawMessage) (Items, error) {
+
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var items Items
+
+	if raw[0] == '[' {
+		err := json.Unmarshal(raw, &items)
+		return items, err
+	}
+
+	byKey := make(map[string]*Item)
+
+	err := json.Unmarshal(raw, &byKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, item := range byKey {
+		item.Key = key
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// devicePattern returns the match param for pattern. The device only supports
+// the * wildcard, so the literal prefix of pattern is passed and the full glob
+// is applied to the returned keys.
+func devicePattern(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		pattern = pattern[:i]
+	}
+	return pattern + "*"
+}
+
+// getMany returns all items with keys matching pattern sorted by key, following
+// offset until all pages are read. If values is false only keys and etags are
+// returned.
+func getMany(ctx context.Context, client *rpc.Client, pattern string, values bool) (Items, error) {
+
+	matches, err := match(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	method := "KVS.GetMany"
+	field := "items"
+
+	if !values {
+		method = "KVS.List"
+		field = "keys"
+	}
+
+	var items Items
+
+	for {
+
+		var result map[string]json.RawMessage
+
+		err := client.Call(ctx, method, map[string]any{"match": devicePattern(pattern), "offset": len(items)}, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := decodeItems(result[field])
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+
+		var total int
+		json.Unmarshal(result["total"], &total)
+
+		if len(page) == 0 || len(items) >= total {
+			break
+		}
+	}
+
+	var matched Items
+
+	for _, item := range items {
+		if matches(item.Key) {
+			matched = append(matched, item)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Key < matched[j].Key
+	})
+
+	return matched, nil
+}
@@ -0,0 +1,161 @@
+package kvs
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+)
+
+func TestMatch(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "", key: "anything", want: true},
+		{pattern: "light_*", key: "light_1", want: true},
+		{pattern: "light_*", key: "switch_1", want: false},
+		{pattern: "light_?", key: "light_12", want: false},
+		{pattern: "light_[12]", key: "light_2", want: true},
+		{pattern: "*", key: "a/b", want: false},
+	}
+
+	for _, test := range tests {
+
+		matches, err := match(test.pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", test.pattern, err)
+		}
+
+		if got := matches(test.key); got != test.want {
+			t.Errorf("match(%q)(%q) = %v; want %v", test.pattern, test.key, got, test.want)
+		}
+	}
+
+	if _, err := match("light_["); err == nil {
+		t.Error("match(light_[) returned no error")
+	}
+}
+
+func TestDevicePattern(t *testing.T) {
+
+	tests := map[string]string{
+		"":           "*",
+		"light":      "light*",
+		"light_*":    "light_*",
+		"light_[12]": "light_*",
+		"a?b*":       "a*",
+	}
+
+	for pattern, want := range tests {
+		if got := devicePattern(pattern); got != want {
+			t.Errorf("devicePattern(%q) = %q; want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestDecodeItems(t *testing.T) {
+
+	tests := []struct {
+		raw  string
+		want Items
+	}{
+		{raw: ``},
+		{raw: `null`},
+		{
+			raw:  `[{"key":"a","etag":"1","value":5}]`,
+			want: Items{{Key: "a", Etag: "1", Value: 5.0}},
+		},
+		{
+			raw:  `{"a":{"etag":"1","value":"x"}}`,
+			want: Items{{Key: "a", Etag: "1", Value: "x"}},
+		},
+	}
+
+	for _, test := range tests {
+
+		got, err := decodeItems(json.RawMessage(test.raw))
+		if err != nil {
+			t.Fatalf("%q: %v", test.raw, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeItems(%q) = %v; want %v", test.raw, got, test.want)
+		}
+	}
+
+	if _, err := decodeItems(json.RawMessage(`"x"`)); err == nil {
+		t.Error("decodeItems of a string returned no error")
+	}
+}
+
+func TestGetMany(t *testing.T) {
+
+	keys := []string{"switch_1", "light_3", "light_1", "light_2", "light_10", "light_x"}
+
+	// The device supports only a trailing * and returns two items per page,
+	// the keys as an object as older firmware does
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+
+		prefix := strings.TrimSuffix(params["match"].(string), "*")
+
+		var matched []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				matched = append(matched, key)
+			}
+		}
+		sort.Strings(matched)
+
+		offset := int(params["offset"].(float64))
+		end := offset + 2
+		if end > len(matched) {
+			end = len(matched)
+		}
+
+		page := make(map[string]any)
+		for _, key := range matched[offset:end] {
+			page[key] = map[string]any{"etag": "e" + key, "value": key}
+		}
+
+		field := "items"
+		if method == "KVS.List" {
+			field = "keys"
+		}
+
+		return map[string]any{field: page, "offset": offset, "total": len(matched)}, nil
+	}})
+
+	items, err := getMany(context.Background(), device.Client(), "light_[0-9]*", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Key)
+	}
+
+	want := []string{"light_1", "light_10", "light_2", "light_3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v; want %v", got, want)
+	}
+
+	calls := device.Calls()
+
+	if len(calls) != 3 {
+		t.Errorf("calls = %d; want 3 pages", len(calls))
+	}
+
+	for i, call := range calls {
+		if call.Method != "KVS.GetMany" || call.Params["match"] != "light_*" || call.Params["offset"] != float64(2*i) {
+			t.Errorf("call %d: %s %v", i, call.Method, call.Params)
+		}
+	}
+}
@@ -1,40 +1,120 @@
 package light
 
 import (
+	"context"
 	"fmt"
-	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/jodydadescott/shelly-go-sdk/plus/light"
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
 )
 
+const component = "light"
+
 var (
 	truex  = true
 	falsex = false
 )
 
+type nightModeConfig struct {
+	Enable        *bool    `json:"enable,omitempty"`
+	Brightness    *float64 `json:"brightness,omitempty"`
+	ActiveBetween []string `json:"active_between,omitempty"`
+}
+
 type callback interface {
-	Light() (*light.Client, error)
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
 }
 
 func NewCmd(callback callback) *cobra.Command {
 
 	var switchIDArg string
+	var transitionArg time.Duration
+	var softwareFadeArg bool
+	var easingArg string
+	var fadeIntervalArg time.Duration
+	var stepArg float64
+
+	addFadeFlags := func(cmd *cobra.Command) {
+		cmd.Flags().DurationVar(&transitionArg, "transition", 0, "transition duration such as 2s; uses the firmware transition if supported and a software fade otherwise")
+		cmd.Flags().BoolVar(&softwareFadeArg, "software-fade", false, "always fade in software with stepped brightness changes")
+		cmd.Flags().StringVar(&easingArg, "easing", "linear", "software fade curve. One of: "+easingNames())
+		cmd.Flags().DurationVar(&fadeIntervalArg, "fade-interval", defaultFadeInterval, "interval between software fade steps")
+	}
+
+	getFader := func() (*Fader, error) {
+		rpcClient, err := callback.RPC()
+		if err != nil {
+			return nil, err
+		}
+		return NewFader(rpcClient, "light", transitionArg, softwareFadeArg, easingArg, fadeIntervalArg)
+	}
 
-	getSwitchID := func() (*int, error) {
+	// run executes action for each light selected by the id arg and writes
+	// the per light results. The status is read with the same client so that
+	// the device authenticates the command only once.
+	run := func(ctx context.Context, action func(ctx context.Context, client *rpc.Client, switchID int) error) error {
 
-		if switchIDArg == "" {
-			return nil, fmt.Errorf("switchID is required")
+		rpcClient, err := callback.RPC()
+		if err != nil {
+			return err
 		}
 
-		switchID, err := strconv.Atoi(switchIDArg)
-		if err == nil {
-			return &switchID, nil
+		switchIDs, err := channel.ParseIDs(ctx, rpcClient, component, switchIDArg)
+		if err != nil {
+			return err
 		}
 
-		return nil, fmt.Errorf("switchID must be an integer")
+		results, runErr := channel.Run(ctx, rpcClient, component, switchIDs, func(ctx context.Context, switchID int) error {
+			return action(ctx, rpcClient, switchID)
+		})
 
+		err = callback.WriteStdout(results)
+		if err != nil {
+			return err
+		}
+
+		return runErr
+	}
+
+	// adjust changes the brightness of each selected light by delta relative
+	// to its current brightness. Dimming leaves lights that are off alone and
+	// stops at the minimum brightness; brightening a light that is off turns
+	// it on at delta.
+	adjust := func(ctx context.Context, delta float64) error {
+
+		if stepArg <= 0 || stepArg > 100 {
+			return fmt.Errorf("step must be greater than 0 and at most 100")
+		}
+
+		f, err := getFader()
+		if err != nil {
+			return err
+		}
+
+		return run(ctx, func(ctx context.Context, client *rpc.Client, switchID int) error {
+
+			state, err := channel.GetState(ctx, f.client, component, switchID)
+			if err != nil {
+				return err
+			}
+
+			isOn := state.Output != nil && *state.Output
+
+			if !isOn && delta < 0 {
+				return nil
+			}
+
+			current := 0.0
+			if isOn && state.Brightness != nil {
+				current = *state.Brightness
+			}
+
+			return f.Set(ctx, switchID, clampBrightness(current+delta), !isOn)
+		})
 	}
 
 	rootCmd := &cobra.Command{
@@ -42,24 +122,15 @@ func NewCmd(callback callback) *cobra.Command {
 		Short: "Turn light on, off, or set brigtness level",
 	}
 
-	rootCmd.PersistentFlags().StringVar(&switchIDArg, "id", "", "switch ID integer")
+	rootCmd.PersistentFlags().StringVar(&switchIDArg, "id", "", "light IDs; comma separated integers, ranges (0-2), names or 'all'")
 
 	setOnCmd := &cobra.Command{
 		Use:   "on",
 		Short: "Turn light on",
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			switchID, err := getSwitchID()
-			if err != nil {
-				return err
-			}
-
-			client, err := callback.Light()
-			if err != nil {
-				return err
-			}
-
-			return client.Set(cmd.Context(), *switchID, &truex, nil)
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, switchID int) error {
+				return client.Call(ctx, "Light.Set", &lightSetParams{ID: switchID, On: &truex}, nil)
+			})
 		},
 	}
 
@@ -67,70 +138,133 @@ func NewCmd(callback callback) *cobra.Command {
 		Use:   "off",
 		Short: "Turn light off",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, switchID int) error {
+				return client.Call(ctx, "Light.Set", &lightSetParams{ID: switchID, On: &falsex}, nil)
+			})
+		},
+	}
 
-			switchID, err := getSwitchID()
+	setBrightnessCmd := &cobra.Command{
+		Use:   "bright <0-100>",
+		Short: "Sets light brightness",
+		Long:  "Sets light brightness. Whether the light is on or off is not changed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			brightness, err := parseBrightness(args[0])
 			if err != nil {
 				return err
 			}
 
-			client, err := callback.Light()
+			f, err := getFader()
 			if err != nil {
 				return err
 			}
 
-			return client.Set(cmd.Context(), *switchID, &falsex, nil)
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, switchID int) error {
+				return f.Set(ctx, switchID, brightness, false)
+			})
 		},
 	}
 
-	setBrightnessCmd := &cobra.Command{
-		Use:   "bright",
-		Short: "Sets light brightness",
+	addFadeFlags(setBrightnessCmd)
+
+	dimCmd := &cobra.Command{
+		Use:   "dim",
+		Short: "Decreases light brightness by step",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return adjust(cmd.Context(), -stepArg)
+		},
+	}
+
+	addFadeFlags(dimCmd)
+	dimCmd.Flags().Float64Var(&stepArg, "step", 10, "brightness percentage to decrease by")
+
+	brightenCmd := &cobra.Command{
+		Use:   "brighten",
+		Short: "Increases light brightness by step",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			return adjust(cmd.Context(), stepArg)
+		},
+	}
+
+	addFadeFlags(brightenCmd)
+	brightenCmd.Flags().Float64Var(&stepArg, "step", 10, "brightness percentage to increase by")
+
+	var nightModeEnableArg bool
+	var nightModeBrightnessArg string
+	var nightModeActiveBetweenArg []string
+
+	nightModeCmd := &cobra.Command{
+		Use:   "night-mode",
+		Short: "Configures night mode brightness and active hours",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			nightMode := &nightModeConfig{}
+
+			if cmd.Flags().Changed("enable") {
+				nightMode.Enable = &nightModeEnableArg
+			}
 
-			switchID, err := getSwitchID()
+			if nightModeBrightnessArg != "" {
+				brightness, err := parseBrightness(nightModeBrightnessArg)
+				if err != nil {
+					return err
+				}
+				nightMode.Brightness = &brightness
+			}
+
+			if len(nightModeActiveBetweenArg) > 0 {
+				if len(nightModeActiveBetweenArg) != 2 {
+					return fmt.Errorf("active-between expects a start and end time such as 22:00,06:00")
+				}
+				for _, s := range nightModeActiveBetweenArg {
+					_, err := time.Parse("15:04", s)
+					if err != nil {
+						return fmt.Errorf("time %s is invalid; expect HH:MM", s)
+					}
+				}
+				nightMode.ActiveBetween = nightModeActiveBetweenArg
+			}
+
+			rpcClient, err := callback.RPC()
 			if err != nil {
 				return err
 			}
 
-			client, err := callback.Light()
+			switchIDs, err := channel.ParseIDs(cmd.Context(), rpcClient, component, switchIDArg)
 			if err != nil {
 				return err
 			}
 
-			brightness := 0.0
-
-			if len(args) > 0 {
-				arg := args[0]
-				f, err := strconv.ParseFloat(arg, 8)
+			for _, switchID := range switchIDs {
+				err := rpcClient.Call(cmd.Context(), "Light.SetConfig", map[string]any{
+					"id":     switchID,
+					"config": map[string]any{"night_mode": nightMode},
+				}, nil)
 				if err != nil {
-					return fmt.Errorf("arg %s is not a valid float", arg)
+					return fmt.Errorf("%s %d: %w", component, switchID, err)
 				}
-				brightness = f
 			}
 
-			return client.Set(cmd.Context(), *switchID, nil, &brightness)
+			return nil
 		},
 	}
 
+	nightModeCmd.Flags().BoolVar(&nightModeEnableArg, "enable", false, "enable night mode")
+	nightModeCmd.Flags().StringVar(&nightModeBrightnessArg, "brightness", "", "brightness (0-100) used when turned on during night mode")
+	nightModeCmd.Flags().StringSliceVar(&nightModeActiveBetweenArg, "active-between", nil, "start and end time of night mode such as 22:00,06:00")
+
 	toggleCmd := &cobra.Command{
 		Use:   "toggle",
-		Short: "Toggles switch",
+		Short: "Toggles light",
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			switchID, err := getSwitchID()
-			if err != nil {
-				return err
-			}
-
-			client, err := callback.Light()
-			if err != nil {
-				return err
-			}
-
-			return client.Toggle(cmd.Context(), *switchID)
+			return run(cmd.Context(), func(ctx context.Context, client *rpc.Client, switchID int) error {
+				return client.Call(ctx, "Light.Toggle", map[string]int{"id": switchID}, nil)
+			})
 		},
 	}
 
-	rootCmd.AddCommand(toggleCmd, setOnCmd, setOffCmd, setBrightnessCmd)
+	rootCmd.AddCommand(toggleCmd, setOnCmd, setOffCmd, setBrightnessCmd, dimCmd, brightenCmd, nightModeCmd)
 	return rootCmd
 }
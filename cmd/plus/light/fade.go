@@ -0,0 +1,187 @@
+package light
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const defaultFadeInterval = 100 * time.Millisecond
+
+type easing func(float64) float64
+
+var easings = map[string]easing{
+	"linear": func(x float64) float64 {
+		return x
+	},
+	"ease-in": func(x float64) float64 {
+		return x * x
+	},
+	"ease-out": func(x float64) float64 {
+		return 1 - (1-x)*(1-x)
+	},
+	"ease-in-out": func(x float64) float64 {
+		return (1 - math.Cos(math.Pi*x)) / 2
+	},
+}
+
+func easingNames() string {
+	return "linear | ease-in | ease-out | ease-in-out"
+}
+
+// parseBrightness parses a brightness percentage such as 40 or 40%
+func parseBrightness(arg string) (float64, error) {
+
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(arg), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("brightness %s is not a valid number", arg)
+	}
+
+	if f < 0 || f > 100 {
+		return 0, fmt.Errorf("brightness %s is out of range; expect 0 to 100", arg)
+	}
+
+	return f, nil
+}
+
+// clampBrightness limits f to 1-100. Lights are dimmed to the minimum rather
+// than to 0 which would leave them on but dark.
+func clampBrightness(f float64) float64 {
+	return math.Max(1, math.Min(100, f))
+}
+
+type lightSetParams struct {
+	ID                 int      `json:"id"`
+	On                 *bool    `json:"on,omitempty"`
+	Brightness         *float64 `json:"brightness,omitempty"`
+	TransitionDuration *float64 `json:"transition_duration,omitempty"`
+}
+
+// Fader sets the brightness of a light, rgb, rgbw or cct component either
+// instantly, with the firmware transition or with a software fade
+type Fader struct {
+	client     *rpc.Client
+	component  string
+	transition time.Duration
+	software   bool
+	easing     easing
+	interval   time.Duration
+}
+
+// NewFader returns a fader for the components of type component. The
+// interval defaults to 100ms.
+func NewFader(client *rpc.Client, component string, transition time.Duration, software bool, easingName string, interval time.Duration) (*Fader, error) {
+
+	e, ok := easings[easingName]
+	if !ok {
+		return nil, fmt.Errorf("easing %s is unknown; expect one of %s", easingName, easingNames())
+	}
+
+	if transition < 0 {
+		return nil, fmt.Errorf("transition must not be negative")
+	}
+
+	if interval <= 0 {
+		interval = defaultFadeInterval
+	}
+
+	return &Fader{
+		client:     client,
+		component:  component,
+		transition: transition,
+		software:   software,
+		easing:     e,
+		interval:   interval,
+	}, nil
+}
+
+// Set sets the brightness of component id to target. If turnOn is set the light is
+// switched on as well, otherwise its output is left as it is.
+func (t *Fader) Set(ctx context.Context, id int, target float64, turnOn bool) error {
+
+	var on *bool
+	if turnOn {
+		on = &truex
+	}
+
+	if t.transition == 0 {
+		return t.client.Call(ctx, rpc.Method(t.component, "Set"), &lightSetParams{ID: id, On: on, Brightness: &target}, nil)
+	}
+
+	if !t.software {
+
+		seconds := t.transition.Seconds()
+
+		err := t.client.Call(ctx, rpc.Method(t.component, "Set"), &lightSetParams{ID: id, On: on, Brightness: &target, TransitionDuration: &seconds}, nil)
+
+		var rpcErr *rpc.Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != rpc.InvalidArgument {
+			return err
+		}
+
+		// Firmware does not support transition_duration
+	}
+
+	return t.fade(ctx, id, target, on)
+}
+
+func (t *Fader) fade(ctx context.Context, id int, target float64, on *bool) error {
+
+	status := &struct {
+		Output     bool    `json:"output"`
+		Brightness float64 `json:"brightness"`
+	}{}
+
+	err := t.client.Call(ctx, rpc.Method(t.component, "GetStatus"), map[string]int{"id": id}, status)
+	if err != nil {
+		return err
+	}
+
+	start := status.Brightness
+	if !status.Output {
+		if on == nil {
+			// Nothing to fade on a light that stays off
+			return t.client.Call(ctx, rpc.Method(t.component, "Set"), &lightSetParams{ID: id, Brightness: &target}, nil)
+		}
+		start = 0
+	}
+
+	steps := int(t.transition / t.interval)
+	if steps < 1 {
+		steps = 1
+	}
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for step := 1; step <= steps; step++ {
+
+		level := target
+		if step < steps {
+			level = math.Round(start + (target-start)*t.easing(float64(step)/float64(steps)))
+		}
+
+		err := t.client.Call(ctx, rpc.Method(t.component, "Set"), &lightSetParams{ID: id, On: on, Brightness: &level}, nil)
+		if err != nil {
+			return err
+		}
+
+		if step == steps {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
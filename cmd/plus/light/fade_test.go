@@ -0,0 +1,191 @@
+package light
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// fakeLight is a device with a single light that records the Light.Set params
+type fakeLight struct {
+	output       bool
+	brightness   float64
+	noTransition bool
+	sets         []map[string]any
+	client       *rpc.Client
+}
+
+func newFakeLight(t *testing.T, output bool, brightness float64) *fakeLight {
+
+	f := &fakeLight{output: output, brightness: brightness}
+
+	f.client = testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+
+		switch method {
+
+		case "Light.GetStatus":
+			return map[string]any{"output": f.output, "brightness": f.brightness}, nil
+
+		case "Light.Set":
+			if _, ok := params["transition_duration"]; ok && f.noTransition {
+				return nil, &rpc.Error{Code: -103, Message: "invalid argument"}
+			}
+			f.sets = append(f.sets, params)
+			if on, ok := params["on"].(bool); ok {
+				f.output = on
+			}
+			if b, ok := params["brightness"].(float64); ok {
+				f.brightness = b
+			}
+			return nil, nil
+		}
+
+		return nil, testdevice.NotFound(method)
+	}}).Client()
+
+	return f
+}
+
+func TestParseBrightness(t *testing.T) {
+
+	tests := map[string]float64{
+		"0":    0,
+		"40":   40,
+		"40%":  40,
+		" 55 ": 55,
+		"12.5": 12.5,
+		"100":  100,
+	}
+
+	for arg, want := range tests {
+		got, err := parseBrightness(arg)
+		if err != nil || got != want {
+			t.Errorf("parseBrightness(%q) = %v, %v; want %v", arg, got, err, want)
+		}
+	}
+
+	for _, arg := range []string{"", "abc", "-1", "101", "50%%"} {
+		if _, err := parseBrightness(arg); err == nil {
+			t.Errorf("parseBrightness(%q) expected an error", arg)
+		}
+	}
+}
+
+func TestClampBrightness(t *testing.T) {
+
+	tests := map[float64]float64{
+		-20: 1,
+		0:   1,
+		1:   1,
+		50:  50,
+		100: 100,
+		140: 100,
+	}
+
+	for in, want := range tests {
+		if got := clampBrightness(in); got != want {
+			t.Errorf("clampBrightness(%v) = %v; want %v", in, got, want)
+		}
+	}
+}
+
+func TestEasings(t *testing.T) {
+	for name, e := range easings {
+		if e(0) != 0 || e(1) != 1 {
+			t.Errorf("easing %s does not start at 0 and end at 1", name)
+		}
+	}
+}
+
+func TestFaderSetOn(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		turnOn bool
+		wantOn any
+	}{
+		{name: "brightness only", turnOn: false, wantOn: nil},
+		{name: "turn on", turnOn: true, wantOn: true},
+	}
+
+	for _, test := range tests {
+
+		light := newFakeLight(t, false, 30)
+
+		f, err := NewFader(light.client, "light", 0, false, "linear", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Set(context.Background(), 0, 60, test.turnOn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(light.sets) != 1 {
+			t.Fatalf("%s: got %d Light.Set calls; want 1", test.name, len(light.sets))
+		}
+
+		if on := light.sets[0]["on"]; on != test.wantOn {
+			t.Errorf("%s: on = %v; want %v", test.name, on, test.wantOn)
+		}
+	}
+}
+
+func TestFaderSoftwareFallback(t *testing.T) {
+
+	light := newFakeLight(t, true, 0)
+	light.noTransition = true
+
+	f, err := NewFader(light.client, "light", 50*time.Millisecond, false, "linear", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.Set(context.Background(), 0, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(light.sets) < 2 {
+		t.Fatalf("got %d Light.Set calls; want a stepped fade", len(light.sets))
+	}
+
+	previous := 0.0
+	for _, set := range light.sets {
+		if _, ok := set["on"]; ok {
+			t.Errorf("fade of a light that is on sent on: %v", set)
+		}
+		b := set["brightness"].(float64)
+		if b < previous {
+			t.Errorf("brightness decreased from %v to %v", previous, b)
+		}
+		previous = b
+	}
+
+	if light.brightness != 100 {
+		t.Errorf("final brightness = %v; want 100", light.brightness)
+	}
+}
+
+func TestFaderSoftwareFadeOfLightThatIsOff(t *testing.T) {
+
+	light := newFakeLight(t, false, 20)
+
+	f, err := NewFader(light.client, "light", 50*time.Millisecond, true, "linear", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.Set(context.Background(), 0, 80, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(light.sets) != 1 || light.output {
+		t.Errorf("expected a single brightness change of a light that stays off; got %v", light.sets)
+	}
+}
@@ -0,0 +1,194 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// sslCAs maps the ssl-ca flag to the ssl_ca config value
+var sslCAs = map[string]any{
+	"none":    nil,
+	"default": "ca.pem",
+	"user":    "user_ca.pem",
+	"skip":    "*",
+}
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	RPC() (*rpc.Client, error)
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "mqtt",
+		Short: "MQTT Component",
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "MQTT.GetConfig", nil)
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status; whether the device is connected to the broker",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return callback.CallAndWrite(cmd.Context(), "MQTT.GetStatus", nil)
+		},
+	}
+
+	var enableArg bool
+	var serverArg string
+	var clientIDArg string
+	var userArg string
+	var passArg string
+	var sslCAArg string
+	var topicPrefixArg string
+	var rpcNtfArg bool
+	var statusNtfArg bool
+	var useClientCertArg bool
+	var enableRPCArg bool
+	var enableControlArg bool
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("enable") {
+				config["enable"] = enableArg
+			}
+
+			if flags.Changed("server") {
+				config["server"] = serverArg
+			}
+
+			if flags.Changed("client-id") {
+				config["client_id"] = clientIDArg
+			}
+
+			if flags.Changed("user") {
+				config["user"] = userArg
+			}
+
+			if flags.Changed("pass") {
+				config["pass"] = passArg
+			}
+
+			if flags.Changed("ssl-ca") {
+				sslCA, ok := sslCAs[sslCAArg]
+				if !ok {
+					return fmt.Errorf("ssl-ca %s is invalid; expect none, default, user or skip", sslCAArg)
+				}
+				config["ssl_ca"] = sslCA
+			}
+
+			if flags.Changed("topic-prefix") {
+				if topicPrefixArg != "" && (topicPrefixArg[0] == '$' || strings.ContainsAny(topicPrefixArg, "#+")) {
+					return fmt.Errorf("topic-prefix %s is invalid; it must not start with $ or contain # or +", topicPrefixArg)
+				}
+				config["topic_prefix"] = topicPrefixArg
+			}
+
+			if flags.Changed("rpc-ntf") {
+				config["rpc_ntf"] = rpcNtfArg
+			}
+
+			if flags.Changed("status-ntf") {
+				config["status_ntf"] = statusNtfArg
+			}
+
+			if flags.Changed("use-client-cert") {
+				config["use_client_cert"] = useClientCertArg
+			}
+
+			if flags.Changed("enable-rpc") {
+				config["enable_rpc"] = enableRPCArg
+			}
+
+			if flags.Changed("enable-control") {
+				config["enable_control"] = enableControlArg
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "MQTT.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().BoolVar(&enableArg, "enable", false, "enable MQTT")
+	setConfigCmd.Flags().StringVar(&serverArg, "server", "", "broker host:port")
+	setConfigCmd.Flags().StringVar(&clientIDArg, "client-id", "", "client ID; defaults to the device ID")
+	setConfigCmd.Flags().StringVar(&userArg, "user", "", "broker username")
+	setConfigCmd.Flags().StringVar(&passArg, "pass", "", "broker password")
+	setConfigCmd.Flags().StringVar(&sslCAArg, "ssl-ca", "", "TLS CA. One of: none (no TLS) | default | user | skip (no verification)")
+	setConfigCmd.Flags().StringVar(&topicPrefixArg, "topic-prefix", "", "topic prefix; defaults to the device ID")
+	setConfigCmd.Flags().BoolVar(&rpcNtfArg, "rpc-ntf", false, "publish RPC notifications to <prefix>/events/rpc")
+	setConfigCmd.Flags().BoolVar(&statusNtfArg, "status-ntf", false, "publish status notifications to <prefix>/status/<component>")
+	setConfigCmd.Flags().BoolVar(&useClientCertArg, "use-client-cert", false, "authenticate with the user client certificate")
+	setConfigCmd.Flags().BoolVar(&enableRPCArg, "enable-rpc", false, "accept RPC requests on <prefix>/rpc")
+	setConfigCmd.Flags().BoolVar(&enableControlArg, "enable-control", false, "accept control commands on <prefix>/command")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	verifyConfig := &verifyConfig{notify: callback.WriteStderr}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Subscribes to the device topic prefix on the broker and confirms that messages arrive",
+		Long: "Subscribes to the device topic prefix on the broker and confirms that messages arrive. If RPC over " +
+			"MQTT is enabled a request is published and the response awaited. TLS brokers must present a " +
+			"certificate trusted locally unless --insecure is set.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			result, verifyErr := verify(cmd.Context(), client, verifyConfig)
+
+			if result != nil {
+				err = callback.WriteStdout(result)
+				if err != nil {
+					return err
+				}
+			}
+
+			return verifyErr
+		},
+	}
+
+	verifyCmd.Flags().StringVar(&verifyConfig.broker, "broker", "", "broker host:port or URL; defaults to the server configured on the device")
+	verifyCmd.Flags().StringVar(&verifyConfig.user, "user", "", "broker username; defaults to the user configured on the device")
+	verifyCmd.Flags().StringVar(&verifyConfig.pass, "pass", "", "broker password")
+	verifyCmd.Flags().DurationVar(&verifyConfig.timeout, "timeout", 10*time.Second, "maximum time to wait for messages")
+	verifyCmd.Flags().BoolVar(&verifyConfig.insecure, "insecure", false, "skip verification of the broker TLS certificate")
+
+	rootCmd.AddCommand(getConfigCmd, setConfigCmd, statusCmd, verifyCmd)
+	return rootCmd
+}
@@ -0,0 +1,241 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// mqttConfig is the subset of MQTT.GetConfig used by verify
+type mqttConfig struct {
+	Enable      bool    `json:"enable"`
+	Server      *string `json:"server"`
+	User        *string `json:"user"`
+	SSLCA       *string `json:"ssl_ca"`
+	TopicPrefix *string `json:"topic_prefix"`
+	EnableRPC   bool    `json:"enable_rpc"`
+}
+
+// VerifyResult is the result of verify
+type VerifyResult struct {
+	Broker          string   `json:"broker" yaml:"broker"`
+	Topic           string   `json:"topic" yaml:"topic"`
+	DeviceConnected bool     `json:"device_connected" yaml:"device_connected"`
+	Messages        int      `json:"messages" yaml:"messages"`
+	Topics          []string `json:"topics,omitempty" yaml:"topics,omitempty"`
+	RPCResponse     bool     `json:"rpc_response" yaml:"rpc_response"`
+}
+
+type verifyConfig struct {
+	broker   string
+	user     string
+	pass     string
+	timeout  time.Duration
+	insecure bool
+	notify   func(string)
+}
+
+// verify subscribes to the topic prefix of the device on its configured broker
+// (or config.broker) and waits until messages arrive or the timeout expires. If
+// RPC over MQTT is enabled a Shelly.GetDeviceInfo request is published and the
+// response awaited so that both directions are verified.
+func verify(ctx context.Context, client *rpc.Client, config *verifyConfig) (*VerifyResult, error) {
+
+	deviceConfig := &mqttConfig{}
+
+	err := client.Call(ctx, "MQTT.GetConfig", nil, deviceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deviceConfig.Enable {
+		return nil, fmt.Errorf("MQTT is not enabled on the device")
+	}
+
+	status := &struct {
+		Connected bool `json:"connected"`
+	}{}
+
+	err = client.Call(ctx, "MQTT.GetStatus", nil, status)
+	if err != nil {
+		return nil, err
+	}
+
+	if !status.Connected {
+		config.notify("device reports that it is not connected to the broker")
+	}
+
+	deviceInfo := &struct {
+		ID string `json:"id"`
+	}{}
+
+	err = client.Call(ctx, "Shelly.GetDeviceInfo", nil, deviceInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := deviceInfo.ID
+	if deviceConfig.TopicPrefix != nil && *deviceConfig.TopicPrefix != "" {
+		prefix = *deviceConfig.TopicPrefix
+	}
+
+	broker := config.broker
+	if broker == "" {
+		if deviceConfig.Server == nil || *deviceConfig.Server == "" {
+			return nil, fmt.Errorf("device has no MQTT server configured; use --broker")
+		}
+		broker = *deviceConfig.Server
+	}
+
+	broker = brokerURL(broker, deviceConfig.SSLCA != nil)
+
+	user := config.user
+	if user == "" && deviceConfig.User != nil {
+		user = *deviceConfig.User
+	}
+
+	result := &VerifyResult{
+		Broker:          broker,
+		Topic:           prefix + "/#",
+		DeviceConnected: status.Connected,
+	}
+
+	clientID := fmt.Sprintf("shelly-cli-%d", time.Now().UnixNano())
+	requestTopic := prefix + "/rpc"
+	responseTopic := clientID + "/rpc"
+
+	var mutex sync.Mutex
+	topics := make(map[string]bool)
+	done := make(chan struct{})
+	var once sync.Once
+
+	handler := func(_ paho.Client, message paho.Message) {
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch message.Topic() {
+		case requestTopic:
+			// our own request
+		case responseTopic:
+			result.RPCResponse = true
+		default:
+			result.Messages++
+			topics[message.Topic()] = true
+		}
+
+		if result.Messages > 0 && (result.RPCResponse || !deviceConfig.EnableRPC) {
+			once.Do(func() { close(done) })
+		}
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(user).
+		SetPassword(config.pass).
+		SetConnectTimeout(config.timeout).
+		SetAutoReconnect(false)
+
+	useTLS := strings.HasPrefix(broker, "ssl://")
+
+	if useTLS && config.insecure {
+		options.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	}
+
+	mqttClient := paho.NewClient(options)
+
+	token := mqttClient.Connect()
+	if !token.WaitTimeout(config.timeout) {
+		return nil, fmt.Errorf("connection to broker %s timed out", broker)
+	}
+
+	if token.Error() != nil {
+		if useTLS && !config.insecure {
+			return nil, fmt.Errorf("connection to broker %s failed: %w; use --insecure if the broker certificate is not trusted locally", broker, token.Error())
+		}
+		return nil, fmt.Errorf("connection to broker %s failed: %w", broker, token.Error())
+	}
+
+	defer mqttClient.Disconnect(250)
+
+	for _, topic := range []string{result.Topic, responseTopic} {
+		token = mqttClient.Subscribe(topic, 0, handler)
+		if !token.WaitTimeout(config.timeout) || token.Error() != nil {
+			return nil, fmt.Errorf("subscribe to %s failed: %v", topic, token.Error())
+		}
+	}
+
+	if deviceConfig.EnableRPC {
+
+		request, _ := json.Marshal(map[string]any{
+			"id":     1,
+			"src":    clientID,
+			"method": "Shelly.GetDeviceInfo",
+		})
+
+		token = mqttClient.Publish(requestTopic, 0, false, request)
+		if !token.WaitTimeout(config.timeout) || token.Error() != nil {
+			return nil, fmt.Errorf("publish to %s failed: %v", requestTopic, token.Error())
+		}
+	}
+
+	timer := time.NewTimer(config.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for topic := range topics {
+		result.Topics = append(result.Topics, topic)
+	}
+
+	sort.Strings(result.Topics)
+
+	if result.Messages == 0 {
+		return result, fmt.Errorf("no messages received on %s within %s", result.Topic, config.timeout)
+	}
+
+	if deviceConfig.EnableRPC && !result.RPCResponse {
+		return result, fmt.Errorf("no RPC response received on %s within %s", responseTopic, config.timeout)
+	}
+
+	return result, nil
+}
+
+// brokerURL returns server as a broker URL. The port defaults to 1883 or to
+// 8883 with TLS.
+func brokerURL(server string, useTLS bool) string {
+
+	if strings.Contains(server, "://") {
+		return server
+	}
+
+	scheme, port := "tcp", "1883"
+	if useTLS {
+		scheme, port = "ssl", "8883"
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, port)
+	}
+
+	return scheme + "://" + server
+}
@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const devicePrefix = "shellyplus1-test"
+
+// newBroker starts a fake broker for a device with devicePrefix and returns
+// its host:port. If online is set a subscription to the device topics
+// receives a retained online message, and if answerRPC is set RPC requests
+// published to <prefix>/rpc are answered as the device would.
+func newBroker(t *testing.T, online, answerRPC bool) string {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveBroker(conn, online, answerRPC)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// serveBroker serves one client connection of the fake broker
+func serveBroker(conn net.Conn, online, answerRPC bool) {
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	publish := func(topic string, payload []byte) error {
+		p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		p.TopicName = topic
+		p.Payload = payload
+		return p.Write(conn)
+	}
+
+	for {
+
+		packet, err := packets.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch p := packet.(type) {
+
+		case *packets.ConnectPacket:
+			err = packets.NewControlPacket(packets.Connack).Write(conn)
+
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = make([]byte, len(p.Topics))
+			err = suback.Write(conn)
+			for _, topic := range p.Topics {
+				if err == nil && online && topic == devicePrefix+"/#" {
+					err = publish(devicePrefix+"/online", []byte("true"))
+				}
+			}
+
+		case *packets.PublishPacket:
+			if answerRPC && p.TopicName == devicePrefix+"/rpc" {
+				var request struct {
+					ID  int    `json:"id"`
+					Src string `json:"src"`
+				}
+				json.Unmarshal(p.Payload, &request)
+				response, _ := json.Marshal(map[string]any{"id": request.ID, "src": devicePrefix, "result": map[string]any{"id": devicePrefix}})
+				err = publish(request.Src+"/rpc", response)
+			}
+
+		case *packets.PingreqPacket:
+			err = packets.NewControlPacket(packets.Pingresp).Write(conn)
+
+		case *packets.DisconnectPacket:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// newDevice returns a client for a fake device with MQTT configured for
+// server
+func newDevice(t *testing.T, server string, rpcOverMQTT bool) *rpc.Client {
+
+	results := map[string]any{
+		"MQTT.GetConfig":       map[string]any{"enable": true, "server": server, "enable_rpc": rpcOverMQTT},
+		"MQTT.GetStatus":       map[string]any{"connected": rpcOverMQTT},
+		"Shelly.GetDeviceInfo": map[string]any{"id": devicePrefix},
+	}
+
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		result, ok := results[method]
+		if !ok {
+			return nil, testdevice.NotFound(method)
+		}
+		return result, nil
+	}})
+
+	return device.Client()
+}
+
+func TestVerify(t *testing.T) {
+
+	server := newBroker(t, true, true)
+
+	var notes []string
+	config := &verifyConfig{timeout: 2 * time.Second, notify: func(s string) { notes = append(notes, s) }}
+
+	result, err := verify(context.Background(), newDevice(t, server, true), config)
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	if result.Broker != "tcp://"+server || result.Topic != devicePrefix+"/#" || !result.RPCResponse {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if result.Messages != 1 || len(result.Topics) != 1 || result.Topics[0] != devicePrefix+"/online" {
+		t.Errorf("got messages %d on %v; want the retained online message", result.Messages, result.Topics)
+	}
+
+	if len(notes) != 0 {
+		t.Errorf("unexpected notes %v", notes)
+	}
+
+	// The device is not connected and nothing is published
+	config.timeout = 200 * time.Millisecond
+
+	result, err = verify(context.Background(), newDevice(t, newBroker(t, false, false), false), config)
+	if err == nil || !strings.Contains(err.Error(), "no messages received") {
+		t.Errorf("error = %v; want no messages received", err)
+	}
+
+	if result == nil || result.DeviceConnected || len(notes) != 1 {
+		t.Errorf("got result %+v and notes %v; want a disconnected device and a note", result, notes)
+	}
+}
+
+func TestBrokerURL(t *testing.T) {
+
+	tests := []struct {
+		server string
+		useTLS bool
+		want   string
+	}{
+		{server: "mqtt.local", want: "tcp://mqtt.local:1883"},
+		{server: "mqtt.local", useTLS: true, want: "ssl://mqtt.local:8883"},
+		{server: "mqtt.local:1884", want: "tcp://mqtt.local:1884"},
+		{server: "10.0.0.2:8884", useTLS: true, want: "ssl://10.0.0.2:8884"},
+		{server: "ws://mqtt.local:80", useTLS: true, want: "ws://mqtt.local:80"},
+	}
+
+	for _, test := range tests {
+		if got := brokerURL(test.server, test.useTLS); got != test.want {
+			t.Errorf("brokerURL(%q, %v) = %s; want %s", test.server, test.useTLS, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var scheduleIDArg string
+
+	var timespecArg string
+	var enableArg bool
+	var methodArg string
+	var paramsArg string
+
+	// getJob returns the job from the flags. If method is not set the job is read
+	// from file / STDIN and the timespec and enable flags override it.
+	getJob := func(cmd *cobra.Command) (*Job, error) {
+
+		flags := cmd.Flags()
+		job := &Job{}
+
+		if flags.Changed("method") {
+
+			call := &Call{Method: methodArg}
+
+			if paramsArg != "" {
+				err := json.Unmarshal([]byte(paramsArg), &call.Params)
+				if err != nil {
+					return nil, fmt.Errorf("params must be a JSON object: %w", err)
+				}
+			}
+
+			job.Calls = []*Call{call}
+
+		} else {
+
+			err := callback.ReadConfigFile(job)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if flags.Changed("timespec") {
+			job.Timespec = timespecArg
+		}
+
+		if flags.Changed("enable") {
+			job.Enable = &enableArg
+		}
+
+		return job, job.validate()
+	}
+
+	addJobFlags := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&timespecArg, "timespec", "", "timespec such as '0 30 7 * * MON-FRI' or '@sunset-30m * * *'")
+		cmd.Flags().BoolVar(&enableArg, "enable", true, "enable the schedule")
+		cmd.Flags().StringVar(&methodArg, "method", "", "method to call such as Switch.Set; if not set calls are read from file / STDIN")
+		cmd.Flags().StringVar(&paramsArg, "params", "", "method params as a JSON object such as '{\"id\":0,\"on\":true}'")
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Schedule management",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists schedules; the YAML output can be used with apply",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			jobs, err := list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(&Schedules{Schedules: jobs})
+		},
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Creates a schedule from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			job, err := getJob(cmd)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Schedule.Create", jobParams(nil, job), &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	addJobFlags(createCmd)
+
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Replaces a schedule with one from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scheduleID, err := channel.ParseID("schedule", scheduleIDArg)
+			if err != nil {
+				return err
+			}
+
+			job, err := getJob(cmd)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Schedule.Update", jobParams(&scheduleID, job), &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	updateCmd.Flags().StringVar(&scheduleIDArg, "id", "", "schedule ID integer")
+	addJobFlags(updateCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Deletes a schedule",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scheduleID, err := channel.ParseID("schedule", scheduleIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "Schedule.Delete", map[string]int{"id": scheduleID}, nil)
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&scheduleIDArg, "id", "", "schedule ID integer")
+
+	deleteAllCmd := &cobra.Command{
+		Use:   "delete-all",
+		Short: "Deletes all schedules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "Schedule.DeleteAll", nil, nil)
+		},
+	}
+
+	var pruneArg bool
+	var dryRunArg bool
+
+	// getChanges plans the changes needed to apply the schedules file
+	getChanges := func(cmd *cobra.Command) (*rpc.Client, Changes, error) {
+
+		desired := &Schedules{}
+
+		err := callback.ReadConfigFile(desired)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		client, err := callback.RPC()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		current, err := list(cmd.Context(), client)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		changes, err := plan(current, desired.Schedules, pruneArg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return client, changes, nil
+	}
+
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Applies the schedules from file / STDIN and returns the changes",
+		Long: "Applies the schedules from file / STDIN and returns the changes. Schedules with an id update that " +
+			"schedule. Others are matched to an identical schedule or one with the same timespec, else created. " +
+			"Schedules on the device that are not in the file are deleted with --prune.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, changes, err := getChanges(cmd)
+			if err != nil {
+				return err
+			}
+
+			if !dryRunArg {
+				err = apply(cmd.Context(), client, changes)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.WriteStdout(changes)
+		},
+	}
+
+	applyCmd.Flags().BoolVar(&pruneArg, "prune", false, "delete schedules that are not in the file")
+	applyCmd.Flags().BoolVar(&dryRunArg, "dry-run", false, "only return the changes")
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Returns the changes apply would make",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			_, changes, err := getChanges(cmd)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(changes)
+		},
+	}
+
+	diffCmd.Flags().BoolVar(&pruneArg, "prune", false, "include schedules that are not in the file as deletes")
+
+	rootCmd.AddCommand(listCmd, createCmd, updateCmd, deleteCmd, deleteAllCmd, applyCmd, diffCmd)
+	return rootCmd
+}
@@ -0,0 +1,297 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// maxJobs is the number of schedule jobs a device supports
+const maxJobs = 20
+
+// Call is an RPC call made when a job fires
+type Call struct {
+	Method string         `json:"method" yaml:"method"`
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// Job is a schedule job. ID is assigned by the device. Enable defaults to true
+// if it is not set.
+type Job struct {
+	ID       *int    `json:"id,omitempty" yaml:"id,omitempty"`
+	Enable   *bool   `json:"enable,omitempty" yaml:"enable,omitempty"`
+	Timespec string  `json:"timespec" yaml:"timespec"`
+	Calls    []*Call `json:"calls" yaml:"calls"`
+}
+
+func (t *Job) enabled() bool {
+	return t.Enable == nil || *t.Enable
+}
+
+func (t *Job) validate() error {
+
+	err := validateTimespec(t.Timespec)
+	if err != nil {
+		return err
+	}
+
+	if len(t.Calls) == 0 {
+		return fmt.Errorf("timespec %q: at least one call is required", t.Timespec)
+	}
+
+	for _, call := range t.Calls {
+		if !strings.Contains(call.Method, ".") {
+			return fmt.Errorf("timespec %q: method %q is invalid; expect Component.Method", t.Timespec, call.Method)
+		}
+	}
+
+	return nil
+}
+
+func (t *Job) methods() string {
+	var methods []string
+	for _, call := range t.Calls {
+		methods = append(methods, call.Method)
+	}
+	return strings.Join(methods, ",")
+}
+
+// equal returns true if the jobs have the same enable, timespec and calls. The
+// calls are compared by their JSON encoding so that numbers decoded from YAML
+// and from the device compare equal.
+func (t *Job) equal(other *Job) bool {
+
+	if t.enabled() != other.enabled() || t.Timespec != other.Timespec {
+		return false
+	}
+
+	return reflect.DeepEqual(normalize(t.Calls), normalize(other.Calls))
+}
+
+func normalize(calls []*Call) any {
+	b, _ := json.Marshal(calls)
+	var v any
+	json.Unmarshal(b, &v)
+	return v
+}
+
+// Schedules is the file format used by apply and the output of list
+type Schedules struct {
+	Schedules []*Job `json:"schedules" yaml:"schedules"`
+}
+
+func (t *Schedules) Header() []string {
+	return []string{"ID", "ENABLE", "TIMESPEC", "CALLS"}
+}
+
+func (t *Schedules) Rows() [][]string {
+	var rows [][]string
+	for _, job := range t.Schedules {
+		id := ""
+		if job.ID != nil {
+			id = strconv.Itoa(*job.ID)
+		}
+		rows = append(rows, []string{id, strconv.FormatBool(job.enabled()), job.Timespec, job.methods()})
+	}
+	return rows
+}
+
+func list(ctx context.Context, client *rpc.Client) ([]*Job, error) {
+
+	result := &struct {
+		Jobs []*Job `json:"jobs"`
+	}{}
+
+	err := client.Call(ctx, "Schedule.List", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Jobs, nil
+}
+
+// Change is a planned or applied change of a job
+type Change struct {
+	Action   string `json:"action" yaml:"action"`
+	ID       *int   `json:"id,omitempty" yaml:"id,omitempty"`
+	Timespec string `json:"timespec" yaml:"timespec"`
+	Calls    string `json:"calls" yaml:"calls"`
+	job      *Job
+}
+
+const (
+	actionCreate    = "create"
+	actionUpdate    = "update"
+	actionDelete    = "delete"
+	actionUnchanged = "unchanged"
+)
+
+type Changes []*Change
+
+func (t Changes) Header() []string {
+	return []string{"ACTION", "ID", "TIMESPEC", "CALLS"}
+}
+
+func (t Changes) Rows() [][]string {
+	var rows [][]string
+	for _, change := range t {
+		id := ""
+		if change.ID != nil {
+			id = strconv.Itoa(*change.ID)
+		}
+		rows = append(rows, []string{change.Action, id, change.Timespec, change.Calls})
+	}
+	return rows
+}
+
+func newChange(action string, id *int, job *Job) *Change {
+	return &Change{
+		Action:   action,
+		ID:       id,
+		Timespec: job.Timespec,
+		Calls:    job.methods(),
+		job:      job,
+	}
+}
+
+// plan compares the desired jobs with the jobs on the device. Desired jobs with
+// an ID are matched by ID. Others are matched to an identical job, else to a job
+// with the same timespec (update), else created. Unmatched device jobs are
+// deleted if prune is set.
+func plan(current, desired []*Job, prune bool) (Changes, error) {
+
+	var changes Changes
+
+	matched := make(map[int]bool)
+
+	find := func(match func(*Job) bool) *Job {
+		for _, job := range current {
+			if !matched[*job.ID] && match(job) {
+				return job
+			}
+		}
+		return nil
+	}
+
+	for _, job := range desired {
+
+		err := job.validate()
+		if err != nil {
+			return nil, err
+		}
+
+		var existing *Job
+
+		if job.ID != nil {
+			existing = find(func(j *Job) bool { return *j.ID == *job.ID })
+			if existing == nil {
+				return nil, fmt.Errorf("schedule %d not found on device", *job.ID)
+			}
+		} else {
+			existing = find(job.equal)
+			if existing == nil {
+				existing = find(func(j *Job) bool { return j.Timespec == job.Timespec })
+			}
+		}
+
+		if existing == nil {
+			changes = append(changes, newChange(actionCreate, nil, job))
+			continue
+		}
+
+		matched[*existing.ID] = true
+
+		if job.equal(existing) {
+			changes = append(changes, newChange(actionUnchanged, existing.ID, job))
+			continue
+		}
+
+		changes = append(changes, newChange(actionUpdate, existing.ID, job))
+	}
+
+	if prune {
+		for _, job := range current {
+			if !matched[*job.ID] {
+				changes = append(changes, newChange(actionDelete, job.ID, job))
+			}
+		}
+	}
+
+	count := len(current)
+	for _, change := range changes {
+		switch change.Action {
+		case actionCreate:
+			count++
+		case actionDelete:
+			count--
+		}
+	}
+
+	if count > maxJobs {
+		return nil, fmt.Errorf("device supports %d schedules; the plan results in %d", maxJobs, count)
+	}
+
+	// order the changes as apply executes them
+	var ordered Changes
+	for _, action := range []string{actionUnchanged, actionDelete, actionUpdate, actionCreate} {
+		for _, change := range changes {
+			if change.Action == action {
+				ordered = append(ordered, change)
+			}
+		}
+	}
+
+	return ordered, nil
+}
+
+// apply executes the changes in order. plan orders deletes first to make room
+// for creates.
+func apply(ctx context.Context, client *rpc.Client, changes Changes) error {
+
+	for _, change := range changes {
+
+		var err error
+
+		switch change.Action {
+
+		case actionDelete:
+			err = client.Call(ctx, "Schedule.Delete", map[string]int{"id": *change.ID}, nil)
+
+		case actionUpdate:
+			err = client.Call(ctx, "Schedule.Update", jobParams(change.ID, change.job), nil)
+
+		case actionCreate:
+			result := &struct {
+				ID int `json:"id"`
+			}{}
+			err = client.Call(ctx, "Schedule.Create", jobParams(nil, change.job), result)
+			change.ID = &result.ID
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s schedule %q failed: %w", change.Action, change.Timespec, err)
+		}
+	}
+
+	return nil
+}
+
+func jobParams(id *int, job *Job) map[string]any {
+
+	params := map[string]any{
+		"enable":   job.enabled(),
+		"timespec": job.Timespec,
+		"calls":    job.Calls,
+	}
+
+	if id != nil {
+		params["id"] = *id
+	}
+
+	return params
+}
@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidateTimespec(t *testing.T) {
+
+	valid := []string{
+		"0 30 7 * * MON-FRI",
+		"0 0 22 * * *",
+		"*/15 * * * * *",
+		"0 0,30 8-18/2 1-15 JAN,jun SUN",
+		"0 0 0 31 12 6",
+		"@sunset * * *",
+		"@sunrise+1h * * SAT,SUN",
+		"@sunset-30m * * *",
+		"@sunrise+1h30m 1 * *",
+	}
+
+	for _, timespec := range valid {
+		if err := validateTimespec(timespec); err != nil {
+			t.Errorf("validateTimespec(%q) error = %v", timespec, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                         "expect 6 fields",
+		"0 30 7 * *":               "expect 6 fields",
+		"60 0 0 * * *":             "second \"60\" is invalid",
+		"0 0 24 * * *":             "hour \"24\" is invalid",
+		"0 0 0 0 * *":              "day of month \"0\" is invalid",
+		"0 0 0 * 13 *":             "month \"13\" is invalid",
+		"0 0 0 * * 7":              "day of week \"7\" is invalid",
+		"0 0 0 * * FRI-MON":        "range \"FRI-MON\" is invalid",
+		"*/0 * * * * *":            "step \"0\" is invalid",
+		"*/61 * * * * *":           "step \"61\" is invalid",
+		"0 0 0 * * MONDAY":         "day of week \"MONDAY\" is invalid",
+		"@noon * * *":              "@noon is invalid",
+		"@sunset+ * * *":           "@sunset+ is invalid",
+		"@sunset+30s * * *":        "is invalid",
+		"@sunset * *":              "expect a sun event followed by",
+		"@sunrise * 13 *":          "month \"13\" is invalid",
+		"0 30 7 * * MON-FRI extra": "expect 6 fields",
+	}
+
+	for timespec, want := range invalid {
+		err := validateTimespec(timespec)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("validateTimespec(%q) error = %v; want %q", timespec, err, want)
+		}
+	}
+}
+
+func newJob(id int, timespec string, method string, params map[string]any) *Job {
+	job := &Job{Timespec: timespec, Calls: []*Call{{Method: method, Params: params}}}
+	if id >= 0 {
+		job.ID = &id
+	}
+	return job
+}
+
+func TestPlan(t *testing.T) {
+
+	current := []*Job{
+		newJob(1, "0 0 7 * * *", "Switch.Set", map[string]any{"id": 0.0, "on": true}),
+		newJob(2, "0 0 22 * * *", "Switch.Set", map[string]any{"id": 0.0, "on": false}),
+		newJob(3, "0 0 12 * * *", "Script.Start", map[string]any{"id": 1.0}),
+	}
+
+	desired := []*Job{
+		// Identical except for the number type, as decoded from YAML
+		newJob(-1, "0 0 7 * * *", "Switch.Set", map[string]any{"id": 0, "on": true}),
+		// Same timespec, other params
+		newJob(-1, "0 0 22 * * *", "Switch.Set", map[string]any{"id": 1, "on": false}),
+		newJob(-1, "0 30 6 * * MON-FRI", "Switch.Set", map[string]any{"id": 0, "on": true}),
+	}
+
+	changes, err := plan(current, desired, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := summary(changes)
+	want := "unchanged 1,update 2,create -"
+	if got != want {
+		t.Errorf("plan = %s; want %s", got, want)
+	}
+
+	changes, err = plan(current, desired, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got = summary(changes)
+	want = "unchanged 1,delete 3,update 2,create -"
+	if got != want {
+		t.Errorf("plan with prune = %s; want %s", got, want)
+	}
+
+	_, err = plan(current, []*Job{newJob(9, "0 0 7 * * *", "Switch.Set", nil)}, false)
+	if err == nil || !strings.Contains(err.Error(), "schedule 9 not found") {
+		t.Errorf("plan with unknown ID error = %v", err)
+	}
+
+	_, err = plan(current, []*Job{newJob(-1, "0 0 7 * *", "Switch.Set", nil)}, false)
+	if err == nil || !strings.Contains(err.Error(), "expect 6 fields") {
+		t.Errorf("plan with invalid timespec error = %v", err)
+	}
+
+	_, err = plan(current, []*Job{newJob(-1, "0 0 7 * * *", "SwitchSet", nil)}, false)
+	if err == nil || !strings.Contains(err.Error(), "expect Component.Method") {
+		t.Errorf("plan with invalid method error = %v", err)
+	}
+
+	var many []*Job
+	for i := 0; i < maxJobs; i++ {
+		many = append(many, newJob(-1, "0 0 1 * * *", "Switch.Toggle", map[string]any{"id": i}))
+	}
+
+	_, err = plan(current, many, false)
+	if err == nil || !strings.Contains(err.Error(), "device supports 20 schedules") {
+		t.Errorf("plan with too many jobs error = %v", err)
+	}
+}
+
+func summary(changes Changes) string {
+	var s []string
+	for _, change := range changes {
+		id := "-"
+		if change.ID != nil {
+			id = strconv.Itoa(*change.ID)
+		}
+		s = append(s, change.Action+" "+id)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// timespecField is a field of the cron-like timespec
+type timespecField struct {
+	name  string
+	min   int
+	max   int
+	names []string // optional names for the values starting at min
+}
+
+var secondField = &timespecField{name: "second", min: 0, max: 59}
+var minuteField = &timespecField{name: "minute", min: 0, max: 59}
+var hourField = &timespecField{name: "hour", min: 0, max: 23}
+var dayOfMonthField = &timespecField{name: "day of month", min: 1, max: 31}
+
+var monthField = &timespecField{name: "month", min: 1, max: 12,
+	names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+
+var dayOfWeekField = &timespecField{name: "day of week", min: 0, max: 6,
+	names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+
+// sunEvent matches @sunrise or @sunset with an optional offset such as +1h30m
+var sunEvent = regexp.MustCompile(`^@(sunrise|sunset)([+-](\d+h)?(\d+m)?)?$`)
+
+// validateTimespec validates a Shelly schedule timespec. It is either six
+// fields (second minute hour day-of-month month day-of-week) or a sun event
+// followed by day-of-month month day-of-week, for example
+//
+//	0 30 7 * * MON-FRI
+//	@sunset-30m * * *
+func validateTimespec(timespec string) error {
+
+	fields := strings.Fields(timespec)
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+
+		match := sunEvent.FindStringSubmatch(fields[0])
+		if match == nil || (match[2] != "" && match[3] == "" && match[4] == "") {
+			return fmt.Errorf("timespec %q: %s is invalid; expect @sunrise or @sunset with an optional offset such as +1h30m", timespec, fields[0])
+		}
+
+		if len(fields) != 4 {
+			return fmt.Errorf("timespec %q: expect a sun event followed by day of month, month and day of week", timespec)
+		}
+
+		return validateFields(timespec, fields[1:], dayOfMonthField, monthField, dayOfWeekField)
+	}
+
+	if len(fields) != 6 {
+		return fmt.Errorf("timespec %q: expect 6 fields (second minute hour day-of-month month day-of-week) or a sun event", timespec)
+	}
+
+	return validateFields(timespec, fields, secondField, minuteField, hourField, dayOfMonthField, monthField, dayOfWeekField)
+}
+
+func validateFields(timespec string, values []string, fields ...*timespecField) error {
+
+	for i, field := range fields {
+		err := field.validate(values[i])
+		if err != nil {
+			return fmt.Errorf("timespec %q: %w", timespec, err)
+		}
+	}
+
+	return nil
+}
+
+// validate validates a comma separated list of *, values, ranges and steps
+func (t *timespecField) validate(s string) error {
+
+	for _, item := range strings.Split(s, ",") {
+
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		if hasStep {
+			step, err := strconv.Atoi(stepPart)
+			if err != nil || step < 1 || step > t.max-t.min+1 {
+				return fmt.Errorf("%s step %q is invalid", t.name, stepPart)
+			}
+		}
+
+		if rangePart == "*" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(rangePart, "-")
+
+		fromValue, err := t.value(from)
+		if err != nil {
+			return err
+		}
+
+		if !isRange {
+			continue
+		}
+
+		toValue, err := t.value(to)
+		if err != nil {
+			return err
+		}
+
+		if toValue < fromValue {
+			return fmt.Errorf("%s range %q is invalid", t.name, rangePart)
+		}
+	}
+
+	return nil
+}
+
+func (t *timespecField) value(s string) (int, error) {
+
+	for i, name := range t.names {
+		if strings.EqualFold(s, name) {
+			return t.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < t.min || v > t.max {
+		return 0, fmt.Errorf("%s %q is invalid; expect %d to %d", t.name, s, t.min, t.max)
+	}
+
+	return v, nil
+}
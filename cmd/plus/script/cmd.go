@@ -0,0 +1,431 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+	"github.com/jodydadescott/shelly-go-cli/types"
+)
+
+// putChunkSize is the maximum number of bytes sent per Script.PutCode call
+const putChunkSize = 1024
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	RPC() (*rpc.Client, error)
+	CallAndWrite(ctx context.Context, method string, params any) error
+	GetFiles() (*types.Files, error)
+}
+
+// script is an entry returned by Script.List
+type script struct {
+	ID      int    `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Enable  bool   `json:"enable" yaml:"enable"`
+	Running bool   `json:"running" yaml:"running"`
+}
+
+type scripts []*script
+
+func (t scripts) Header() []string {
+	return []string{"ID", "NAME", "ENABLE", "RUNNING"}
+}
+
+func (t scripts) Rows() [][]string {
+	var rows [][]string
+	for _, s := range t {
+		rows = append(rows, []string{strconv.Itoa(s.ID), s.Name, strconv.FormatBool(s.Enable), strconv.FormatBool(s.Running)})
+	}
+	return rows
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var scriptIDArg string
+
+	// call calls method with the script id and writes the result
+	call := func(ctx context.Context, method string) error {
+		scriptID, err := channel.ParseID("script", scriptIDArg)
+		if err != nil {
+			return err
+		}
+		return callback.CallAndWrite(ctx, method, map[string]int{"id": scriptID})
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "script",
+		Short: "Script management",
+	}
+
+	rootCmd.PersistentFlags().StringVar(&scriptIDArg, "id", "", "script ID integer")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists scripts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			result, err := list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Creates an empty script",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Script.Create", map[string]string{"name": args[0]}, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var nameArg string
+	var startArg bool
+
+	putCmd := &cobra.Command{
+		Use:   "put",
+		Short: "Uploads code from file / STDIN to the script given by --id or --name",
+		Long: "Uploads code from file / STDIN to the script given by --id or --name. With --name the script is created " +
+			"if it does not exist. A running script is stopped for the upload and started again afterwards.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			ctx := cmd.Context()
+
+			if scriptIDArg != "" && nameArg != "" {
+				return fmt.Errorf("id and name are mutually exclusive")
+			}
+
+			files, err := callback.GetFiles()
+			if err != nil {
+				return err
+			}
+
+			file := files.GetSingleFile()
+			if file == nil {
+				return fmt.Errorf("expected a single file")
+			}
+
+			if !utf8.Valid(file.Bytes) {
+				return fmt.Errorf("script code must be UTF-8")
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			existing, err := list(ctx, client)
+			if err != nil {
+				return err
+			}
+
+			var target *script
+
+			if nameArg == "" {
+
+				scriptID, err := channel.ParseID("script", scriptIDArg)
+				if err != nil {
+					return err
+				}
+
+				for _, s := range existing {
+					if s.ID == scriptID {
+						target = s
+					}
+				}
+
+				if target == nil {
+					return fmt.Errorf("script %d not found", scriptID)
+				}
+
+			} else {
+
+				for _, s := range existing {
+					if s.Name == nameArg {
+						target = s
+					}
+				}
+
+				if target == nil {
+
+					target = &script{Name: nameArg}
+
+					err = client.Call(ctx, "Script.Create", map[string]string{"name": nameArg}, target)
+					if err != nil {
+						return err
+					}
+
+					callback.WriteStderr(fmt.Sprintf("created script %d %s", target.ID, nameArg))
+				}
+			}
+
+			if target.Running {
+				err = client.Call(ctx, "Script.Stop", map[string]int{"id": target.ID}, nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = putCode(ctx, client, target.ID, string(file.Bytes))
+			if err != nil {
+				return err
+			}
+
+			callback.WriteStderr(fmt.Sprintf("uploaded %d bytes to script %d", len(file.Bytes), target.ID))
+
+			if target.Running || startArg {
+				return client.Call(ctx, "Script.Start", map[string]int{"id": target.ID}, nil)
+			}
+
+			return nil
+		},
+	}
+
+	putCmd.Flags().StringVar(&nameArg, "name", "", "script name; created if it does not exist")
+	putCmd.Flags().BoolVar(&startArg, "start", false, "start the script after upload")
+
+	getCmd := &cobra.Command{
+		Use:   "get",
+		Short: "Returns script code",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scriptID, err := channel.ParseID("script", scriptIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			code, err := getCode(cmd.Context(), client, scriptID)
+			if err != nil {
+				return err
+			}
+
+			// WriteStdout terminates the output with a newline
+			return callback.WriteStdout(strings.TrimSuffix(code, "\n"))
+		},
+	}
+
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "Starts the script",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "Script.Start")
+		},
+	}
+
+	stopCmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stops the script",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), "Script.Stop")
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Deletes the script",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scriptID, err := channel.ParseID("script", scriptIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "Script.Delete", map[string]int{"id": scriptID}, nil)
+		},
+	}
+
+	evalCmd := &cobra.Command{
+		Use:   "eval <code>",
+		Short: "Evaluates code in the context of the running script",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scriptID, err := channel.ParseID("script", scriptIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Script.Eval", map[string]any{
+				"id":   scriptID,
+				"code": strings.Join(args, " "),
+			}, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var grepArg string
+
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Tails script print() output from the debug websocket",
+		Long: "Tails script print() output from the debug websocket. The debug websocket must be enabled " +
+			"(plus sys set-config --debug-websocket). With --id only the output of that script is shown; " +
+			"otherwise the whole debug log is shown.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scriptID := -1
+
+			if scriptIDArg != "" {
+				var err error
+				scriptID, err = channel.ParseID("script", scriptIDArg)
+				if err != nil {
+					return err
+				}
+			}
+
+			var filter *regexp.Regexp
+
+			if grepArg != "" {
+				var err error
+				filter, err = regexp.Compile(grepArg)
+				if err != nil {
+					return fmt.Errorf("grep %s is invalid: %w", grepArg, err)
+				}
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return tail(cmd.Context(), client, scriptID, filter, func(line string) {
+				callback.WriteStdout(line)
+			})
+		},
+	}
+
+	logsCmd.Flags().StringVar(&grepArg, "grep", "", "only show lines matching the regular expression")
+
+	rootCmd.AddCommand(listCmd, createCmd, putCmd, getCmd, startCmd, stopCmd, deleteCmd, evalCmd, logsCmd)
+	return rootCmd
+}
+
+func list(ctx context.Context, client *rpc.Client) (scripts, error) {
+
+	result := &struct {
+		Scripts scripts `json:"scripts"`
+	}{}
+
+	err := client.Call(ctx, "Script.List", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Scripts, nil
+}
+
+// putCode uploads code in chunks of at most putChunkSize bytes. The first chunk
+// replaces the existing code and the rest are appended. Chunks never split a
+// UTF-8 sequence.
+func putCode(ctx context.Context, client *rpc.Client, scriptID int, code string) error {
+
+	appendArg := false
+
+	for {
+
+		chunk := code
+
+		if len(chunk) > putChunkSize {
+			end := putChunkSize
+			for end > 0 && !utf8.RuneStart(code[end]) {
+				end--
+			}
+			chunk = code[:end]
+		}
+
+		err := client.Call(ctx, "Script.PutCode", map[string]any{
+			"id":     scriptID,
+			"code":   chunk,
+			"append": appendArg,
+		}, nil)
+		if err != nil {
+			return err
+		}
+
+		code = code[len(chunk):]
+		appendArg = true
+
+		if code == "" {
+			return nil
+		}
+	}
+}
+
+// getCode downloads the script code, following left until it is complete
+func getCode(ctx context.Context, client *rpc.Client, scriptID int) (string, error) {
+
+	var sb strings.Builder
+
+	for {
+
+		result := &struct {
+			Data string `json:"data"`
+			Left int    `json:"left"`
+		}{}
+
+		err := client.Call(ctx, "Script.GetCode", map[string]int{
+			"id":     scriptID,
+			"offset": sb.Len(),
+		}, result)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(result.Data)
+
+		if result.Left <= 0 || result.Data == "" {
+			return sb.String(), nil
+		}
+	}
+}
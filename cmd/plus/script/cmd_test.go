@@ -0,0 +1,140 @@
+package script
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+)
+
+func TestPutCode(t *testing.T) {
+
+	// A multi-byte rune straddles the chunk boundary
+	code := strings.Repeat("a", putChunkSize-1) + "ä" + strings.Repeat("€", putChunkSize/3) + "end"
+
+	var stored string
+
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		chunk := params["code"].(string)
+		if params["append"] == true {
+			stored += chunk
+		} else {
+			stored = chunk
+		}
+		return map[string]any{"len": len(stored)}, nil
+	}})
+
+	err := putCode(context.Background(), device.Client(), 1, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored != code {
+		t.Errorf("stored %d bytes; want %d", len(stored), len(code))
+	}
+
+	calls := device.Calls()
+
+	for i, call := range calls {
+
+		chunk := call.Params["code"].(string)
+
+		if len(chunk) > putChunkSize || !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d: %d bytes, valid UTF-8 %v", i, len(chunk), utf8.ValidString(chunk))
+		}
+
+		if call.Params["append"] != (i > 0) || call.Params["id"] != 1.0 {
+			t.Errorf("chunk %d: params %v", i, call.Params)
+		}
+	}
+
+	if len(calls) < 3 || len(calls[0].Params["code"].(string)) != putChunkSize-1 {
+		t.Errorf("got %d chunks; want the first one to end before the split rune", len(calls))
+	}
+}
+
+func TestGetCode(t *testing.T) {
+
+	code := strings.Repeat("let x = 1;\n", 300)
+
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		offset := int(params["offset"].(float64))
+		end := offset + 1000
+		if end > len(code) {
+			end = len(code)
+		}
+		return map[string]any{"data": code[offset:end], "left": len(code) - end}, nil
+	}})
+
+	got, err := getCode(context.Background(), device.Client(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != code {
+		t.Errorf("got %d bytes; want %d", len(got), len(code))
+	}
+
+	if calls := device.Calls(); len(calls) != 4 || calls[3].Params["offset"] != 3000.0 {
+		t.Errorf("calls = %d; want 4 pages", len(calls))
+	}
+}
+
+func TestTail(t *testing.T) {
+
+	messages := []string{
+		`{"ts":1.5,"level":2,"data":"script one\n","fd":101}`,
+		`{"ts":1.5,"level":2,"data":"script two\n","fd":102}`,
+		`{"ts":1.5,"level":2,"data":"shelly_notification:163 Status change","fd":1}`,
+		`{"ts":2.5,"level":2,"data":"script one again\n","fd":101}`,
+		`plain text line`,
+	}
+
+	device := testdevice.New(t, &testdevice.Config{Websocket: func(path string, conn *websocket.Conn) {
+		if path != "/debug/log" {
+			return
+		}
+		for _, message := range messages {
+			conn.WriteMessage(websocket.TextMessage, []byte(message))
+		}
+	}})
+
+	tests := []struct {
+		scriptID int
+		filter   string
+		want     int
+	}{
+		{scriptID: -1, want: 5},
+		{scriptID: 1, want: 2},
+		{scriptID: 1, filter: "again", want: 1},
+		{scriptID: 3, want: 0},
+	}
+
+	for _, test := range tests {
+
+		var filter *regexp.Regexp
+		if test.filter != "" {
+			filter = regexp.MustCompile(test.filter)
+		}
+
+		var lines []string
+
+		// The device closes the log after the messages
+		err := tail(context.Background(), device.Client(), test.scriptID, filter, func(line string) {
+			lines = append(lines, line)
+		})
+
+		if err == nil || !strings.Contains(err.Error(), "debug log closed") {
+			t.Errorf("id %d: error = %v; want debug log closed", test.scriptID, err)
+		}
+
+		if len(lines) != test.want {
+			t.Errorf("id %d filter %q: lines %q; want %d", test.scriptID, test.filter, lines, test.want)
+		}
+	}
+}
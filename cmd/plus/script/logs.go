@@ -0,0 +1,81 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// scriptFD is added to the script ID to get the fd of the debug log entries
+// with the output of a script
+const scriptFD = 100
+
+// logEntry is a message sent by the device on the debug websocket
+type logEntry struct {
+	TS    float64 `json:"ts"`
+	Level int     `json:"level"`
+	Data  string  `json:"data"`
+	FD    *int    `json:"fd"`
+}
+
+func (t *logEntry) String() string {
+	sec, frac := math.Modf(t.TS)
+	ts := time.Unix(int64(sec), int64(frac*1e9))
+	return ts.Format("15:04:05.000") + " " + strings.TrimRight(t.Data, "\r\n")
+}
+
+// tail reads the debug log until ctx is done or the connection is closed and
+// calls fn with each line that matches filter (all lines if filter is nil). If
+// scriptID is not negative only the output of that script is passed.
+func tail(ctx context.Context, client *rpc.Client, scriptID int, filter *regexp.Regexp, fn func(string)) error {
+
+	conn, err := client.Dial(ctx, "/debug/log")
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	for {
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("debug log closed: %w", err)
+		}
+
+		line := strings.TrimRight(string(message), "\r\n")
+
+		entry := &logEntry{}
+		if json.Unmarshal(message, entry) == nil && entry.Data != "" {
+			line = entry.String()
+		}
+
+		if scriptID >= 0 && (entry.FD == nil || *entry.FD != scriptFD+scriptID) {
+			continue
+		}
+
+		if filter != nil && !filter.MatchString(line) {
+			continue
+		}
+
+		fn(line)
+	}
+}
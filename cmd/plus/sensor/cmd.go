@@ -0,0 +1,245 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+// configFlags registers the set-config flags on cmd and returns a function that
+// returns the config for the changed flags
+type configFlags func(cmd *cobra.Command) func(cmd *cobra.Command) (map[string]any, error)
+
+// NewTemperatureCmd returns the command for the Temperature component
+func NewTemperatureCmd(callback callback) *cobra.Command {
+
+	return newCmd(callback, "temperature", "Temperature", "Temperature sensor Component", func(cmd *cobra.Command) func(cmd *cobra.Command) (map[string]any, error) {
+
+		var nameArg string
+		var reportThrArg float64
+		var offsetArg float64
+		var unitArg string
+
+		cmd.Flags().StringVar(&nameArg, "name", "", "sensor name")
+		cmd.Flags().Float64Var(&reportThrArg, "report-thr", 0, "report threshold in --unit degrees")
+		cmd.Flags().Float64Var(&offsetArg, "offset", 0, "offset in --unit degrees added to the measurement")
+		cmd.Flags().StringVar(&unitArg, "unit", "C", "unit of report-thr and offset. One of: C | F")
+
+		return func(cmd *cobra.Command) (map[string]any, error) {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			unit, err := parseUnit(unitArg)
+			if err != nil {
+				return nil, err
+			}
+
+			if flags.Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if flags.Changed("report-thr") {
+				if reportThrArg <= 0 {
+					return nil, fmt.Errorf("report-thr must be positive")
+				}
+				config["report_thr_C"] = round(unit.deltaToC(reportThrArg))
+			}
+
+			if flags.Changed("offset") {
+				config["offset_C"] = round(unit.deltaToC(offsetArg))
+			}
+
+			return config, nil
+		}
+	})
+}
+
+// NewHumidityCmd returns the command for the Humidity component
+func NewHumidityCmd(callback callback) *cobra.Command {
+
+	return newCmd(callback, "humidity", "Humidity", "Humidity sensor Component", func(cmd *cobra.Command) func(cmd *cobra.Command) (map[string]any, error) {
+
+		var nameArg string
+		var reportThrArg float64
+		var offsetArg float64
+
+		cmd.Flags().StringVar(&nameArg, "name", "", "sensor name")
+		cmd.Flags().Float64Var(&reportThrArg, "report-thr", 0, "report threshold in %RH")
+		cmd.Flags().Float64Var(&offsetArg, "offset", 0, "offset in %RH added to the measurement")
+
+		return func(cmd *cobra.Command) (map[string]any, error) {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			if flags.Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if flags.Changed("report-thr") {
+				if reportThrArg <= 0 || reportThrArg > 100 {
+					return nil, fmt.Errorf("report-thr must be greater than 0 and at most 100")
+				}
+				config["report_thr"] = reportThrArg
+			}
+
+			if flags.Changed("offset") {
+				if offsetArg < -50 || offsetArg > 50 {
+					return nil, fmt.Errorf("offset must be -50 to 50")
+				}
+				config["offset"] = offsetArg
+			}
+
+			return config, nil
+		}
+	})
+}
+
+// NewVoltmeterCmd returns the command for the Voltmeter component
+func NewVoltmeterCmd(callback callback) *cobra.Command {
+
+	return newCmd(callback, "voltmeter", "Voltmeter", "Voltmeter (add-on analog input) Component", func(cmd *cobra.Command) func(cmd *cobra.Command) (map[string]any, error) {
+
+		var nameArg string
+		var reportThrArg float64
+		var xvoltageExprArg string
+		var xvoltageUnitArg string
+
+		cmd.Flags().StringVar(&nameArg, "name", "", "sensor name")
+		cmd.Flags().Float64Var(&reportThrArg, "report-thr", 0, "report threshold in volts")
+		cmd.Flags().StringVar(&xvoltageExprArg, "xvoltage-expr", "", "JS expression converting x (volts) to xvoltage such as 'x*10'")
+		cmd.Flags().StringVar(&xvoltageUnitArg, "xvoltage-unit", "", "unit of xvoltage such as bar")
+
+		return func(cmd *cobra.Command) (map[string]any, error) {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+			xvoltage := make(map[string]any)
+
+			if flags.Changed("name") {
+				config["name"] = nameArg
+			}
+
+			if flags.Changed("report-thr") {
+				if reportThrArg <= 0 {
+					return nil, fmt.Errorf("report-thr must be positive")
+				}
+				config["report_thr"] = reportThrArg
+			}
+
+			if flags.Changed("xvoltage-expr") {
+				xvoltage["expr"] = xvoltageExprArg
+			}
+
+			if flags.Changed("xvoltage-unit") {
+				xvoltage["unit"] = xvoltageUnitArg
+			}
+
+			if len(xvoltage) > 0 {
+				config["xvoltage"] = xvoltage
+			}
+
+			return config, nil
+		}
+	})
+}
+
+// NewDevicePowerCmd returns the command for the DevicePower component. It has
+// no configuration.
+func NewDevicePowerCmd(callback callback) *cobra.Command {
+	return newCmd(callback, "devicepower", "DevicePower", "Device power (battery / external supply) Component", nil)
+}
+
+func newCmd(callback callback, use, component, short string, flags configFlags) *cobra.Command {
+
+	var sensorIDArg string
+
+	// call calls method with the sensor id and writes the result
+	call := func(ctx context.Context, method string) error {
+		sensorID, err := channel.ParseID("sensor", sensorIDArg)
+		if err != nil {
+			return err
+		}
+		return callback.CallAndWrite(ctx, method, map[string]int{"id": sensorID})
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+	}
+
+	rootCmd.PersistentFlags().StringVar(&sensorIDArg, "id", "", "sensor ID integer")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), component+".GetStatus")
+		},
+	}
+
+	rootCmd.AddCommand(statusCmd)
+
+	if flags == nil {
+		return rootCmd
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call(cmd.Context(), component+".GetConfig")
+		},
+	}
+
+	var disableAutoRebootArg bool
+	var getConfig func(cmd *cobra.Command) (map[string]any, error)
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			sensorID, err := channel.ParseID("sensor", sensorIDArg)
+			if err != nil {
+				return err
+			}
+
+			config, err := getConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), component+".SetConfig", map[string]any{
+				"id":     sensorID,
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	getConfig = flags(setConfigCmd)
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(getConfigCmd, setConfigCmd)
+	return rootCmd
+}
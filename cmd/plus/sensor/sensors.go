@@ -0,0 +1,189 @@
+package sensor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// sensorComponents are the status key prefixes of the sensor type components
+var sensorComponents = []string{"temperature", "humidity", "voltmeter", "devicepower"}
+
+// Reading is a single value of a sensor type component
+type Reading struct {
+	Component string   `json:"component"`
+	ID        int      `json:"id"`
+	Name      string   `json:"name,omitempty"`
+	Value     *float64 `json:"value,omitempty"`
+	Unit      string   `json:"unit,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// Readings is a list of Reading
+type Readings []*Reading
+
+func (t Readings) Header() []string {
+	return []string{"COMPONENT", "ID", "NAME", "VALUE", "UNIT", "ERRORS"}
+}
+
+func (t Readings) Rows() [][]string {
+	var rows [][]string
+	for _, reading := range t {
+		value := ""
+		if reading.Value != nil {
+			value = strconv.FormatFloat(*reading.Value, 'f', -1, 64)
+		}
+		rows = append(rows, []string{reading.Component, strconv.Itoa(reading.ID), reading.Name, value,
+			reading.Unit, strings.Join(reading.Errors, ",")})
+	}
+	return rows
+}
+
+// sensorStatus is the union of the sensor component status fields
+type sensorStatus struct {
+	ID       int      `json:"id"`
+	TC       *float64 `json:"tC"`
+	RH       *float64 `json:"rh"`
+	Voltage  *float64 `json:"voltage"`
+	XVoltage *float64 `json:"xvoltage"`
+	Battery  *struct {
+		V       *float64 `json:"V"`
+		Percent *float64 `json:"percent"`
+	} `json:"battery"`
+	Errors []string `json:"errors"`
+}
+
+// sensorConfig is the part of the sensor component config used in the readings
+type sensorConfig struct {
+	Name     string `json:"name"`
+	XVoltage *struct {
+		Unit string `json:"unit"`
+	} `json:"xvoltage"`
+}
+
+// NewSensorsCmd returns the command listing every sensor type component
+func NewSensorsCmd(callback callback) *cobra.Command {
+
+	var unitArg string
+
+	cmd := &cobra.Command{
+		Use:   "sensors",
+		Short: "Lists the readings of every Temperature, Humidity, Voltmeter and DevicePower component",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			ctx := cmd.Context()
+
+			unit, err := parseUnit(unitArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			var status map[string]json.RawMessage
+
+			err = client.Call(ctx, "Shelly.GetStatus", nil, &status)
+			if err != nil {
+				return err
+			}
+
+			var config map[string]json.RawMessage
+
+			err = client.Call(ctx, "Shelly.GetConfig", nil, &config)
+			if err != nil {
+				return err
+			}
+
+			keys := make([]string, 0, len(status))
+			for key := range status {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			readings := Readings{}
+
+			for _, key := range keys {
+
+				component, _, _ := strings.Cut(key, ":")
+				if !isSensor(component) {
+					continue
+				}
+
+				s := &sensorStatus{}
+				err := json.Unmarshal(status[key], s)
+				if err != nil {
+					return fmt.Errorf("unable to decode status of %s: %w", key, err)
+				}
+
+				c := &sensorConfig{}
+				if b, ok := config[key]; ok {
+					json.Unmarshal(b, c)
+				}
+
+				readings = append(readings, newReadings(component, s, c, unit)...)
+			}
+
+			return callback.WriteStdout(readings)
+		},
+	}
+
+	cmd.Flags().StringVar(&unitArg, "unit", "C", "temperature unit. One of: C | F")
+	return cmd
+}
+
+func isSensor(component string) bool {
+	for _, s := range sensorComponents {
+		if s == component {
+			return true
+		}
+	}
+	return false
+}
+
+// newReadings returns the readings of a component; one unless a voltmeter has
+// a converted xvoltage or device power reports both voltage and percent
+func newReadings(component string, s *sensorStatus, c *sensorConfig, unit unit) Readings {
+
+	reading := func(value *float64, u string) *Reading {
+		return &Reading{Component: component, ID: s.ID, Name: c.Name, Value: value, Unit: u, Errors: s.Errors}
+	}
+
+	switch component {
+
+	case "temperature":
+		if s.TC == nil {
+			return Readings{reading(nil, unit.String())}
+		}
+		value := round(unit.fromC(*s.TC))
+		return Readings{reading(&value, unit.String())}
+
+	case "humidity":
+		return Readings{reading(s.RH, "%")}
+
+	case "voltmeter":
+		readings := Readings{reading(s.Voltage, "V")}
+		if s.XVoltage != nil {
+			xunit := ""
+			if c.XVoltage != nil {
+				xunit = c.XVoltage.Unit
+			}
+			readings = append(readings, reading(s.XVoltage, xunit))
+		}
+		return readings
+
+	case "devicepower":
+		if s.Battery == nil {
+			return Readings{reading(nil, "")}
+		}
+		return Readings{reading(s.Battery.Percent, "%"), reading(s.Battery.V, "V")}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package sensor
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnit(t *testing.T) {
+
+	for _, s := range []string{"c", "C", "°C"} {
+		if u, err := parseUnit(s); err != nil || u != celsius {
+			t.Errorf("parseUnit(%q) = %v, %v; want C", s, u, err)
+		}
+	}
+
+	if u, err := parseUnit("f"); err != nil || u != fahrenheit {
+		t.Errorf("parseUnit(f) = %v, %v; want F", u, err)
+	}
+
+	if _, err := parseUnit("K"); err == nil {
+		t.Errorf("parseUnit(K) expected an error")
+	}
+
+	tests := []struct {
+		unit  unit
+		c     float64
+		value float64
+		delta float64
+	}{
+		{unit: celsius, c: 21.5, value: 21.5, delta: 1},
+		{unit: fahrenheit, c: 21.5, value: 70.7, delta: 0.6},
+		{unit: fahrenheit, c: -40, value: -40, delta: 0.6},
+	}
+
+	for _, test := range tests {
+		if got := round(test.unit.fromC(test.c)); got != test.value {
+			t.Errorf("%s fromC(%g) = %g; want %g", test.unit, test.c, got, test.value)
+		}
+		if got := round(test.unit.deltaToC(1)); got != test.delta {
+			t.Errorf("%s deltaToC(1) = %g; want %g", test.unit, got, test.delta)
+		}
+	}
+}
+
+func TestNewReadings(t *testing.T) {
+
+	tests := []struct {
+		component string
+		status    string
+		config    string
+		unit      unit
+		want      string
+	}{
+		{
+			component: "temperature",
+			status:    `{"id":0,"tC":21.46}`,
+			config:    `{"name":"room"}`,
+			unit:      fahrenheit,
+			want:      `[{"component":"temperature","id":0,"name":"room","value":70.6,"unit":"°F"}]`,
+		},
+		{
+			component: "temperature",
+			status:    `{"id":1,"tC":null,"errors":["out_of_range"]}`,
+			config:    `{}`,
+			unit:      celsius,
+			want:      `[{"component":"temperature","id":1,"unit":"°C","errors":["out_of_range"]}]`,
+		},
+		{
+			component: "voltmeter",
+			status:    `{"id":100,"voltage":4.5,"xvoltage":45}`,
+			config:    `{"xvoltage":{"unit":"mm"}}`,
+			want:      `[{"component":"voltmeter","id":100,"value":4.5,"unit":"V"},{"component":"voltmeter","id":100,"value":45,"unit":"mm"}]`,
+		},
+		{
+			component: "devicepower",
+			status:    `{"id":0,"battery":{"V":3.1,"percent":80}}`,
+			config:    `{}`,
+			want:      `[{"component":"devicepower","id":0,"value":80,"unit":"%"},{"component":"devicepower","id":0,"value":3.1,"unit":"V"}]`,
+		},
+		{
+			component: "devicepower",
+			status:    `{"id":0}`,
+			config:    `{}`,
+			want:      `[{"component":"devicepower","id":0}]`,
+		},
+	}
+
+	for _, test := range tests {
+
+		status := &sensorStatus{}
+		config := &sensorConfig{}
+		json.Unmarshal([]byte(test.status), status)
+		json.Unmarshal([]byte(test.config), config)
+
+		data, _ := json.Marshal(newReadings(test.component, status, config, test.unit))
+
+		if string(data) != test.want {
+			t.Errorf("%s %s: got %s; want %s", test.component, test.status, data, test.want)
+		}
+	}
+}
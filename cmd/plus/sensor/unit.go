@@ -0,0 +1,53 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// unit is a temperature unit
+type unit string
+
+const (
+	celsius    unit = "C"
+	fahrenheit unit = "F"
+)
+
+func parseUnit(s string) (unit, error) {
+
+	switch strings.ToUpper(strings.TrimPrefix(s, "°")) {
+	case "C":
+		return celsius, nil
+	case "F":
+		return fahrenheit, nil
+	}
+
+	return "", fmt.Errorf("unit %s is invalid; expect C or F", s)
+}
+
+// deltaToC converts a temperature difference (threshold or offset) in the unit
+// to °C
+func (t unit) deltaToC(v float64) float64 {
+	if t == fahrenheit {
+		return v * 5 / 9
+	}
+	return v
+}
+
+// fromC converts a temperature in °C to the unit
+func (t unit) fromC(v float64) float64 {
+	if t == fahrenheit {
+		return v*9/5 + 32
+	}
+	return v
+}
+
+func (t unit) String() string {
+	return "°" + string(t)
+}
+
+// round rounds to one decimal place; the resolution of the sensors
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -1,40 +1,54 @@
 package switchx
 
 import (
-	"fmt"
-	"strconv"
+	"context"
 
 	"github.com/spf13/cobra"
 
-	"github.com/jodydadescott/shelly-go-sdk/plus/switchx"
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
 )
 
-var (
-	truex  = true
-	falsex = false
-)
+const component = "switch"
 
 type callback interface {
-	Switch() (*switchx.Client, error)
+	WriteStdout(any) error
+	RPC() (*rpc.Client, error)
 }
 
 func NewCmd(callback callback) *cobra.Command {
 
 	var switchIDArg string
 
-	getSwitchID := func() (*int, error) {
+	// run calls method for each switch selected by the id arg and writes the
+	// per switch results. The status is read with the same client so that the
+	// device authenticates the command only once.
+	run := func(ctx context.Context, method string, params map[string]any) error {
 
-		if switchIDArg == "" {
-			return nil, fmt.Errorf("switchID is required")
+		rpcClient, err := callback.RPC()
+		if err != nil {
+			return err
 		}
 
-		switchID, err := strconv.Atoi(switchIDArg)
-		if err == nil {
-			return &switchID, nil
+		switchIDs, err := channel.ParseIDs(ctx, rpcClient, component, switchIDArg)
+		if err != nil {
+			return err
 		}
 
-		return nil, fmt.Errorf("switchID must be an integer")
+		results, runErr := channel.Run(ctx, rpcClient, component, switchIDs, func(ctx context.Context, switchID int) error {
+			p := map[string]any{"id": switchID}
+			for k, v := range params {
+				p[k] = v
+			}
+			return rpcClient.Call(ctx, method, p, nil)
+		})
 
+		err = callback.WriteStdout(results)
+		if err != nil {
+			return err
+		}
+
+		return runErr
 	}
 
 	rootCmd := &cobra.Command{
@@ -42,43 +56,21 @@ func NewCmd(callback callback) *cobra.Command {
 		Short: "Turn switch on or off",
 	}
 
-	rootCmd.PersistentFlags().StringVar(&switchIDArg, "id", "", "switch ID integer")
+	rootCmd.PersistentFlags().StringVar(&switchIDArg, "id", "", "switch IDs; comma separated integers, ranges (0-2), names or 'all'")
 
 	setOnCmd := &cobra.Command{
 		Use:   "on",
-		Short: "Turn light on",
+		Short: "Turn switch on",
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			switchID, err := getSwitchID()
-			if err != nil {
-				return err
-			}
-
-			client, err := callback.Switch()
-			if err != nil {
-				return err
-			}
-
-			return client.Set(cmd.Context(), *switchID, &truex)
+			return run(cmd.Context(), "Switch.Set", map[string]any{"on": true})
 		},
 	}
 
 	setOffCmd := &cobra.Command{
 		Use:   "off",
-		Short: "Turn light off",
+		Short: "Turn switch off",
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			switchID, err := getSwitchID()
-			if err != nil {
-				return err
-			}
-
-			client, err := callback.Switch()
-			if err != nil {
-				return err
-			}
-
-			return client.Set(cmd.Context(), *switchID, &falsex)
+			return run(cmd.Context(), "Switch.Set", map[string]any{"on": false})
 		},
 	}
 
@@ -86,18 +78,7 @@ func NewCmd(callback callback) *cobra.Command {
 		Use:   "toggle",
 		Short: "Toggles switch",
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			switchID, err := getSwitchID()
-			if err != nil {
-				return err
-			}
-
-			client, err := callback.Switch()
-			if err != nil {
-				return err
-			}
-
-			return client.Toggle(cmd.Context(), *switchID)
+			return run(cmd.Context(), "Switch.Toggle", nil)
 		},
 	}
 
@@ -0,0 +1,155 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type callback interface {
+	CallAndWrite(ctx context.Context, method string, params any) error
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	rootCmd := &cobra.Command{
+		Use:   "sys",
+		Short: "System Component",
+	}
+
+	getStatusCmd := &cobra.Command{
+		Use:   "get-status",
+		Short: "Returns status; uptime, RAM and FS free, restart required, available updates and time",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			return callback.CallAndWrite(cmd.Context(), "Sys.GetStatus", nil)
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			return callback.CallAndWrite(cmd.Context(), "Sys.GetConfig", nil)
+		},
+	}
+
+	var nameArg string
+	var ecoModeArg bool
+	var timezoneArg string
+	var latArg float64
+	var lonArg float64
+	var sntpServerArg string
+	var debugMQTTArg bool
+	var debugWebsocketArg bool
+	var debugUDPArg string
+	var disableAutoRebootArg bool
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+
+			device := make(map[string]any)
+			location := make(map[string]any)
+			sntp := make(map[string]any)
+			debug := make(map[string]any)
+
+			if flags.Changed("name") {
+				device["name"] = nameArg
+			}
+
+			if flags.Changed("eco-mode") {
+				device["eco_mode"] = ecoModeArg
+			}
+
+			if flags.Changed("tz") {
+				_, err := time.LoadLocation(timezoneArg)
+				if err != nil {
+					return fmt.Errorf("timezone %s is invalid; expect an IANA name such as Europe/Sofia", timezoneArg)
+				}
+				location["tz"] = timezoneArg
+			}
+
+			if flags.Changed("lat") {
+				if latArg < -90 || latArg > 90 {
+					return fmt.Errorf("lat must be -90 to 90")
+				}
+				location["lat"] = latArg
+			}
+
+			if flags.Changed("lon") {
+				if lonArg < -180 || lonArg > 180 {
+					return fmt.Errorf("lon must be -180 to 180")
+				}
+				location["lon"] = lonArg
+			}
+
+			if flags.Changed("sntp-server") {
+				sntp["server"] = sntpServerArg
+			}
+
+			if flags.Changed("debug-mqtt") {
+				debug["mqtt"] = map[string]any{"enable": debugMQTTArg}
+			}
+
+			if flags.Changed("debug-websocket") {
+				debug["websocket"] = map[string]any{"enable": debugWebsocketArg}
+			}
+
+			if flags.Changed("debug-udp") {
+				if debugUDPArg == "" {
+					debug["udp"] = map[string]any{"addr": nil}
+				} else {
+					debug["udp"] = map[string]any{"addr": debugUDPArg}
+				}
+			}
+
+			config := make(map[string]any)
+
+			for key, value := range map[string]map[string]any{
+				"device":   device,
+				"location": location,
+				"sntp":     sntp,
+				"debug":    debug,
+			} {
+				if len(value) > 0 {
+					config[key] = value
+				}
+			}
+
+			if len(config) == 0 {
+
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Sys.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	setConfigCmd.Flags().StringVar(&nameArg, "name", "", "device name")
+	setConfigCmd.Flags().BoolVar(&ecoModeArg, "eco-mode", false, "enable eco mode")
+	setConfigCmd.Flags().StringVar(&timezoneArg, "tz", "", "timezone such as Europe/Sofia")
+	setConfigCmd.Flags().Float64Var(&latArg, "lat", 0, "latitude in degrees")
+	setConfigCmd.Flags().Float64Var(&lonArg, "lon", 0, "longitude in degrees")
+	setConfigCmd.Flags().StringVar(&sntpServerArg, "sntp-server", "", "SNTP server")
+	setConfigCmd.Flags().BoolVar(&debugMQTTArg, "debug-mqtt", false, "send debug logs over MQTT")
+	setConfigCmd.Flags().BoolVar(&debugWebsocketArg, "debug-websocket", false, "send debug logs over the websocket")
+	setConfigCmd.Flags().StringVar(&debugUDPArg, "debug-udp", "", "send debug logs over UDP to host:port; empty to disable")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	rootCmd.AddCommand(getStatusCmd, getConfigCmd, setConfigCmd)
+	return rootCmd
+}
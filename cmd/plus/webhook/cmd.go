@@ -0,0 +1,401 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+}
+
+// hookParams are the params of Webhook.Create and Webhook.Update. Unset fields
+// are omitted so that update only changes what is given.
+type hookParams struct {
+	ID            *int     `json:"id,omitempty"`
+	CID           *int     `json:"cid,omitempty"`
+	Enable        *bool    `json:"enable,omitempty"`
+	Event         *string  `json:"event,omitempty"`
+	Name          *string  `json:"name,omitempty"`
+	URLs          []string `json:"urls,omitempty"`
+	Condition     *string  `json:"condition,omitempty"`
+	RepeatPeriod  *int     `json:"repeat_period,omitempty"`
+	ActiveBetween []string `json:"active_between,omitempty"`
+}
+
+func (t *hookParams) validate(ctx context.Context, client *rpc.Client) error {
+
+	for _, u := range t.URLs {
+		err := validateURL(u)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.Condition != nil {
+		err := validateCondition(*t.Condition)
+		if err != nil {
+			return err
+		}
+	}
+
+	if t.RepeatPeriod != nil && *t.RepeatPeriod < 0 {
+		return fmt.Errorf("repeat-period must not be negative")
+	}
+
+	if t.ActiveBetween != nil {
+		if len(t.ActiveBetween) != 2 {
+			return fmt.Errorf("active_between must be a start and end time")
+		}
+		for _, s := range t.ActiveBetween {
+			err := validateTime(s)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.Event == nil {
+		return nil
+	}
+
+	eventTypes, err := listSupported(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if !eventTypes.has(*t.Event) {
+		return fmt.Errorf("event %s is not supported by the device; see webhook supported", *t.Event)
+	}
+
+	return nil
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var webhookIDArg string
+
+	var cidArg int
+	var enableArg bool
+	var eventArg string
+	var nameArg string
+	var urlsArg []string
+	var conditionArg string
+	var repeatPeriodArg int
+	var activeBetweenArg string
+
+	// getParams returns the params from the changed flags or if no flag was
+	// changed from file / STDIN
+	getParams := func(cmd *cobra.Command) (*hookParams, error) {
+
+		flags := cmd.Flags()
+		params := &hookParams{}
+		changed := false
+
+		if flags.Changed("cid") {
+			params.CID = &cidArg
+			changed = true
+		}
+
+		if flags.Changed("enable") {
+			params.Enable = &enableArg
+			changed = true
+		}
+
+		if flags.Changed("event") {
+			params.Event = &eventArg
+			changed = true
+		}
+
+		if flags.Changed("name") {
+			params.Name = &nameArg
+			changed = true
+		}
+
+		if flags.Changed("url") {
+			params.URLs = urlsArg
+			changed = true
+		}
+
+		if flags.Changed("condition") {
+			params.Condition = &conditionArg
+			changed = true
+		}
+
+		if flags.Changed("repeat-period") {
+			params.RepeatPeriod = &repeatPeriodArg
+			changed = true
+		}
+
+		if flags.Changed("active-between") {
+			start, end, ok := strings.Cut(activeBetweenArg, "-")
+			if !ok {
+				return nil, fmt.Errorf("active-between %s is invalid; expect HH:MM-HH:MM", activeBetweenArg)
+			}
+			params.ActiveBetween = []string{start, end}
+			changed = true
+		}
+
+		if changed {
+			return params, nil
+		}
+
+		err := callback.ReadConfigFile(params)
+		if err != nil {
+			return nil, err
+		}
+
+		return params, nil
+	}
+
+	addHookFlags := func(cmd *cobra.Command) {
+		cmd.Flags().IntVar(&cidArg, "cid", 0, "component ID the event belongs to")
+		cmd.Flags().BoolVar(&enableArg, "enable", true, "enable the webhook")
+		cmd.Flags().StringVar(&eventArg, "event", "", "event such as switch.on; see webhook supported")
+		cmd.Flags().StringVar(&nameArg, "name", "", "webhook name")
+		cmd.Flags().StringArrayVar(&urlsArg, "url", nil, "URL to call; may contain tokens such as ${ev.tC}. Repeat for more URLs")
+		cmd.Flags().StringVar(&conditionArg, "condition", "", "condition such as 'ev.tC > 30'")
+		cmd.Flags().IntVar(&repeatPeriodArg, "repeat-period", 0, "minimum seconds between calls")
+		cmd.Flags().StringVar(&activeBetweenArg, "active-between", "", "active time window HH:MM-HH:MM")
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Webhook management",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists webhooks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			result, err := list(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var componentArg string
+
+	supportedCmd := &cobra.Command{
+		Use:   "supported",
+		Short: "Lists the supported event types and their attributes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			eventTypes, err := listSupported(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			if componentArg == "" {
+				return callback.WriteStdout(eventTypes)
+			}
+
+			var result EventTypes
+
+			for _, eventType := range eventTypes {
+				if strings.HasPrefix(eventType.Event, strings.ToLower(componentArg)+".") {
+					result = append(result, eventType)
+				}
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	supportedCmd.Flags().StringVar(&componentArg, "component", "", "only list events of the component such as switch")
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Creates a webhook from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			params, err := getParams(cmd)
+			if err != nil {
+				return err
+			}
+
+			if params.Event == nil {
+				return fmt.Errorf("event is required")
+			}
+
+			if len(params.URLs) == 0 {
+				return fmt.Errorf("url is required")
+			}
+
+			if params.CID == nil {
+				params.CID = &cidArg
+			}
+
+			if params.Enable == nil {
+				params.Enable = &enableArg
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			err = params.validate(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Webhook.Create", params, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	addHookFlags(createCmd)
+
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Updates a webhook from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			webhookID, err := channel.ParseID("webhook", webhookIDArg)
+			if err != nil {
+				return err
+			}
+
+			params, err := getParams(cmd)
+			if err != nil {
+				return err
+			}
+
+			params.ID = &webhookID
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			err = params.validate(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			var result map[string]any
+
+			err = client.Call(cmd.Context(), "Webhook.Update", params, &result)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	updateCmd.Flags().StringVar(&webhookIDArg, "id", "", "webhook ID integer")
+	addHookFlags(updateCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Deletes a webhook",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			webhookID, err := channel.ParseID("webhook", webhookIDArg)
+			if err != nil {
+				return err
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			return client.Call(cmd.Context(), "Webhook.Delete", map[string]int{"id": webhookID}, nil)
+		},
+	}
+
+	deleteCmd.Flags().StringVar(&webhookIDArg, "id", "", "webhook ID integer")
+
+	testConfig := &testConfig{notify: callback.WriteStderr}
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Registers a temporary webhook to a local listener and returns the received request",
+		Long: "Registers a temporary webhook to a local listener and returns the first request received before the " +
+			"timeout. The event must be triggered on the device unless --fire is set. With --fire output on / off " +
+			"events are fired by switching the output to the opposite state and back, which briefly switches the " +
+			"connected load, and input button events with Input.Trigger, which runs the actions bound to the input.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if testConfig.event == "" {
+				return fmt.Errorf("event is required")
+			}
+
+			if testConfig.condition != "" {
+				err := validateCondition(testConfig.condition)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !strings.HasPrefix(testConfig.path, "/") {
+				testConfig.path = "/" + testConfig.path
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			eventTypes, err := listSupported(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+
+			if !eventTypes.has(testConfig.event) {
+				return fmt.Errorf("event %s is not supported by the device; see webhook supported", testConfig.event)
+			}
+
+			result, err := test(cmd.Context(), client, testConfig)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	testCmd.Flags().StringVar(&testConfig.event, "event", "", "event such as switch.on")
+	testCmd.Flags().IntVar(&testConfig.cid, "cid", 0, "component ID the event belongs to")
+	testCmd.Flags().StringVar(&testConfig.listen, "listen", "", "local listen address host:port; defaults to the address used to reach the device")
+	testCmd.Flags().StringVar(&testConfig.path, "path", "/hook", "URL path; may contain tokens such as ?t=${ev.tC}")
+	testCmd.Flags().StringVar(&testConfig.condition, "condition", "", "condition such as 'ev.tC > 30'")
+	testCmd.Flags().DurationVar(&testConfig.timeout, "timeout", 30*time.Second, "maximum time to wait for the request")
+	testCmd.Flags().BoolVar(&testConfig.fire, "fire", false, "fire the event from the CLI; switches outputs off and on (or on and off) again")
+
+	rootCmd.AddCommand(listCmd, supportedCmd, createCmd, updateCmd, deleteCmd, testCmd)
+	return rootCmd
+}
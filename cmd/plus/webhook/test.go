@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const testHookName = "shelly-cli-test"
+
+// restoreTimeout limits restoring the output state after firing an event,
+// which is done even if the command is interrupted
+const restoreTimeout = 10 * time.Second
+
+// Received is the request received by the test listener
+type Received struct {
+	Event      string            `json:"event" yaml:"event"`
+	RemoteAddr string            `json:"remote_addr" yaml:"remote_addr"`
+	Method     string            `json:"method" yaml:"method"`
+	URL        string            `json:"url" yaml:"url"`
+	Headers    map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body       string            `json:"body,omitempty" yaml:"body,omitempty"`
+	Latency    string            `json:"latency,omitempty" yaml:"latency,omitempty"`
+	at         time.Time
+}
+
+type testConfig struct {
+	event     string
+	cid       int
+	listen    string
+	path      string
+	condition string
+	timeout   time.Duration
+	fire      bool
+	notify    func(string)
+}
+
+// test starts a local HTTP listener, registers a temporary webhook for the
+// event pointing to it and returns the first request received. If fire is set
+// the event is fired by the CLI, otherwise it must be triggered on the device.
+// The webhook is deleted before returning.
+func test(ctx context.Context, client *rpc.Client, config *testConfig) (*Received, error) {
+
+	listen := config.listen
+
+	if listen == "" {
+		ip, err := localIP(client.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		listen = net.JoinHostPort(ip, "0")
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+
+	received := make(chan *Received, 1)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			body, _ := io.ReadAll(r.Body)
+
+			result := &Received{
+				Event:      config.event,
+				RemoteAddr: r.RemoteAddr,
+				Method:     r.Method,
+				URL:        r.URL.String(),
+				Headers:    make(map[string]string),
+				Body:       string(body),
+				at:         time.Now(),
+			}
+
+			for key := range r.Header {
+				result.Headers[key] = r.Header.Get(key)
+			}
+
+			select {
+			case received <- result:
+			default:
+			}
+		}),
+	}
+
+	go server.Serve(listener)
+	defer server.Close()
+
+	hookURL := "http://" + listener.Addr().String() + config.path
+
+	params := map[string]any{
+		"cid":    config.cid,
+		"enable": true,
+		"event":  config.event,
+		"name":   testHookName,
+		"urls":   []string{hookURL},
+	}
+
+	if config.condition != "" {
+		params["condition"] = config.condition
+	}
+
+	hook := &struct {
+		ID int `json:"id"`
+	}{}
+
+	err = client.Call(ctx, "Webhook.Create", params, hook)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Call(context.Background(), "Webhook.Delete", map[string]int{"id": hook.ID}, nil)
+
+	config.notify(fmt.Sprintf("registered webhook %d for %s to %s", hook.ID, config.event, hookURL))
+
+	var fired time.Time
+
+	if config.fire {
+		fired = time.Now()
+		err = fire(ctx, client, config.event, config.cid)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		config.notify(fmt.Sprintf("waiting %s for %s; trigger it on the device or use --fire", config.timeout, config.event))
+	}
+
+	timer := time.NewTimer(config.timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-received:
+		if !fired.IsZero() {
+			result.Latency = result.at.Sub(fired).Round(time.Millisecond).String()
+		}
+		return result, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no request received within %s", config.timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fire triggers the event. Output on / off events are fired by switching the
+// output to the opposite of its current state and back, which restores the
+// state read first. Input button events are emulated with Input.Trigger.
+// Other events must be triggered manually.
+func fire(ctx context.Context, client *rpc.Client, event string, cid int) error {
+
+	component, name, _ := strings.Cut(event, ".")
+
+	switch component {
+
+	case "switch", "light", "rgb", "rgbw", "cct":
+		if name != "on" && name != "off" {
+			break
+		}
+
+		state, err := channel.GetState(ctx, client, component, cid)
+		if err != nil {
+			return err
+		}
+
+		if state.Output == nil {
+			return fmt.Errorf("%s %d does not report its output", component, cid)
+		}
+
+		method := rpc.Method(component, "Set")
+
+		err = client.Call(ctx, method, map[string]any{"id": cid, "on": !*state.Output}, nil)
+		if err != nil {
+			return err
+		}
+
+		restoreCtx, cancel := context.WithTimeout(context.Background(), restoreTimeout)
+		defer cancel()
+
+		err = client.Call(restoreCtx, method, map[string]any{"id": cid, "on": *state.Output}, nil)
+		if err != nil {
+			return fmt.Errorf("unable to restore %s %d to %s: %w", component, cid, state, err)
+		}
+
+		return nil
+
+	case "input":
+		eventType := map[string]string{
+			"button_push":       "single_push",
+			"button_doublepush": "double_push",
+			"button_triplepush": "triple_push",
+			"button_longpush":   "long_push",
+		}[name]
+
+		if eventType == "" {
+			break
+		}
+
+		return client.Call(ctx, "Input.Trigger", map[string]any{"id": cid, "event_type": eventType}, nil)
+	}
+
+	return fmt.Errorf("event %s can not be fired by the CLI; trigger it on the device", event)
+}
+
+// localIP returns the local address used to reach hostname
+func localIP(hostname string) (string, error) {
+
+	host, _, err := net.SplitHostPort(hostname)
+	if err != nil {
+		host = hostname
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "80"))
+	if err != nil {
+		return "", fmt.Errorf("unable to determine local address for %s; use --listen: %w", hostname, err)
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// Hook is a webhook as returned by Webhook.List
+type Hook struct {
+	ID            int      `json:"id" yaml:"id"`
+	CID           int      `json:"cid" yaml:"cid"`
+	Enable        bool     `json:"enable" yaml:"enable"`
+	Event         string   `json:"event" yaml:"event"`
+	Name          string   `json:"name" yaml:"name"`
+	URLs          []string `json:"urls" yaml:"urls"`
+	Condition     *string  `json:"condition,omitempty" yaml:"condition,omitempty"`
+	RepeatPeriod  int      `json:"repeat_period,omitempty" yaml:"repeat_period,omitempty"`
+	ActiveBetween []string `json:"active_between,omitempty" yaml:"active_between,omitempty"`
+}
+
+type Hooks []*Hook
+
+func (t Hooks) Header() []string {
+	return []string{"ID", "CID", "ENABLE", "EVENT", "NAME", "URLS"}
+}
+
+func (t Hooks) Rows() [][]string {
+	var rows [][]string
+	for _, hook := range t {
+		rows = append(rows, []string{strconv.Itoa(hook.ID), strconv.Itoa(hook.CID), strconv.FormatBool(hook.Enable),
+			hook.Event, hook.Name, strings.Join(hook.URLs, " ")})
+	}
+	return rows
+}
+
+func list(ctx context.Context, client *rpc.Client) (Hooks, error) {
+
+	result := &struct {
+		Hooks Hooks `json:"hooks"`
+	}{}
+
+	err := client.Call(ctx, "Webhook.List", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Hooks, nil
+}
+
+// EventType is a supported event type and the attributes usable in URL
+// templates and conditions
+type EventType struct {
+	Event string   `json:"event" yaml:"event"`
+	Attrs []string `json:"attrs,omitempty" yaml:"attrs,omitempty"`
+}
+
+type EventTypes []*EventType
+
+func (t EventTypes) Header() []string {
+	return []string{"EVENT", "ATTRS"}
+}
+
+func (t EventTypes) Rows() [][]string {
+	var rows [][]string
+	for _, eventType := range t {
+		rows = append(rows, []string{eventType.Event, strings.Join(eventType.Attrs, ",")})
+	}
+	return rows
+}
+
+func (t EventTypes) has(event string) bool {
+	for _, eventType := range t {
+		if eventType.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// listSupported returns the supported event types sorted by event. Newer
+// firmware returns types with attributes, older firmware only hook_types.
+func listSupported(ctx context.Context, client *rpc.Client) (EventTypes, error) {
+
+	result := &struct {
+		Types map[string]struct {
+			Attrs []struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+		} `json:"types"`
+		HookTypes []string `json:"hook_types"`
+	}{}
+
+	err := client.Call(ctx, "Webhook.ListSupported", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventTypes EventTypes
+
+	for event, value := range result.Types {
+		eventType := &EventType{Event: event}
+		for _, attr := range value.Attrs {
+			eventType.Attrs = append(eventType.Attrs, attr.Name)
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	for _, event := range result.HookTypes {
+		if !eventTypes.has(event) {
+			eventTypes = append(eventTypes, &EventType{Event: event})
+		}
+	}
+
+	sort.Slice(eventTypes, func(i, j int) bool {
+		return eventTypes[i].Event < eventTypes[j].Event
+	})
+
+	return eventTypes, nil
+}
+
+// templateToken matches a ${...} token in a URL template
+var templateToken = regexp.MustCompile(`\$\{[^{}]*\}`)
+
+// validateURL validates a URL template. Tokens such as ${ev.tC} are replaced
+// before the URL is parsed; an unterminated token is an error.
+func validateURL(s string) error {
+
+	replaced := templateToken.ReplaceAllStringFunc(s, func(token string) string {
+		if strings.TrimSpace(token[2:len(token)-1]) == "" {
+			return "${}"
+		}
+		return "x"
+	})
+
+	if strings.Contains(replaced, "${") {
+		return fmt.Errorf("url %s has an empty or unterminated ${} token", s)
+	}
+
+	u, err := url.Parse(replaced)
+	if err != nil {
+		return fmt.Errorf("url %s is invalid: %w", s, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %s is invalid; expect http or https scheme", s)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("url %s is invalid; host is required", s)
+	}
+
+	return nil
+}
+
+// validateCondition checks that a condition such as `ev.tC > 30 && ev.tC < 40`
+// is not empty and has balanced brackets and terminated strings
+func validateCondition(s string) error {
+
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("condition is empty")
+	}
+
+	pairs := map[rune]rune{')': '(', ']': '['}
+
+	var stack []rune
+	var quote rune
+
+	for _, r := range s {
+
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch r {
+		case '"', '\'':
+			quote = r
+		case '(', '[':
+			stack = append(stack, r)
+		case ')', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[r] {
+				return fmt.Errorf("condition %q has an unbalanced %c", s, r)
+			}
+			stack = stack[:len(stack)-1]
+		case ';':
+			return fmt.Errorf("condition %q must be a single expression", s)
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf("condition %q has an unterminated string", s)
+	}
+
+	if len(stack) > 0 {
+		return fmt.Errorf("condition %q has an unbalanced %c", s, stack[len(stack)-1])
+	}
+
+	return nil
+}
+
+// validateTime validates an active_between time HH:MM
+func validateTime(s string) error {
+	hour, minute, ok := strings.Cut(s, ":")
+	h, err1 := strconv.Atoi(hour)
+	m, err2 := strconv.Atoi(minute)
+	if !ok || err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return fmt.Errorf("time %s is invalid; expect HH:MM", s)
+	}
+	return nil
+}
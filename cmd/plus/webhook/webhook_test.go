@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// newSwitch returns a client for a fake switch with the output set to on that
+// records the calls it receives
+func newSwitch(t *testing.T, on bool, calls *[]string) *rpc.Client {
+	return testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		switch method {
+		case "Switch.GetStatus":
+			return map[string]any{"id": 0, "output": on}, nil
+		case "Switch.Set":
+			on = params["on"].(bool)
+			*calls = append(*calls, fmt.Sprintf("%s %v", method, on))
+		default:
+			*calls = append(*calls, method)
+		}
+		return map[string]any{}, nil
+	}}).Client()
+}
+
+func TestFire(t *testing.T) {
+
+	tests := []struct {
+		event string
+		on    bool
+		calls string
+		err   string
+	}{
+		{event: "switch.on", on: true, calls: "Switch.Set false,Switch.Set true"},
+		{event: "switch.off", on: false, calls: "Switch.Set true,Switch.Set false"},
+		{event: "input.button_push", calls: "Input.Trigger"},
+		{event: "switch.on_temperature", err: "can not be fired"},
+		{event: "temperature.change", err: "can not be fired"},
+	}
+
+	for _, test := range tests {
+
+		var calls []string
+
+		err := fire(context.Background(), newSwitch(t, test.on, &calls), test.event, 0)
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error = %v; want %q", test.event, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: error = %v", test.event, err)
+			continue
+		}
+
+		if got := strings.Join(calls, ","); got != test.calls {
+			t.Errorf("%s: calls = %s; want %s", test.event, got, test.calls)
+		}
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+
+	valid := []string{
+		"ev.tC > 30",
+		"ev.tC > 30 && (ev.tC < 40 || ev.rh[0] > 1)",
+		`ev.name == "a (b"`,
+	}
+
+	for _, condition := range valid {
+		if err := validateCondition(condition); err != nil {
+			t.Errorf("validateCondition(%q) error = %v", condition, err)
+		}
+	}
+
+	invalid := map[string]string{
+		" ":               "empty",
+		"(ev.tC > 30":     "unbalanced (",
+		"ev.tC > 30)":     "unbalanced )",
+		"(ev.rh[0)]":      "unbalanced )",
+		`ev.name == "a`:   "unterminated string",
+		"ev.tC > 30; x()": "single expression",
+	}
+
+	for condition, want := range invalid {
+		err := validateCondition(condition)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("validateCondition(%q) error = %v; want %q", condition, err, want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+
+	tests := map[string]bool{
+		"http://host/path?t=${ev.tC}": true,
+		"https://host":                true,
+		"ftp://host":                  false,
+		"http:///path":                false,
+		"http://host/${ev.tC":         false,
+		"http://host/${ }":            false,
+	}
+
+	for s, want := range tests {
+		if err := validateURL(s); (err == nil) != want {
+			t.Errorf("validateURL(%q) error = %v; want valid %v", s, err, want)
+		}
+	}
+}
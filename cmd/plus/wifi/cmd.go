@@ -1,21 +1,36 @@
 package wifi
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/jodydadescott/shelly-go-sdk/plus/wifi"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
 )
 
+const joinPollInterval = 2 * time.Second
+
 type callback interface {
 	WriteStdout(any) error
+	WriteStderr(s string)
 	Wifi() (*wifi.Client, error)
+	RPC() (*rpc.Client, error)
+	ReadConfigFile(v any) error
+	SetConfig(ctx context.Context, method string, params any, disableAutoReboot bool) error
+	Inventory() (*inventory.Inventory, error)
 }
 
 func NewCmd(callback callback) *cobra.Command {
 
 	rootCmd := &cobra.Command{
 		Use:   "wifi",
-		Short: "WiFi Scan / List AP Clients",
+		Short: "WiFi Status / Config / Join / Scan / List AP Clients",
 	}
 
 	scanCmd := &cobra.Command{
@@ -58,7 +73,343 @@ func NewCmd(callback callback) *cobra.Command {
 		},
 	}
 
-	rootCmd.AddCommand(scanCmd, listAPClientsCmd)
+	getStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Returns status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.Wifi()
+			if err != nil {
+				return err
+			}
+
+			result, err := client.GetStatus(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	getConfigCmd := &cobra.Command{
+		Use:   "get-config",
+		Short: "Returns config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			client, err := callback.Wifi()
+			if err != nil {
+				return err
+			}
+
+			result, err := client.GetConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(result)
+		},
+	}
+
+	var apEnableArg bool
+	var apSSIDArg string
+	var apPassArg string
+	var apOpenArg bool
+	var rangeExtenderArg bool
+	var roamRSSIThresholdArg int
+	var roamIntervalArg int
+	var disableAutoRebootArg bool
+
+	sta := newSTAFlags("sta")
+	sta1 := newSTAFlags("sta1")
+
+	setConfigCmd := &cobra.Command{
+		Use:   "set-config",
+		Short: "Sets config from flags or from file / STDIN",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			flags := cmd.Flags()
+			config := make(map[string]any)
+
+			for _, network := range []*staFlags{sta, sta1} {
+				networkConfig, err := network.config(cmd)
+				if err != nil {
+					return err
+				}
+				if networkConfig != nil {
+					config[network.name] = networkConfig
+				}
+			}
+
+			ap := make(map[string]any)
+
+			if flags.Changed("ap-enable") {
+				ap["enable"] = apEnableArg
+			}
+
+			if flags.Changed("ap-ssid") {
+				ap["ssid"] = apSSIDArg
+			}
+
+			if flags.Changed("ap-pass") {
+				if len(apPassArg) < 8 {
+					return fmt.Errorf("ap-pass must be at least 8 characters")
+				}
+				ap["pass"] = apPassArg
+			}
+
+			if flags.Changed("ap-open") {
+				ap["is_open"] = apOpenArg
+			}
+
+			if flags.Changed("range-extender") {
+				ap["range_extender"] = map[string]any{"enable": rangeExtenderArg}
+			}
+
+			if len(ap) > 0 {
+				config["ap"] = ap
+			}
+
+			roam := make(map[string]any)
+
+			if flags.Changed("roam-rssi-thr") {
+				if roamRSSIThresholdArg > 0 || roamRSSIThresholdArg < -100 {
+					return fmt.Errorf("roam-rssi-thr must be -100 to 0 dBm")
+				}
+				roam["rssi_thr"] = roamRSSIThresholdArg
+			}
+
+			if flags.Changed("roam-interval") {
+				if roamIntervalArg < 0 {
+					return fmt.Errorf("roam-interval must not be negative")
+				}
+				roam["interval"] = roamIntervalArg
+			}
+
+			if len(roam) > 0 {
+				config["roam"] = roam
+			}
+
+			if len(config) == 0 {
+				err := callback.ReadConfigFile(&config)
+				if err != nil {
+					return err
+				}
+			}
+
+			return callback.SetConfig(cmd.Context(), "Wifi.SetConfig", map[string]any{
+				"config": config,
+			}, disableAutoRebootArg)
+		},
+	}
+
+	sta.register(setConfigCmd, "station network")
+	sta1.register(setConfigCmd, "fallback station network")
+
+	setConfigCmd.Flags().BoolVar(&apEnableArg, "ap-enable", false, "enable access point")
+	setConfigCmd.Flags().StringVar(&apSSIDArg, "ap-ssid", "", "access point SSID")
+	setConfigCmd.Flags().StringVar(&apPassArg, "ap-pass", "", "access point password")
+	setConfigCmd.Flags().BoolVar(&apOpenArg, "ap-open", false, "access point does not require a password")
+	setConfigCmd.Flags().BoolVar(&rangeExtenderArg, "range-extender", false, "enable range extender mode for the access point")
+	setConfigCmd.Flags().IntVar(&roamRSSIThresholdArg, "roam-rssi-thr", 0, "RSSI (dBm) below which the device looks for a better access point")
+	setConfigCmd.Flags().IntVar(&roamIntervalArg, "roam-interval", 0, "seconds between roam scans; 0 disables roaming")
+	setConfigCmd.Flags().BoolVar(&disableAutoRebootArg, "disable-autoreboot", false, "disable automatic reboot (if reboot is necessary)")
+
+	var joinSSIDArg string
+	var joinPassArg string
+	var joinFallbackArg bool
+	var joinTimeoutArg time.Duration
+	var joinForceArg bool
+
+	joinCmd := &cobra.Command{
+		Use:   "join",
+		Short: "Joins a network and verifies connectivity before and after the change",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if joinSSIDArg == "" {
+				return fmt.Errorf("ssid is required")
+			}
+
+			client, err := callback.RPC()
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+
+			result := &joinResult{Before: &wifiStatus{}}
+
+			err = client.Call(ctx, "Wifi.GetStatus", nil, result.Before)
+			if err != nil {
+				return err
+			}
+
+			current := &wifiConfig{}
+
+			err = client.Call(ctx, "Wifi.GetConfig", nil, current)
+			if err != nil {
+				return err
+			}
+
+			network := "sta"
+			fallbackEnabled := current.STA1.Enable
+			if joinFallbackArg {
+				network = "sta1"
+				fallbackEnabled = current.STA.Enable
+			}
+
+			if !current.AP.Enable && !fallbackEnabled {
+				if !joinForceArg {
+					return fmt.Errorf("access point is disabled and no other station network is enabled; the device will be unreachable if it cannot join %s. Use --force to continue", joinSSIDArg)
+				}
+				callback.WriteStderr(fmt.Sprintf("warning: access point is disabled and no other station network is enabled; the device will be unreachable if it cannot join %s", joinSSIDArg))
+			}
+
+			viaSTA := false
+			if host, _, err := net.SplitHostPort(client.Hostname()); err == nil {
+				viaSTA = result.Before.StaIP != nil && host == *result.Before.StaIP
+			} else {
+				viaSTA = result.Before.StaIP != nil && client.Hostname() == *result.Before.StaIP
+			}
+
+			if viaSTA && network == "sta" {
+				callback.WriteStderr(fmt.Sprintf("warning: device is reached through its station address %s; it may get a different address on %s", *result.Before.StaIP, joinSSIDArg))
+			}
+
+			networkConfig := map[string]any{
+				"ssid":   joinSSIDArg,
+				"enable": true,
+			}
+
+			if joinPassArg != "" {
+				networkConfig["pass"] = joinPassArg
+			}
+
+			err = client.Call(ctx, "Wifi.SetConfig", map[string]any{
+				"config": map[string]any{network: networkConfig},
+			}, nil)
+			if err != nil {
+				return err
+			}
+
+			callback.WriteStderr(fmt.Sprintf("waiting up to %s for device to join %s", joinTimeoutArg, joinSSIDArg))
+
+			after, err := waitForJoin(ctx, client, joinSSIDArg, joinTimeoutArg)
+			result.After = after
+
+			writeErr := callback.WriteStdout(result)
+			if err != nil {
+				if viaSTA {
+					return fmt.Errorf("%w; the device may have a new address on %s", err, joinSSIDArg)
+				}
+				return err
+			}
+
+			return writeErr
+		},
+	}
+
+	joinCmd.Flags().StringVar(&joinSSIDArg, "ssid", "", "network SSID")
+	joinCmd.Flags().StringVar(&joinPassArg, "pass", "", "network password")
+	joinCmd.Flags().BoolVar(&joinFallbackArg, "fallback", false, "configure the fallback network (sta1) instead of the primary network (sta)")
+	joinCmd.Flags().DurationVar(&joinTimeoutArg, "timeout", 60*time.Second, "time to wait for the device to join")
+	joinCmd.Flags().BoolVar(&joinForceArg, "force", false, "continue even if the change could leave the device unreachable")
+
+	var surveyDevicesArg []string
+	var surveySamplesArg int
+	var surveyIntervalArg time.Duration
+	var surveyThresholdArg int
+	var surveyNoScanArg bool
+
+	surveyCmd := &cobra.Command{
+		Use:   "survey",
+		Short: "Samples signal strength and channel congestion of inventory devices and reports weak devices",
+		Long: "Samples signal strength and channel congestion of inventory devices and reports weak devices. " +
+			"Status and scan errors are counted separately. Roams are detected from changes of the SSID and of the " +
+			"BSSID; firmware that does not report the BSSID in Wifi.GetStatus only shows SSID changes, marked with * " +
+			"in the ROAMS column of tables and bssid false otherwise. The state of a device is weak if its average RSSI is " +
+			"below the threshold, unreachable if every status read failed, no_rssi if the firmware reported no RSSI and " +
+			"skipped for Gen1 devices.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if surveySamplesArg < 1 {
+				return fmt.Errorf("samples must be at least 1")
+			}
+
+			if surveyIntervalArg <= 0 {
+				return fmt.Errorf("interval must be positive")
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			devices, err := inv.Select(surveyDevicesArg)
+			if err != nil {
+				return err
+			}
+
+			callback.WriteStderr(fmt.Sprintf("surveying %d devices; %d samples every %s", len(devices), surveySamplesArg, surveyIntervalArg))
+
+			reports := survey(cmd.Context(), devices, &surveyConfig{
+				samples:   surveySamplesArg,
+				interval:  surveyIntervalArg,
+				threshold: surveyThresholdArg,
+				scan:      !surveyNoScanArg,
+			})
+
+			return callback.WriteStdout(reports)
+		},
+	}
+
+	surveyCmd.Flags().StringSliceVar(&surveyDevicesArg, "devices", nil, "inventory device names; defaults to all devices")
+	surveyCmd.Flags().IntVar(&surveySamplesArg, "samples", 5, "number of samples per device")
+	surveyCmd.Flags().DurationVar(&surveyIntervalArg, "interval", 10*time.Second, "interval between samples")
+	surveyCmd.Flags().IntVar(&surveyThresholdArg, "threshold", -70, "average RSSI (dBm) below which a device is flagged as weak")
+	surveyCmd.Flags().BoolVar(&surveyNoScanArg, "no-scan", false, "do not scan for access points; scanning briefly interrupts connectivity")
+
+	rootCmd.AddCommand(getStatusCmd, getConfigCmd, setConfigCmd, joinCmd, scanCmd, listAPClientsCmd, surveyCmd)
 
 	return rootCmd
 }
+
+// waitForJoin polls the wifi status until the device is connected to ssid. Errors
+// while polling are expected as the device reconnects.
+func waitForJoin(ctx context.Context, client *rpc.Client, ssid string, timeout time.Duration) (*wifiStatus, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(joinPollInterval)
+	defer ticker.Stop()
+
+	var last *wifiStatus
+	var lastErr error
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			if last != nil {
+				return last, fmt.Errorf("device did not join %s; status is %s", ssid, last.Status)
+			}
+			return nil, fmt.Errorf("device did not respond after joining %s: %v", ssid, lastErr)
+		case <-ticker.C:
+		}
+
+		s := &wifiStatus{}
+
+		err := client.Call(ctx, "Wifi.GetStatus", nil, s)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		last = s
+
+		if s.connected() && s.SSID != nil && *s.SSID == ssid {
+			return s, nil
+		}
+	}
+}
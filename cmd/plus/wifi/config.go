@@ -0,0 +1,121 @@
+package wifi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// staFlags are the flags for a station network (sta or sta1)
+type staFlags struct {
+	name       string
+	ssid       string
+	pass       string
+	enable     bool
+	ipv4mode   string
+	ip         string
+	netmask    string
+	gw         string
+	nameserver string
+}
+
+func newSTAFlags(name string) *staFlags {
+	return &staFlags{name: name}
+}
+
+func (t *staFlags) register(cmd *cobra.Command, description string) {
+	cmd.Flags().StringVar(&t.ssid, t.name+"-ssid", "", description+" SSID")
+	cmd.Flags().StringVar(&t.pass, t.name+"-pass", "", description+" password")
+	cmd.Flags().BoolVar(&t.enable, t.name+"-enable", false, "enable "+description)
+	cmd.Flags().StringVar(&t.ipv4mode, t.name+"-ipv4mode", "", description+" IPv4 mode. One of: dhcp | static")
+	cmd.Flags().StringVar(&t.ip, t.name+"-ip", "", description+" static IP")
+	cmd.Flags().StringVar(&t.netmask, t.name+"-netmask", "", description+" static netmask")
+	cmd.Flags().StringVar(&t.gw, t.name+"-gw", "", description+" static gateway")
+	cmd.Flags().StringVar(&t.nameserver, t.name+"-nameserver", "", description+" static nameserver")
+}
+
+// config returns the network config for the changed flags or nil if no flag was
+// changed
+func (t *staFlags) config(cmd *cobra.Command) (map[string]any, error) {
+
+	flags := cmd.Flags()
+	config := make(map[string]any)
+
+	if flags.Changed(t.name + "-ssid") {
+		config["ssid"] = t.ssid
+	}
+
+	if flags.Changed(t.name + "-pass") {
+		config["pass"] = t.pass
+	}
+
+	if flags.Changed(t.name + "-enable") {
+		config["enable"] = t.enable
+	}
+
+	if flags.Changed(t.name + "-ipv4mode") {
+		if t.ipv4mode != "dhcp" && t.ipv4mode != "static" {
+			return nil, fmt.Errorf("%s-ipv4mode must be dhcp or static", t.name)
+		}
+		config["ipv4mode"] = t.ipv4mode
+	}
+
+	for key, value := range map[string]string{
+		"ip":         t.ip,
+		"netmask":    t.netmask,
+		"gw":         t.gw,
+		"nameserver": t.nameserver,
+	} {
+		if !flags.Changed(t.name + "-" + key) {
+			continue
+		}
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+			return nil, fmt.Errorf("%s-%s %s is not a valid IPv4 address", t.name, key, value)
+		}
+		config[key] = value
+	}
+
+	if config["ipv4mode"] == "static" && config["ip"] == nil {
+		return nil, fmt.Errorf("%s-ip is required for static ipv4mode", t.name)
+	}
+
+	if len(config) == 0 {
+		return nil, nil
+	}
+
+	return config, nil
+}
+
+// wifiStatus is the subset of Wifi.GetStatus used by join
+type wifiStatus struct {
+	StaIP  *string `json:"sta_ip" yaml:"sta_ip"`
+	Status string  `json:"status" yaml:"status"`
+	SSID   *string `json:"ssid" yaml:"ssid"`
+	RSSI   *int    `json:"rssi" yaml:"rssi"`
+}
+
+func (t *wifiStatus) connected() bool {
+	return t.Status == "got ip"
+}
+
+// wifiConfig is the subset of Wifi.GetConfig used by join
+type wifiConfig struct {
+	AP struct {
+		Enable bool `json:"enable"`
+	} `json:"ap"`
+	STA struct {
+		SSID   *string `json:"ssid"`
+		Enable bool    `json:"enable"`
+	} `json:"sta"`
+	STA1 struct {
+		SSID   *string `json:"ssid"`
+		Enable bool    `json:"enable"`
+	} `json:"sta1"`
+}
+
+type joinResult struct {
+	Before *wifiStatus `json:"before" yaml:"before"`
+	After  *wifiStatus `json:"after,omitempty" yaml:"after,omitempty"`
+}
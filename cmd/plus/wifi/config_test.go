@@ -0,0 +1,61 @@
+package wifi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSTAFlagsConfig(t *testing.T) {
+
+	tests := []struct {
+		args []string
+		want map[string]any
+		err  string
+	}{
+		{args: nil, want: nil},
+		{args: []string{"--sta-ssid", "home", "--sta-pass", "secret", "--sta-enable"}, want: map[string]any{"ssid": "home", "pass": "secret", "enable": true}},
+		{args: []string{"--sta-enable=false"}, want: map[string]any{"enable": false}},
+		{
+			args: []string{"--sta-ipv4mode", "static", "--sta-ip", "192.168.1.20", "--sta-netmask", "255.255.255.0", "--sta-gw", "192.168.1.1"},
+			want: map[string]any{"ipv4mode": "static", "ip": "192.168.1.20", "netmask": "255.255.255.0", "gw": "192.168.1.1"},
+		},
+		{args: []string{"--sta-ipv4mode", "auto"}, err: "must be dhcp or static"},
+		{args: []string{"--sta-ipv4mode", "static"}, err: "sta-ip is required"},
+		{args: []string{"--sta-ip", "192.168.1"}, err: "not a valid IPv4 address"},
+		{args: []string{"--sta-ip", "fe80::1"}, err: "not a valid IPv4 address"},
+		{args: []string{"--sta-nameserver", "::ffff:1.1.1.1"}, err: "not a valid IPv4 address"},
+	}
+
+	for _, test := range tests {
+
+		flags := newSTAFlags("sta")
+		cmd := &cobra.Command{}
+		flags.register(cmd, "station")
+
+		err := cmd.ParseFlags(test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := flags.config(cmd)
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v: error = %v; want %q", test.args, err, test.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: error = %v", test.args, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: config = %v; want %v", test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package wifi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+// scanResult is an access point returned by Wifi.Scan
+type scanResult struct {
+	SSID    *string `json:"ssid"`
+	BSSID   string  `json:"bssid"`
+	Channel int     `json:"channel"`
+	RSSI    int     `json:"rssi"`
+}
+
+// surveyStatus is the subset of Wifi.GetStatus used by survey. Not all
+// firmware versions report the BSSID.
+type surveyStatus struct {
+	Status string  `json:"status"`
+	SSID   *string `json:"ssid"`
+	BSSID  *string `json:"bssid"`
+	RSSI   *int    `json:"rssi"`
+}
+
+// Survey states of a device
+const (
+	surveyOK          = "ok"
+	surveyWeak        = "weak"
+	surveyUnreachable = "unreachable"
+	surveyNoRSSI      = "no_rssi"
+	surveySkipped     = "skipped"
+)
+
+// surveyReport is the survey result of a single device. Samples counts the
+// status reads; StatusErrors and ScanErrors count the failed status reads and
+// scans. State is weak only if the average RSSI is below the threshold; a
+// device whose status reads all failed is unreachable and Gen1 devices are
+// skipped. Roams counts changes of the SSID and, if the firmware reports it in
+// Wifi.GetStatus, of the BSSID; BSSID is false if it does not, in which case
+// roaming between access points of the same SSID is not detected.
+type surveyReport struct {
+	Device       string  `json:"device" yaml:"device"`
+	SSID         string  `json:"ssid,omitempty" yaml:"ssid,omitempty"`
+	Channel      int     `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Samples      int     `json:"samples" yaml:"samples"`
+	StatusErrors int     `json:"status_errors" yaml:"status_errors"`
+	ScanErrors   int     `json:"scan_errors" yaml:"scan_errors"`
+	MinRSSI      *int    `json:"min_rssi,omitempty" yaml:"min_rssi,omitempty"`
+	AvgRSSI      float64 `json:"avg_rssi,omitempty" yaml:"avg_rssi,omitempty"`
+	MaxRSSI      *int    `json:"max_rssi,omitempty" yaml:"max_rssi,omitempty"`
+	ChannelAPs   int     `json:"channel_aps" yaml:"channel_aps"`
+	VisibleAPs   int     `json:"visible_aps" yaml:"visible_aps"`
+	Roams        int     `json:"roams" yaml:"roams"`
+	BSSID        bool    `json:"bssid" yaml:"bssid"`
+	State        string  `json:"state" yaml:"state"`
+	Weak         bool    `json:"weak" yaml:"weak"`
+	LastError    string  `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	rssiSum      int
+	rssiSamples  int
+	bssid        string
+}
+
+func (t *surveyReport) addRSSI(rssi int) {
+
+	if t.MinRSSI == nil || rssi < *t.MinRSSI {
+		t.MinRSSI = &rssi
+	}
+
+	if t.MaxRSSI == nil || rssi > *t.MaxRSSI {
+		v := rssi
+		t.MaxRSSI = &v
+	}
+
+	t.rssiSum += rssi
+	t.rssiSamples++
+	t.AvgRSSI = math.Round(float64(t.rssiSum)/float64(t.rssiSamples)*10) / 10
+}
+
+// addScan records the channel of the connected access point and the number of
+// access points sharing or overlapping it
+func (t *surveyReport) addScan(results []*scanResult) {
+
+	t.VisibleAPs = len(results)
+
+	var connected *scanResult
+
+	for _, result := range results {
+		if t.bssid != "" && result.BSSID == t.bssid {
+			connected = result
+			break
+		}
+		if result.SSID != nil && *result.SSID == t.SSID && (connected == nil || result.RSSI > connected.RSSI) {
+			connected = result
+		}
+	}
+
+	if connected == nil {
+		return
+	}
+
+	t.Channel = connected.Channel
+
+	count := 0
+	for _, result := range results {
+		if result != connected && channelsOverlap(result.Channel, connected.Channel) {
+			count++
+		}
+	}
+
+	if count > t.ChannelAPs {
+		t.ChannelAPs = count
+	}
+}
+
+// channelsOverlap returns true if the 2.4GHz channels a and b overlap (less
+// than five channels apart) or if the 5GHz channels are equal
+func channelsOverlap(a, b int) bool {
+	if a > 14 || b > 14 {
+		return a == b
+	}
+	return int(math.Abs(float64(a-b))) < 5
+}
+
+type surveyReports []*surveyReport
+
+func (t surveyReports) Header() []string {
+	return []string{"DEVICE", "SSID", "CHANNEL", "SAMPLES", "STATUS_ERRORS", "SCAN_ERRORS", "MIN", "AVG", "MAX", "CHANNEL_APS", "VISIBLE_APS", "ROAMS", "STATE"}
+}
+
+func (t surveyReports) Rows() [][]string {
+
+	optional := func(v *int) string {
+		if v == nil {
+			return "-"
+		}
+		return strconv.Itoa(*v)
+	}
+
+	var rows [][]string
+
+	for _, report := range t {
+
+		avg := "-"
+		if report.rssiSamples > 0 {
+			avg = fmt.Sprintf("%.1f", report.AvgRSSI)
+		}
+
+		state := ""
+		if report.State != surveyOK {
+			state = strings.ToUpper(report.State)
+		}
+
+		roams := strconv.Itoa(report.Roams)
+		if !report.BSSID {
+			// Roams between access points of the same SSID are not detected
+			roams += "*"
+		}
+
+		rows = append(rows, []string{
+			report.Device,
+			report.SSID,
+			strconv.Itoa(report.Channel),
+			strconv.Itoa(report.Samples),
+			strconv.Itoa(report.StatusErrors),
+			strconv.Itoa(report.ScanErrors),
+			optional(report.MinRSSI),
+			avg,
+			optional(report.MaxRSSI),
+			strconv.Itoa(report.ChannelAPs),
+			strconv.Itoa(report.VisibleAPs),
+			roams,
+			state,
+		})
+	}
+
+	return rows
+}
+
+type surveyConfig struct {
+	samples   int
+	interval  time.Duration
+	threshold int
+	scan      bool
+}
+
+// survey concurrently samples the wifi status (and optionally scans) of each
+// device and returns a report per device
+func survey(ctx context.Context, devices []*inventory.Device, config *surveyConfig) surveyReports {
+
+	reports := make(surveyReports, len(devices))
+
+	var wg sync.WaitGroup
+
+	for i, device := range devices {
+
+		report := &surveyReport{Device: device.Name}
+		reports[i] = report
+
+		if device.Gen == 1 {
+			// Gen1 devices have no Wifi.GetStatus
+			report.State = surveySkipped
+			report.LastError = "Gen1 device"
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(device *inventory.Device, report *surveyReport) {
+			defer wg.Done()
+			surveyDevice(ctx, device, report, config)
+		}(device, report)
+	}
+
+	wg.Wait()
+
+	for _, report := range reports {
+		switch {
+		case report.State == surveySkipped:
+		case report.StatusErrors == report.Samples:
+			report.State = surveyUnreachable
+		case report.rssiSamples == 0:
+			report.State = surveyNoRSSI
+		case report.AvgRSSI < float64(config.threshold):
+			report.State = surveyWeak
+			report.Weak = true
+		default:
+			report.State = surveyOK
+		}
+	}
+
+	return reports
+}
+
+func surveyDevice(ctx context.Context, device *inventory.Device, report *surveyReport, config *surveyConfig) {
+
+	client := device.Client()
+
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+
+	for sample := 0; sample < config.samples; sample++ {
+
+		if sample > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		report.Samples++
+
+		status := &surveyStatus{}
+
+		err := client.Call(ctx, "Wifi.GetStatus", nil, status)
+		if err != nil {
+			report.StatusErrors++
+			report.LastError = err.Error()
+			continue
+		}
+
+		if status.RSSI != nil {
+			report.addRSSI(*status.RSSI)
+		}
+
+		roamed := false
+
+		if status.SSID != nil {
+			roamed = report.SSID != "" && report.SSID != *status.SSID
+			report.SSID = *status.SSID
+		}
+
+		if status.BSSID != nil {
+			report.BSSID = true
+			roamed = roamed || (report.bssid != "" && report.bssid != *status.BSSID)
+			report.bssid = *status.BSSID
+		}
+
+		if roamed {
+			report.Roams++
+		}
+
+		if !config.scan {
+			continue
+		}
+
+		scan := &struct {
+			Results []*scanResult `json:"results"`
+		}{}
+
+		err = client.Call(ctx, "Wifi.Scan", nil, scan)
+		if err != nil {
+			report.ScanErrors++
+			report.LastError = err.Error()
+			continue
+		}
+
+		report.addScan(scan.Results)
+	}
+}
@@ -0,0 +1,161 @@
+package wifi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// newSurveyDevice returns a device that answers Wifi.GetStatus with statuses
+// in turn and fails every Wifi.Scan
+func newSurveyDevice(t *testing.T, statuses ...string) *inventory.Device {
+
+	next := 0
+
+	return testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		if method != "Wifi.GetStatus" || next >= len(statuses) {
+			return nil, &rpc.Error{Code: -114, Message: "unavailable"}
+		}
+		next++
+		return json.RawMessage(statuses[next-1]), nil
+	}}).Inventory("dev")
+}
+
+func TestSurveyDevice(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		statuses []string
+		scan     bool
+		check    func(*surveyReport) bool
+	}{
+		{
+			name: "bssid roam",
+			statuses: []string{
+				`{"ssid":"home","bssid":"aa","rssi":-60}`,
+				`{"ssid":"home","bssid":"bb","rssi":-50}`,
+				`{"ssid":"home","bssid":"bb","rssi":-70}`,
+			},
+			check: func(r *surveyReport) bool {
+				return r.Roams == 1 && r.BSSID && r.AvgRSSI == -60 && *r.MinRSSI == -70 && *r.MaxRSSI == -50
+			},
+		},
+		{
+			name: "ssid roam without bssid",
+			statuses: []string{
+				`{"ssid":"home","rssi":-60}`,
+				`{"ssid":"guest","rssi":-60}`,
+				`{"ssid":"guest","rssi":-60}`,
+			},
+			check: func(r *surveyReport) bool {
+				return r.Roams == 1 && !r.BSSID
+			},
+		},
+		{
+			name: "separate error counts",
+			statuses: []string{
+				`{"ssid":"home","rssi":-60}`,
+			},
+			scan: true,
+			check: func(r *surveyReport) bool {
+				// One status and scan, then two failed status reads
+				return r.Samples == 3 && r.StatusErrors == 2 && r.ScanErrors == 1
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		report := &surveyReport{}
+
+		surveyDevice(context.Background(), newSurveyDevice(t, test.statuses...), report, &surveyConfig{
+			samples:  3,
+			interval: time.Millisecond,
+			scan:     test.scan,
+		})
+
+		if !test.check(report) {
+			data, _ := json.Marshal(report)
+			t.Errorf("%s: unexpected report %s", test.name, data)
+		}
+	}
+}
+
+func TestSurveyState(t *testing.T) {
+
+	devices := []*inventory.Device{
+		newSurveyDevice(t, `{"ssid":"home","rssi":-60}`),
+		newSurveyDevice(t, `{"ssid":"home","rssi":-80}`),
+		newSurveyDevice(t),
+		newSurveyDevice(t, `{"status":"disconnected"}`),
+		{Name: "relay", Hostname: "127.0.0.1:1", Gen: 1},
+	}
+
+	reports := survey(context.Background(), devices, &surveyConfig{samples: 1, interval: time.Millisecond, threshold: -70})
+
+	for i, want := range []string{surveyOK, surveyWeak, surveyUnreachable, surveyNoRSSI, surveySkipped} {
+		if got := reports[i].State; got != want {
+			t.Errorf("device %d: state = %s; want %s", i, got, want)
+		}
+		if reports[i].Weak != (want == surveyWeak) {
+			t.Errorf("device %d: weak = %v", i, reports[i].Weak)
+		}
+	}
+}
+
+func TestAddScan(t *testing.T) {
+
+	home := "home"
+	other := "other"
+
+	report := &surveyReport{SSID: home}
+
+	report.addScan([]*scanResult{
+		{SSID: &home, BSSID: "aa", Channel: 6, RSSI: -70},
+		{SSID: &home, BSSID: "bb", Channel: 1, RSSI: -50},
+		{SSID: &other, BSSID: "cc", Channel: 4, RSSI: -80},
+		{SSID: &other, BSSID: "dd", Channel: 11, RSSI: -80},
+		{BSSID: "ee", Channel: 36, RSSI: -80},
+	})
+
+	if report.Channel != 1 || report.ChannelAPs != 1 || report.VisibleAPs != 5 {
+		t.Errorf("got channel %d, channel APs %d, visible APs %d; want 1, 1, 5", report.Channel, report.ChannelAPs, report.VisibleAPs)
+	}
+
+	report = &surveyReport{SSID: home, bssid: "aa"}
+	report.addScan([]*scanResult{
+		{SSID: &home, BSSID: "aa", Channel: 6, RSSI: -70},
+		{SSID: &home, BSSID: "bb", Channel: 1, RSSI: -50},
+	})
+
+	if report.Channel != 6 {
+		t.Errorf("got channel %d; want the channel of the connected BSSID", report.Channel)
+	}
+}
+
+func TestChannelsOverlap(t *testing.T) {
+
+	tests := []struct {
+		a, b int
+		want bool
+	}{
+		{1, 1, true},
+		{1, 5, true},
+		{1, 6, false},
+		{6, 11, false},
+		{36, 36, true},
+		{36, 40, false},
+		{11, 36, false},
+	}
+
+	for _, test := range tests {
+		if got := channelsOverlap(test.a, test.b); got != test.want {
+			t.Errorf("channelsOverlap(%d, %d) = %v; want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
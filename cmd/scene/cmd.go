@@ -0,0 +1,153 @@
+package scene
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+type callback interface {
+	WriteStdout(any) error
+	WriteStderr(string)
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var devicesArg []string
+	var componentsArg []string
+	var transitionArg time.Duration
+	var ignoreErrorsArg bool
+
+	rootCmd := &cobra.Command{
+		Use:   "scene",
+		Short: "Save and recall switch and light states across inventory devices",
+	}
+
+	saveCmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Captures the current component states of devices into a scene",
+		Long: "Captures the current component states of devices into a scene. Devices without matching components " +
+			"(such as sensors and Gen1 devices) are skipped. If a device can not be read the scene is not saved unless " +
+			"--ignore-errors is set.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			devices, err := inv.Select(devicesArg)
+			if err != nil {
+				return err
+			}
+
+			scene, captureErr := Capture(cmd.Context(), args[0], devices, componentsArg)
+
+			var errs *multierror.Error
+			if errors.As(captureErr, &errs) {
+				for _, err := range errs.Errors {
+					callback.WriteStderr(err.Error())
+				}
+				if !ignoreErrorsArg {
+					return fmt.Errorf("%d of %d devices could not be read; use --ignore-errors to save the scene without them", len(errs.Errors), len(devices))
+				}
+			}
+
+			if len(scene.Devices) == 0 {
+				return fmt.Errorf("no matching components found")
+			}
+
+			err = save(scene)
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(scene)
+		},
+	}
+
+	saveCmd.Flags().StringSliceVar(&devicesArg, "devices", nil, "inventory device names; defaults to all devices")
+	saveCmd.Flags().BoolVar(&ignoreErrorsArg, "ignore-errors", false, "save the scene without the devices that can not be read")
+	saveCmd.Flags().StringSliceVar(&componentsArg, "components", nil, "component types (light) or keys (light:0) to capture; defaults to all switch, light, rgb, rgbw and cct components")
+
+	applyCmd := &cobra.Command{
+		Use:   "apply <name>",
+		Short: "Restores the component states of a scene",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if transitionArg < 0 {
+				return fmt.Errorf("transition must not be negative")
+			}
+
+			scene, err := load(args[0])
+			if err != nil {
+				return err
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			results, applyErr := Apply(cmd.Context(), scene, inv, transitionArg.Seconds())
+
+			err = callback.WriteStdout(results)
+			if err != nil {
+				return err
+			}
+
+			return applyErr
+		},
+	}
+
+	applyCmd.Flags().DurationVar(&transitionArg, "transition", 0, "transition duration for lights such as 2s")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists scenes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scenes, err := list()
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(scenes)
+		},
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Shows a scene",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			scene, err := load(args[0])
+			if err != nil {
+				return err
+			}
+
+			return callback.WriteStdout(scene)
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Removes a scene",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return remove(args[0])
+		},
+	}
+
+	rootCmd.AddCommand(saveCmd, applyCmd, listCmd, showCmd, removeCmd)
+	return rootCmd
+}
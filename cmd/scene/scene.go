@@ -0,0 +1,465 @@
+package scene
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/light"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const scenesDirName = "scenes"
+
+// components are the component types captured by scenes
+var components = []string{"switch", "light", "rgb", "rgbw", "cct"}
+
+type Scene struct {
+	Name    string         `json:"name" yaml:"name"`
+	Devices []*DeviceState `json:"devices" yaml:"devices"`
+}
+
+type DeviceState struct {
+	Device     string            `json:"device" yaml:"device"`
+	Components []*ComponentState `json:"components" yaml:"components"`
+}
+
+type ComponentState struct {
+	Key           string `json:"key" yaml:"key"`
+	channel.State `yaml:",inline"`
+}
+
+// Result is the result of applying a single component state
+type Result struct {
+	Device    string `json:"device" yaml:"device"`
+	Component string `json:"component" yaml:"component"`
+	State     string `json:"state" yaml:"state"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type Results []*Result
+
+func (t Results) Header() []string {
+	return []string{"DEVICE", "COMPONENT", "STATE", "ERROR"}
+}
+
+func (t Results) Rows() [][]string {
+	var rows [][]string
+	for _, result := range t {
+		rows = append(rows, []string{result.Device, result.Component, result.State, result.Error})
+	}
+	return rows
+}
+
+type sceneList []*Scene
+
+func (t sceneList) Header() []string {
+	return []string{"NAME", "DEVICES", "COMPONENTS"}
+}
+
+func (t sceneList) Rows() [][]string {
+	var rows [][]string
+	for _, scene := range t {
+		count := 0
+		for _, device := range scene.Devices {
+			count += len(device.Components)
+		}
+		rows = append(rows, []string{scene.Name, strconv.Itoa(len(scene.Devices)), strconv.Itoa(count)})
+	}
+	return rows
+}
+
+func scenesDir() (string, error) {
+	dir, err := inventory.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, scenesDirName), nil
+}
+
+func sceneFile(name string) (string, error) {
+
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("scene name %q is invalid", name)
+	}
+
+	dir, err := scenesDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+func load(name string) (*Scene, error) {
+
+	filename, err := sceneFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("scene %s not found", name)
+		}
+		return nil, err
+	}
+
+	scene := &Scene{}
+
+	err = yaml.Unmarshal(data, scene)
+	if err != nil {
+		return nil, fmt.Errorf("scene %s is invalid: %w", name, err)
+	}
+
+	return scene, nil
+}
+
+func save(scene *Scene) error {
+
+	filename, err := sceneFile(scene.Name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(scene)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0600)
+}
+
+func remove(name string) error {
+
+	filename, err := sceneFile(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("scene %s not found", name)
+	}
+
+	return err
+}
+
+func list() (sceneList, error) {
+
+	dir, err := scenesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sceneList{}, nil
+		}
+		return nil, err
+	}
+
+	var scenes sceneList
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+		scene, err := load(strings.TrimSuffix(entry.Name(), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		scenes = append(scenes, scene)
+	}
+
+	return scenes, nil
+}
+
+// matches returns true if the component key (for example light:1) is selected by
+// filters. Each filter is a component type (light) or key (light:1). An empty
+// filter list selects all supported components.
+func matches(key string, filters []string) bool {
+
+	componentType := strings.SplitN(key, ":", 2)[0]
+
+	supported := false
+	for _, c := range components {
+		if c == componentType {
+			supported = true
+		}
+	}
+
+	if !supported {
+		return false
+	}
+
+	if len(filters) == 0 {
+		return true
+	}
+
+	for _, filter := range filters {
+		if strings.EqualFold(filter, key) || strings.EqualFold(filter, componentType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// capture returns the current state of the selected components of device or
+// nil if the device has none. Gen1 devices have none.
+func capture(ctx context.Context, device *inventory.Device, filters []string) (*DeviceState, error) {
+
+	if device.Gen == 1 {
+		return nil, nil
+	}
+
+	var status map[string]json.RawMessage
+
+	err := device.Client().Call(ctx, "Shelly.GetStatus", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceState := &DeviceState{Device: device.Name}
+
+	for key, raw := range status {
+
+		if !matches(key, filters) {
+			continue
+		}
+
+		componentState := &ComponentState{Key: key}
+
+		err := json.Unmarshal(raw, &componentState.State)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+
+		deviceState.Components = append(deviceState.Components, componentState)
+	}
+
+	if len(deviceState.Components) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(deviceState.Components, func(i, j int) bool {
+		return deviceState.Components[i].Key < deviceState.Components[j].Key
+	})
+
+	return deviceState, nil
+}
+
+// Capture captures the selected component states of devices into a new scene.
+// Devices without matching components are left out. The returned error lists
+// each device that could not be read; the scene holds the other devices.
+func Capture(ctx context.Context, name string, devices []*inventory.Device, filters []string) (*Scene, error) {
+
+	deviceStates := make([]*DeviceState, len(devices))
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var errors *multierror.Error
+
+	for i, device := range devices {
+
+		wg.Add(1)
+
+		go func(i int, device *inventory.Device) {
+
+			defer wg.Done()
+
+			deviceState, err := capture(ctx, device, filters)
+			if err != nil {
+				mutex.Lock()
+				errors = multierror.Append(errors, fmt.Errorf("device %s: %w", device.Name, err))
+				mutex.Unlock()
+				return
+			}
+
+			deviceStates[i] = deviceState
+
+		}(i, device)
+	}
+
+	wg.Wait()
+
+	scene := &Scene{Name: name}
+
+	for _, deviceState := range deviceStates {
+		if deviceState != nil {
+			scene.Devices = append(scene.Devices, deviceState)
+		}
+	}
+
+	return scene, errors.ErrorOrNil()
+}
+
+// setParams returns the Set method and params that restore state
+func setParams(state *ComponentState, transition float64) (string, map[string]any, error) {
+
+	split := strings.SplitN(state.Key, ":", 2)
+	if len(split) != 2 {
+		return "", nil, fmt.Errorf("component key %s is invalid", state.Key)
+	}
+
+	id, err := strconv.Atoi(split[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("component key %s is invalid", state.Key)
+	}
+
+	if state.Output == nil {
+		return "", nil, fmt.Errorf("component %s has no output state", state.Key)
+	}
+
+	params := map[string]any{
+		"id": id,
+		"on": *state.Output,
+	}
+
+	if split[0] != "switch" && *state.Output {
+
+		if state.Brightness != nil {
+			params["brightness"] = *state.Brightness
+		}
+
+		if len(state.RGB) == 3 {
+			params["rgb"] = state.RGB
+		}
+
+		if state.White != nil {
+			params["white"] = *state.White
+		}
+
+		if state.CT != nil {
+			params["ct"] = *state.CT
+		}
+
+		if transition > 0 {
+			params["transition_duration"] = transition
+		}
+	}
+
+	return rpc.Method(split[0], "Set"), params, nil
+}
+
+// set calls method with params. Firmware that does not support
+// transition_duration rejects it; the brightness is then faded in software as
+// the light commands do.
+func set(ctx context.Context, client *rpc.Client, key string, method string, params map[string]any, transition float64) error {
+
+	err := client.Call(ctx, method, params, nil)
+
+	var rpcErr *rpc.Error
+	if _, ok := params["transition_duration"]; !ok || !errors.As(err, &rpcErr) || rpcErr.Code != rpc.InvalidArgument {
+		return err
+	}
+
+	delete(params, "transition_duration")
+
+	brightness, ok := params["brightness"].(float64)
+	if !ok {
+		return client.Call(ctx, method, params, nil)
+	}
+
+	// The colour is set first without switching the light on and the
+	// brightness is faded from the current level, or from 0 if it is off
+	delete(params, "on")
+	delete(params, "brightness")
+
+	if len(params) > 1 {
+		err = client.Call(ctx, method, params, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	fader, err := light.NewFader(client, strings.SplitN(key, ":", 2)[0], time.Duration(transition*float64(time.Second)), true, "linear", 0)
+	if err != nil {
+		return err
+	}
+
+	return fader.Set(ctx, params["id"].(int), brightness, true)
+}
+
+// Apply concurrently restores the component states of scene. Devices are looked
+// up in inv.
+func Apply(ctx context.Context, scene *Scene, inv *inventory.Inventory, transition float64) (Results, error) {
+
+	var results Results
+	var wg sync.WaitGroup
+
+	for _, deviceState := range scene.Devices {
+
+		// A single client per device so that the auth handshake is shared
+		var client *rpc.Client
+		if device := inv.Get(deviceState.Device); device != nil {
+			client = device.Client()
+		}
+
+		for _, componentState := range deviceState.Components {
+
+			result := &Result{
+				Device:    deviceState.Device,
+				Component: componentState.Key,
+				State:     componentState.State.String(),
+			}
+
+			results = append(results, result)
+
+			if client == nil {
+				result.Error = "device not found in inventory"
+				continue
+			}
+
+			wg.Add(1)
+
+			go func(client *rpc.Client, componentState *ComponentState, result *Result) {
+
+				defer wg.Done()
+
+				method, params, err := setParams(componentState, transition)
+				if err == nil {
+					err = set(ctx, client, componentState.Key, method, params, transition)
+				}
+
+				if err != nil {
+					result.Error = err.Error()
+				}
+
+			}(client, componentState, result)
+		}
+	}
+
+	wg.Wait()
+
+	var errors *multierror.Error
+
+	for _, result := range results {
+		if result.Error != "" {
+			errors = multierror.Append(errors, fmt.Errorf("device %s %s: %s", result.Device, result.Component, result.Error))
+		}
+	}
+
+	return results, errors.ErrorOrNil()
+}
@@ -0,0 +1,225 @@
+package scene
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/jodydadescott/shelly-go-cli/cmd/plus/channel"
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// newDevice returns an inventory device that answers Shelly.GetStatus with
+// status
+func newDevice(t *testing.T, name string, status string) *inventory.Device {
+	return testdevice.New(t, &testdevice.Config{RPC: testdevice.Results(map[string]string{
+		"Shelly.GetStatus": status,
+	})}).Inventory(name)
+}
+
+func TestMatches(t *testing.T) {
+
+	tests := []struct {
+		key     string
+		filters []string
+		want    bool
+	}{
+		{key: "switch:0", want: true},
+		{key: "cct:1", want: true},
+		{key: "input:0", want: false},
+		{key: "sys", want: false},
+		{key: "light:1", filters: []string{"light"}, want: true},
+		{key: "light:1", filters: []string{"LIGHT:1"}, want: true},
+		{key: "light:1", filters: []string{"light:0"}, want: false},
+		{key: "switch:0", filters: []string{"light"}, want: false},
+		{key: "input:0", filters: []string{"input"}, want: false},
+	}
+
+	for _, test := range tests {
+		if got := matches(test.key, test.filters); got != test.want {
+			t.Errorf("matches(%q, %v) = %v; want %v", test.key, test.filters, got, test.want)
+		}
+	}
+}
+
+func TestSetParams(t *testing.T) {
+
+	on := true
+	off := false
+	brightness := 40.0
+
+	tests := []struct {
+		state  *ComponentState
+		method string
+		params map[string]any
+	}{
+		{
+			state:  &ComponentState{Key: "switch:1", State: channel.State{Output: &on}},
+			method: "Switch.Set",
+			params: map[string]any{"id": 1, "on": true},
+		},
+		{
+			state:  &ComponentState{Key: "light:0", State: channel.State{Output: &on, Brightness: &brightness}},
+			method: "Light.Set",
+			params: map[string]any{"id": 0, "on": true, "brightness": 40.0, "transition_duration": 2.0},
+		},
+		{
+			state:  &ComponentState{Key: "rgb:0", State: channel.State{Output: &off, Brightness: &brightness, RGB: []int{1, 2, 3}}},
+			method: "RGB.Set",
+			params: map[string]any{"id": 0, "on": false},
+		},
+	}
+
+	for _, test := range tests {
+
+		method, params, err := setParams(test.state, 2)
+		if err != nil {
+			t.Errorf("%s: error = %v", test.state.Key, err)
+			continue
+		}
+
+		if method != test.method || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: got %s %v; want %s %v", test.state.Key, method, params, test.method, test.params)
+		}
+	}
+
+	for _, key := range []string{"switch", "switch:x"} {
+		if _, _, err := setParams(&ComponentState{Key: key, State: channel.State{Output: &on}}, 0); err == nil {
+			t.Errorf("setParams(%q) expected an error", key)
+		}
+	}
+
+	if _, _, err := setParams(&ComponentState{Key: "switch:0"}, 0); err == nil {
+		t.Errorf("setParams without output expected an error")
+	}
+}
+
+func TestCapture(t *testing.T) {
+
+	devices := []*inventory.Device{
+		newDevice(t, "plug", `{"switch:0":{"id":0,"output":true},"sys":{}}`),
+		newDevice(t, "sensor", `{"temperature:0":{"id":0,"tC":21.5}}`),
+		{Name: "gen1", Hostname: "127.0.0.1:1", Gen: 1},
+		{Name: "dead", Hostname: "127.0.0.1:1"},
+	}
+
+	scene, err := Capture(context.Background(), "evening", devices, nil)
+
+	var errs *multierror.Error
+	if !errors.As(err, &errs) || len(errs.Errors) != 1 || !strings.Contains(errs.Errors[0].Error(), "device dead") {
+		t.Fatalf("error = %v; want a single error for device dead", err)
+	}
+
+	if len(scene.Devices) != 1 || scene.Devices[0].Device != "plug" {
+		t.Fatalf("scene devices = %v; want only plug", scene.Devices)
+	}
+
+	components := scene.Devices[0].Components
+	if len(components) != 1 || components[0].Key != "switch:0" || !*components[0].Output {
+		t.Errorf("plug components = %v; want switch:0 on", components)
+	}
+}
+
+func TestApplyTransitionFallback(t *testing.T) {
+
+	output := false
+	brightness := 0.0
+
+	// The firmware does not support transition_duration
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+		switch method {
+		case "RGB.GetStatus":
+			return map[string]any{"id": 0, "output": output, "brightness": brightness}, nil
+		case "RGB.Set":
+			if _, ok := params["transition_duration"]; ok {
+				return nil, &rpc.Error{Code: rpc.InvalidArgument, Message: "invalid argument"}
+			}
+			if on, ok := params["on"].(bool); ok {
+				output = on
+			}
+			if b, ok := params["brightness"].(float64); ok {
+				brightness = b
+			}
+			return nil, nil
+		}
+		return nil, testdevice.NotFound(method)
+	}})
+
+	on := true
+	level := 80.0
+
+	scene := &Scene{Name: "evening", Devices: []*DeviceState{{Device: "strip", Components: []*ComponentState{
+		{Key: "rgb:0", State: channel.State{Output: &on, Brightness: &level, RGB: []int{255, 0, 0}}},
+	}}}}
+
+	inv := &inventory.Inventory{Devices: []*inventory.Device{device.Inventory("strip")}}
+
+	_, err := Apply(context.Background(), scene, inv, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := device.Calls()
+
+	if len(calls) != 4 || calls[1].Params["rgb"] == nil || calls[1].Params["on"] != nil || calls[2].Method != "RGB.GetStatus" {
+		t.Fatalf("calls = %v; want the rejected set, the colour, the status and the fade", device.Methods())
+	}
+
+	if !output || brightness != 80 {
+		t.Errorf("output %v brightness %v; want on at 80", output, brightness)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	t.Setenv(inventory.ConfigDirEnvVar, t.TempDir())
+
+	on := true
+
+	scene := &Scene{
+		Name: "evening",
+		Devices: []*DeviceState{
+			{Device: "plug", Components: []*ComponentState{{Key: "switch:0", State: channel.State{Output: &on}}}},
+		},
+	}
+
+	err := save(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := load("evening")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, scene) {
+		t.Errorf("loaded %+v; want %+v", loaded, scene)
+	}
+
+	scenes, err := list()
+	if err != nil || len(scenes) != 1 {
+		t.Errorf("list() = %v, %v; want one scene", scenes, err)
+	}
+
+	err = remove("evening")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := load("evening"); err == nil {
+		t.Errorf("expected scene evening to be removed")
+	}
+
+	for _, name := range []string{"", "../x", "a/b", ".hidden"} {
+		if _, err := sceneFile(name); err == nil {
+			t.Errorf("sceneFile(%q) expected an error", name)
+		}
+	}
+}
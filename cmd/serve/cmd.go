@@ -0,0 +1,150 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/server"
+)
+
+type callback interface {
+	WriteStderr(string)
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var listenArg string
+	var tokenFileArg string
+	var noAuthArg bool
+	var timeoutArg time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serves a REST API in front of the inventory devices",
+		Long: "Serves GET /devices, GET /devices/{name}/info, GET /devices/{name}/status, " +
+			"POST /devices/{name}/{component}/{id}/{on|off|toggle} and POST /rpc for the inventory devices so that " +
+			"other tools can control them without the device credentials. Clients send one of the tokens of the " +
+			"token file (one per line) as bearer token. Each request is logged to STDERR. The OpenAPI document " +
+			"is served on /openapi.json. Runs until interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if tokenFileArg == "" && !noAuthArg {
+				return fmt.Errorf("token-file is required unless no-auth is set")
+			}
+
+			if tokenFileArg != "" && noAuthArg {
+				return fmt.Errorf("token-file and no-auth are mutually exclusive")
+			}
+
+			var tokens []string
+
+			if tokenFileArg != "" {
+				var err error
+				tokens, err = readTokens(tokenFileArg)
+				if err != nil {
+					return err
+				}
+			}
+
+			inv, err := callback.Inventory()
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			s := &http.Server{
+				Addr: listenArg,
+				Handler: server.New(&server.Config{
+					Inventory: inv,
+					Tokens:    tokens,
+					Timeout:   timeoutArg,
+					Logf: func(format string, args ...any) {
+						callback.WriteStderr(fmt.Sprintf(format, args...))
+					},
+				}),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			errs := make(chan error, 1)
+
+			go func() {
+				errs <- s.ListenAndServe()
+			}()
+
+			callback.WriteStderr(fmt.Sprintf("serving %d devices on %s", len(inv.Devices), listenArg))
+
+			select {
+
+			case err := <-errs:
+				return err
+
+			case <-ctx.Done():
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err = s.Shutdown(shutdownCtx)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listenArg, "listen", "127.0.0.1:8080", "listen address")
+	cmd.Flags().StringVar(&tokenFileArg, "token-file", "", "file with the accepted bearer tokens, one per line")
+	cmd.Flags().BoolVar(&noAuthArg, "no-auth", false, "disable authentication")
+	cmd.Flags().DurationVar(&timeoutArg, "timeout", 10*time.Second, "timeout of the device requests per API request")
+
+	return cmd
+}
+
+// readTokens returns the tokens of the file. Empty lines and lines starting
+// with # are ignored.
+func readTokens(filename string) ([]string, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var tokens []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token file %s has no tokens", filename)
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,744 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	shelly "github.com/jodydadescott/shelly-go-sdk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/gen1"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/repl"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	historyFileName   = "history"
+	completionTimeout = 3 * time.Second
+)
+
+// secretWords mark lines that are not written to the history because they may
+// contain credentials, such as call Shelly.SetAuth or wifi set-config --pass
+var secretWords = []string{"pass", "setauth", "ha1", "token", "secret"}
+
+// secretShortFlags are the short flags that set credentials, -p for the
+// password and -u for the username. Long flags are matched by secretWords and
+// user.
+const secretShortFlags = "pu"
+
+// outputComponents are the component types completed for --id if the command
+// does not name a component
+var outputComponents = []string{"switch", "light", "rgb", "rgbw", "cct", "cover"}
+
+var shellBuiltins = []struct {
+	name, usage, short string
+}{
+	{"use", "use <device>", "switch to an inventory device or hostname"},
+	{"call", "call <Method> [params]", "call an RPC method with JSON params such as '{\"id\":0}'"},
+	{"methods", "methods", "list the RPC methods of the device"},
+	{"help", "help", "show this help"},
+	{"exit", "exit", "leave the shell (also quit or Ctrl-D)"},
+}
+
+// session is the target device of the shell. Its clients are kept for the
+// session so that connections and authentication are reused between
+// commands: the rpc client for Plus devices, the gen1 client for Gen1
+// devices and the SDK client for the shelly and wifi commands.
+type session struct {
+	name     string
+	hostname string
+	username string
+	password string
+	gen      int
+	debug    bool
+
+	mutex       sync.Mutex
+	_client     *shelly.Client
+	_rpcClient  *rpc.Client
+	_gen1Client *gen1.Client
+	_device     device.Device
+	methods     []string
+	components  []string
+}
+
+func (t *session) client() *shelly.Client {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t._client == nil {
+		t._client = shelly.New(&shelly.Config{
+			DebugEnabled: t.debug,
+			Hostname:     t.hostname,
+			Password:     t.password,
+		})
+	}
+	return t._client
+}
+
+func (t *session) rpc() *rpc.Client {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t._rpcClient == nil {
+		t._rpcClient = rpc.New(&rpc.Config{
+			Hostname: t.hostname,
+			Password: t.password,
+		})
+	}
+	return t._rpcClient
+}
+
+func (t *session) gen1() *gen1.Client {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t._gen1Client == nil {
+		t._gen1Client = gen1.New(&gen1.Config{
+			Hostname: t.hostname,
+			Username: t.username,
+			Password: t.password,
+		})
+	}
+	return t._gen1Client
+}
+
+// device returns the generation agnostic device. The generation is detected
+// once.
+func (t *session) device(ctx context.Context) (device.Device, error) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t._device == nil {
+		d, err := device.New(ctx, &device.Config{
+			Hostname: t.hostname,
+			Username: t.username,
+			Password: t.password,
+			Gen:      t.gen,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t._device = d
+		t.gen = d.Gen()
+	}
+
+	return t._device, nil
+}
+
+// listMethods returns the RPC methods of the device. The methods are fetched
+// once.
+func (t *session) listMethods(ctx context.Context) ([]string, error) {
+
+	t.mutex.Lock()
+	methods := t.methods
+	t.mutex.Unlock()
+
+	if methods != nil {
+		return methods, nil
+	}
+
+	result := &struct {
+		Methods []string `json:"methods"`
+	}{}
+
+	err := t.rpc().Call(ctx, "Shelly.ListMethods", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(result.Methods)
+
+	t.mutex.Lock()
+	t.methods = result.Methods
+	t.mutex.Unlock()
+
+	return result.Methods, nil
+}
+
+// listComponents returns the component keys such as switch:0 of a Plus
+// device. The components are fetched once.
+func (t *session) listComponents(ctx context.Context) ([]string, error) {
+
+	t.mutex.Lock()
+	components := t.components
+	t.mutex.Unlock()
+
+	if components != nil {
+		return components, nil
+	}
+
+	d, err := t.device(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Gen() == 1 {
+		return nil, fmt.Errorf("Gen1 devices have no components")
+	}
+
+	var status map[string]json.RawMessage
+
+	err = t.rpc().Call(ctx, "Shelly.GetStatus", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	components = []string{}
+	for key := range status {
+		if strings.Contains(key, ":") {
+			components = append(components, key)
+		}
+	}
+
+	sort.Strings(components)
+
+	t.mutex.Lock()
+	t.components = components
+	t.mutex.Unlock()
+
+	return components, nil
+}
+
+func (t *session) close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t._client != nil {
+		t._client.Close()
+	}
+}
+
+// shell is the state of the interactive shell
+type shell struct {
+	root    *Cmd
+	session *session
+}
+
+func newShellCmd(t *Cmd) *cobra.Command {
+
+	return &cobra.Command{
+		Use:   "shell",
+		Short: "Interactive shell with a persistent device session",
+		Long: "Reads commands interactively and runs them against the session device, which is set with the " +
+			"hostname or device flag or with 'use <device>' inside the shell. The connection and authentication " +
+			"of the session device are reused between commands. Lines are CLI commands without the binary name " +
+			"such as 'status' or 'plus switch get-status --id 0', or one of the builtins use, call, methods, " +
+			"help and exit. Commands, RPC methods, component IDs and device names are completed with tab. The " +
+			"history is kept in the config dir; lines that may contain credentials such as passwords, usernames, tokens " +
+			"and Shelly.SetAuth calls are left out.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			sh := &shell{
+				root:    t,
+				session: &session{debug: t.debugEnabledArg},
+			}
+
+			if hostname := t.Hostname(); hostname != "" {
+				name := t.deviceArg
+				if name == "" {
+					name = hostname
+				}
+				sh.session = &session{
+					name:     name,
+					hostname: hostname,
+					username: t.Username(),
+					password: t.Password(),
+					gen:      t.gen,
+					debug:    t.debugEnabledArg,
+				}
+			}
+
+			defer func() {
+				sh.session.close()
+			}()
+
+			historyFile := ""
+			if dir, err := inventory.ConfigDir(); err == nil {
+				historyFile = filepath.Join(dir, historyFileName)
+			}
+
+			reader := repl.New(&repl.Config{
+				HistoryFile: historyFile,
+				Complete:    sh.complete,
+			})
+
+			// Interrupts cancel the running command instead of ending the shell
+			interrupts := make(chan os.Signal, 1)
+			signal.Notify(interrupts, os.Interrupt)
+			defer signal.Stop(interrupts)
+
+			if reader.Terminal() {
+				t.WriteStderr("Type 'help' for help and 'exit' or Ctrl-D to leave")
+			}
+
+			for {
+
+				line, err := reader.ReadLine(sh.prompt())
+				if err == io.EOF {
+					return nil
+				}
+				if err == repl.ErrInterrupted {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				if reader.Terminal() && !hasSecret(line) {
+					err = reader.AddHistory(line)
+					if err != nil {
+						t.WriteStderr(fmt.Sprintf("unable to write history: %s", err))
+					}
+				}
+
+				// Drain an interrupt received while reading
+				select {
+				case <-interrupts:
+				default:
+				}
+
+				ctx, cancel := context.WithCancel(cmd.Context())
+
+				go func() {
+					select {
+					case <-interrupts:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+
+				exit, err := sh.execute(ctx, line)
+				cancel()
+
+				if err != nil {
+					t.WriteStderr(fmt.Sprintf("Error: %s", err))
+				}
+
+				if exit {
+					return nil
+				}
+			}
+		},
+	}
+}
+
+func (t *shell) prompt() string {
+	if t.session.name == "" {
+		return BinaryName + "> "
+	}
+	return t.session.name + "> "
+}
+
+// execute runs line and returns true if the shell should exit
+func (t *shell) execute(ctx context.Context, line string) (bool, error) {
+
+	words, err := repl.Split(line)
+	if err != nil {
+		return false, err
+	}
+
+	if len(words) == 0 {
+		return false, nil
+	}
+
+	switch words[0] {
+
+	case "exit", "quit":
+		return true, nil
+
+	case "help":
+		if len(words) > 1 {
+			break
+		}
+		return false, t.help()
+
+	case "use":
+		if len(words) != 2 {
+			return false, fmt.Errorf("usage: use <device>")
+		}
+		return false, t.use(words[1])
+
+	case "methods":
+		if err := t.requireRPC(ctx); err != nil {
+			return false, err
+		}
+		methods, err := t.session.listMethods(ctx)
+		if err != nil {
+			return false, err
+		}
+		return false, t.root.WriteStdout(strings.Join(methods, "\n"))
+
+	case "call":
+		return false, t.call(ctx, words[1:])
+
+	case "shell":
+		return false, fmt.Errorf("already in the shell")
+	}
+
+	// Each line runs in a new command tree so that flags do not carry over; the
+	// output format and debug flag of the shell are the defaults
+	c := NewCmd()
+	c.session = t.session
+	c.PersistentFlags().Set("output", t.root.outputArg)
+	if t.root.debugEnabledArg {
+		c.PersistentFlags().Set("debug", "true")
+	}
+	c.SetArgs(words)
+
+	// Errors are written by cobra
+	c.ExecuteContext(ctx)
+
+	return false, nil
+}
+
+func (t *shell) help() error {
+
+	var b strings.Builder
+
+	b.WriteString("Builtins:\n")
+	for _, builtin := range shellBuiltins {
+		fmt.Fprintf(&b, "  %-24s %s\n", builtin.usage, builtin.short)
+	}
+
+	b.WriteString("\nAny other line runs a command such as 'status' or 'plus switch get-status --id 0'.\n")
+	b.WriteString("Use '--help' to list the commands and '<command> --help' for the help of a command.")
+
+	return t.root.WriteStdout(b.String())
+}
+
+// use switches the session to the inventory device or hostname name
+func (t *shell) use(name string) error {
+
+	inv, err := inventory.Load()
+	if err != nil {
+		return err
+	}
+
+	next := &session{name: name, hostname: name, debug: t.session.debug}
+
+	if d := inv.Get(name); d != nil {
+		next = &session{
+			name:     d.Name,
+			hostname: d.Hostname,
+			username: d.Username,
+			password: d.Password,
+			gen:      d.Gen,
+			debug:    t.session.debug,
+		}
+	}
+
+	t.session.close()
+	t.session = next
+
+	if next.name == next.hostname {
+		t.root.WriteStderr(fmt.Sprintf("using hostname %s without credentials", next.hostname))
+	} else {
+		t.root.WriteStderr(fmt.Sprintf("using device %s (%s)", next.name, next.hostname))
+	}
+
+	return nil
+}
+
+// call calls the RPC method with the optional JSON params and writes the
+// result
+func (t *shell) call(ctx context.Context, args []string) error {
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: call <Method> [params]")
+	}
+
+	if err := t.requireRPC(ctx); err != nil {
+		return err
+	}
+
+	var params any
+
+	if len(args) == 2 {
+		err := json.Unmarshal([]byte(args[1]), &params)
+		if err != nil {
+			return fmt.Errorf("params are invalid JSON: %w", err)
+		}
+	}
+
+	var result any
+
+	err := t.session.rpc().Call(ctx, args[0], params, &result)
+	if err != nil {
+		return err
+	}
+
+	return t.root.WriteStdout(result)
+}
+
+// requireRPC returns an error if there is no session device or it is a Gen1
+// device. The generation is detected if it is not known, as for 'use
+// <hostname>'.
+func (t *shell) requireRPC(ctx context.Context) error {
+
+	if t.session.hostname == "" {
+		return fmt.Errorf("no device; use 'use <device>' first")
+	}
+
+	d, err := t.session.device(ctx)
+	if err != nil {
+		return err
+	}
+
+	if d.Gen() == 1 {
+		return fmt.Errorf("device %s is a Gen1 device and does not support RPC; use the gen1 commands", t.session.name)
+	}
+
+	return nil
+}
+
+// hasSecret returns true if line contains one of the secretWords or sets a
+// credential flag. A line that can not be split is treated as a secret.
+func hasSecret(line string) bool {
+
+	line = strings.ToLower(line)
+
+	for _, word := range secretWords {
+		if strings.Contains(line, word) {
+			return true
+		}
+	}
+
+	words, err := repl.Split(line)
+	if err != nil {
+		return true
+	}
+
+	for _, word := range words {
+
+		name, ok := strings.CutPrefix(word, "--")
+		if ok {
+			name, _, _ = strings.Cut(name, "=")
+			if strings.Contains(name, "user") {
+				return true
+			}
+			continue
+		}
+
+		// Short flags may be combined such as -dp
+		name, ok = strings.CutPrefix(word, "-")
+		if ok && strings.ContainsAny(name, secretShortFlags) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// complete returns the candidates for the word ending at pos. The first word
+// completes to builtins and commands, the words after use, call and --id to
+// device names, RPC methods, params and component IDs, and other words to
+// subcommands and flags.
+func (t *shell) complete(line string, pos int) (int, []string) {
+
+	line = line[:pos]
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	prev := strings.Fields(line[:start])
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	var candidates []string
+
+	switch {
+
+	case len(prev) == 0:
+		for _, builtin := range shellBuiltins {
+			candidates = append(candidates, builtin.name)
+		}
+		for _, name := range commandNames(t.root.Command) {
+			if name != "shell" {
+				candidates = append(candidates, name)
+			}
+		}
+
+	case prev[0] == "use" && len(prev) == 1:
+		if inv, err := inventory.Load(); err == nil {
+			for _, d := range inv.Devices {
+				candidates = append(candidates, d.Name)
+			}
+		}
+
+	case prev[0] == "call" && len(prev) == 1:
+		if t.requireRPC(ctx) == nil {
+			candidates, _ = t.session.listMethods(ctx)
+		}
+
+	case prev[0] == "call" && len(prev) == 2:
+		component, _, _ := strings.Cut(strings.ToLower(prev[1]), ".")
+		for _, id := range t.componentIDs(ctx, []string{component}) {
+			candidates = append(candidates, fmt.Sprintf(`'{"id":%s}'`, id))
+		}
+
+	case isBuiltin(prev[0]):
+
+	default:
+		cmd, expectValue := findCommand(t.root.Command, prev)
+		switch {
+		case expectValue == "id":
+			candidates = t.componentIDs(ctx, commandPath(cmd))
+		case expectValue != "":
+		case strings.HasPrefix(word, "-"):
+			candidates = flagNames(cmd)
+		default:
+			candidates = commandNames(cmd)
+		}
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	return start, matches
+}
+
+// componentIDs returns the IDs of the session components of the first of the
+// types that has components, or of the output components if none has
+func (t *shell) componentIDs(ctx context.Context, types []string) []string {
+
+	if t.session.hostname == "" {
+		return nil
+	}
+
+	components, err := t.session.listComponents(ctx)
+	if err != nil {
+		return nil
+	}
+
+	ids := func(types []string) []string {
+		var ids []string
+		for _, component := range components {
+			kind, id, _ := strings.Cut(component, ":")
+			for _, k := range types {
+				if kind == k {
+					ids = append(ids, id)
+				}
+			}
+		}
+		return ids
+	}
+
+	for _, kind := range types {
+		if result := ids([]string{kind}); len(result) > 0 {
+			return result
+		}
+	}
+
+	return ids(outputComponents)
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range shellBuiltins {
+		if builtin.name == name {
+			return true
+		}
+	}
+	return name == "quit"
+}
+
+// findCommand returns the command of words and the name of the flag whose
+// value the next word is, if any
+func findCommand(root *cobra.Command, words []string) (*cobra.Command, string) {
+
+	cmd := root
+	expectValue := ""
+
+	for _, word := range words {
+
+		if expectValue != "" {
+			expectValue = ""
+			continue
+		}
+
+		if strings.HasPrefix(word, "-") {
+			if strings.Contains(word, "=") {
+				continue
+			}
+			flag := lookupFlag(cmd, word)
+			if flag != nil && flag.NoOptDefVal == "" {
+				expectValue = flag.Name
+			}
+			continue
+		}
+
+		for _, sub := range cmd.Commands() {
+			if sub.Name() == word || sub.HasAlias(word) {
+				cmd = sub
+				break
+			}
+		}
+	}
+
+	return cmd, expectValue
+}
+
+func lookupFlag(cmd *cobra.Command, word string) *pflag.Flag {
+
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+	flags.AddFlagSet(cmd.InheritedFlags())
+
+	if strings.HasPrefix(word, "--") {
+		return flags.Lookup(strings.TrimPrefix(word, "--"))
+	}
+
+	if len(word) == 2 {
+		return flags.ShorthandLookup(word[1:])
+	}
+
+	return nil
+}
+
+// commandPath returns the names of cmd and its parents starting with cmd
+func commandPath(cmd *cobra.Command) []string {
+	var names []string
+	for c := cmd; c != nil; c = c.Parent() {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+func commandNames(cmd *cobra.Command) []string {
+	var names []string
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			names = append(names, sub.Name())
+		}
+	}
+	return names
+}
+
+func flagNames(cmd *cobra.Command) []string {
+
+	var names []string
+
+	add := func(flag *pflag.Flag) {
+		if !flag.Hidden {
+			names = append(names, "--"+flag.Name)
+		}
+	}
+
+	cmd.Flags().VisitAll(add)
+	cmd.InheritedFlags().VisitAll(add)
+
+	sort.Strings(names)
+	return names
+}
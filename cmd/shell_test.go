@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+)
+
+func TestHasSecret(t *testing.T) {
+
+	tests := map[string]bool{
+		`call Shelly.SetAuth '{"user":"admin","ha1":"x"}'`: true,
+		`wifi set-config --pass hunter2`:                   true,
+		`call Cloud.SetConfig '{"config":{"Token":"x"}}'`:  true,
+		`status -H 10.0.0.5 -p hunter2`:                    true,
+		`status -H 10.0.0.5 -dp hunter2`:                   true,
+		`status -u admin`:                                  true,
+		`status --username=admin`:                          true,
+		`mqtt set-config --broker-username admin`:          true,
+		`call Switch.Set '{"id":0,"on":true`:               true,
+		`call Switch.Set '{"id":0,"on":true}'`:             false,
+		`plus switch set --id 0 -o yaml`:                   false,
+		`use plug`:                                         false,
+	}
+
+	for line, want := range tests {
+		if got := hasSecret(line); got != want {
+			t.Errorf("hasSecret(%q) = %v; want %v", line, got, want)
+		}
+	}
+}
+
+func TestRequireRPC(t *testing.T) {
+
+	newShell := func(shelly string) *shell {
+		hostname := testdevice.New(t, &testdevice.Config{Shelly: shelly}).Hostname
+		return &shell{session: &session{name: hostname, hostname: hostname}}
+	}
+
+	if err := (&shell{session: &session{}}).requireRPC(context.Background()); err == nil || !strings.Contains(err.Error(), "no device") {
+		t.Errorf("no device error = %v", err)
+	}
+
+	// The generation is not known after use <hostname>
+	if err := newShell(testdevice.Gen1).requireRPC(context.Background()); err == nil || !strings.Contains(err.Error(), "is a Gen1 device") {
+		t.Errorf("Gen1 error = %v", err)
+	}
+
+	if err := newShell(testdevice.Plus).requireRPC(context.Background()); err != nil {
+		t.Errorf("Gen2 error = %v", err)
+	}
+}
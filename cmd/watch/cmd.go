@@ -0,0 +1,99 @@
+package watch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/watch"
+)
+
+type callback interface {
+	Hostname() string
+	Password() string
+	Inventory() (*inventory.Inventory, error)
+}
+
+func NewCmd(callback callback) *cobra.Command {
+
+	var devicesArg []string
+	var allArg bool
+	var methodsArg []string
+	var reorderArg time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Streams the notifications of one or more devices as NDJSON",
+		Long: "Opens a websocket to the hostname device or to each selected inventory device and writes their " +
+			"notifications (NotifyStatus, NotifyFullStatus and NotifyEvent), tagged with the device name, as one " +
+			"NDJSON stream ordered by the device timestamps. Events are held for --reorder after they are received; " +
+			"the order is only as good as the device clocks are in sync and events that arrive later than that " +
+			"follow the events already written. Devices that drop off are reconnected; connected and disconnected events " +
+			"are written for each connection. Runs until interrupted.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if reorderArg < 0 {
+				return fmt.Errorf("reorder must not be negative")
+			}
+
+			var devices []*inventory.Device
+
+			if len(devicesArg) > 0 || allArg {
+
+				inv, err := callback.Inventory()
+				if err != nil {
+					return err
+				}
+
+				devices, err = inv.Select(devicesArg)
+				if err != nil {
+					return err
+				}
+
+			} else {
+
+				if callback.Hostname() == "" {
+					return fmt.Errorf("hostname, devices or all is required")
+				}
+
+				devices = []*inventory.Device{{
+					Name:     callback.Hostname(),
+					Hostname: callback.Hostname(),
+					Password: callback.Password(),
+				}}
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			writer := bufio.NewWriter(os.Stdout)
+			encoder := json.NewEncoder(writer)
+
+			return watch.Run(ctx, &watch.Config{
+				Devices: devices,
+				Methods: methodsArg,
+				Reorder: reorderArg,
+			}, func(event *watch.Event) error {
+				err := encoder.Encode(event)
+				if err != nil {
+					return err
+				}
+				return writer.Flush()
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&devicesArg, "devices", nil, "inventory device names")
+	cmd.Flags().BoolVar(&allArg, "all", false, "watch all inventory devices")
+	cmd.Flags().StringSliceVar(&methodsArg, "method", nil, "notification methods to write such as NotifyEvent; defaults to all")
+	cmd.Flags().DurationVar(&reorderArg, "reorder", 500*time.Millisecond, "how long events are held after they are received to merge the devices in device timestamp order")
+
+	return cmd
+}
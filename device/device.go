@@ -0,0 +1,217 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/gen1"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const detectTimeout = 10 * time.Second
+
+// Action is an output action
+type Action string
+
+const (
+	On     Action = "on"
+	Off    Action = "off"
+	Toggle Action = "toggle"
+)
+
+// Device is a Gen1 or Plus device. Results are normalised so that the same
+// script works for either generation.
+type Device interface {
+	// Gen returns the device generation
+	Gen() int
+	Info(ctx context.Context) (*Info, error)
+	Status(ctx context.Context) (*Status, error)
+	// Set executes action on output id of component. If component is empty the
+	// first switchable component of the device is used.
+	Set(ctx context.Context, component string, id int, action Action) (*Output, error)
+	// SetBrightness turns light id on at brightness percent
+	SetBrightness(ctx context.Context, id int, brightness int) (*Output, error)
+	Reboot(ctx context.Context) error
+}
+
+type Config struct {
+	Hostname string
+	// Username is used by Gen1 devices only
+	Username string
+	Password string
+	// Gen is the device generation. If zero it is detected.
+	Gen int
+}
+
+// Info is the normalised device info
+type Info struct {
+	Hostname string `json:"hostname"`
+	Gen      int    `json:"gen"`
+	Name     string `json:"name,omitempty"`
+	Model    string `json:"model"`
+	MAC      string `json:"mac"`
+	Firmware string `json:"firmware"`
+	Auth     bool   `json:"auth"`
+}
+
+// Output is the normalised status of a switch, relay, light, cover or roller.
+// Gen1 relays are reported as switch and rollers as cover. Power is in W,
+// energy in Wh and temperature in °C.
+type Output struct {
+	Component   string   `json:"component"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name,omitempty"`
+	On          *bool    `json:"on,omitempty"`
+	Brightness  *float64 `json:"brightness,omitempty"`
+	State       string   `json:"state,omitempty"`
+	Position    *float64 `json:"position,omitempty"`
+	Power       *float64 `json:"power,omitempty"`
+	Energy      *float64 `json:"energy,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+func (t *Output) Header() []string {
+	return Outputs{t}.Header()
+}
+
+func (t *Output) Rows() [][]string {
+	return Outputs{t}.Rows()
+}
+
+// Outputs is a list of Output
+type Outputs []*Output
+
+func (t Outputs) Header() []string {
+	return []string{"COMPONENT", "ID", "NAME", "ON", "BRIGHTNESS", "STATE", "POSITION", "POWER", "ENERGY", "TEMPERATURE"}
+}
+
+func (t Outputs) Rows() [][]string {
+	var rows [][]string
+	for _, output := range t {
+		on := ""
+		if output.On != nil {
+			on = strconv.FormatBool(*output.On)
+		}
+		rows = append(rows, []string{output.Component, strconv.Itoa(output.ID), output.Name, on,
+			formatFloat(output.Brightness), output.State, formatFloat(output.Position), formatFloat(output.Power),
+			formatFloat(output.Energy), formatFloat(output.Temperature)})
+	}
+	return rows
+}
+
+// Status is the normalised device status
+type Status struct {
+	Hostname    string   `json:"hostname"`
+	Gen         int      `json:"gen"`
+	Outputs     Outputs  `json:"outputs"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	RSSI        *float64 `json:"rssi,omitempty"`
+	Uptime      *float64 `json:"uptime,omitempty"`
+}
+
+func (t *Status) Header() []string {
+	return t.Outputs.Header()
+}
+
+func (t *Status) Rows() [][]string {
+	return t.Outputs.Rows()
+}
+
+// New returns the device for config. The generation is detected with the
+// unauthenticated /shelly endpoint unless config.Gen is set.
+func New(ctx context.Context, config *Config) (Device, error) {
+
+	if config.Hostname == "" {
+		return nil, fmt.Errorf("hostname is required")
+	}
+
+	gen := config.Gen
+
+	if gen == 0 {
+		var err error
+		gen, err = Detect(ctx, config.Hostname)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if gen == 1 {
+		return &gen1Device{
+			hostname: config.Hostname,
+			client: gen1.New(&gen1.Config{
+				Hostname: config.Hostname,
+				Username: config.Username,
+				Password: config.Password,
+			}),
+		}, nil
+	}
+
+	return &plusDevice{
+		gen:      gen,
+		hostname: config.Hostname,
+		client: rpc.New(&rpc.Config{
+			Hostname: config.Hostname,
+			Password: config.Password,
+		}),
+	}, nil
+}
+
+// Detect returns the generation of the device at hostname. Gen1 devices do not
+// report a generation.
+func Detect(ctx context.Context, hostname string) (int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, detectTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+hostname+"/shelly", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unable to detect generation of device %s: %w", hostname, err)
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unable to detect generation of device %s; /shelly returned HTTP %d", hostname, resp.StatusCode)
+	}
+
+	result := &struct {
+		Gen int `json:"gen"`
+	}{}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return 0, fmt.Errorf("unable to detect generation of device %s; %s is not a Shelly device", hostname, hostname)
+	}
+
+	if result.Gen == 0 {
+		return 1, nil
+	}
+
+	return result.Gen, nil
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
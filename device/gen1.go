@@ -0,0 +1,254 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/jodydadescott/shelly-go-cli/gen1"
+)
+
+type gen1Device struct {
+	hostname string
+	client   *gen1.Client
+}
+
+type gen1Status struct {
+	Relays []struct {
+		IsOn bool `json:"ison"`
+	} `json:"relays"`
+	Lights []struct {
+		IsOn       bool     `json:"ison"`
+		Brightness *float64 `json:"brightness"`
+	} `json:"lights"`
+	Rollers []struct {
+		State      string   `json:"state"`
+		CurrentPos *float64 `json:"current_pos"`
+		Power      *float64 `json:"power"`
+	} `json:"rollers"`
+	Meters []struct {
+		Power *float64 `json:"power"`
+		// Total is in watt-minutes
+		Total *float64 `json:"total"`
+	} `json:"meters"`
+	Temperature *float64 `json:"temperature"`
+	Tmp         *struct {
+		TC *float64 `json:"tC"`
+	} `json:"tmp"`
+	WifiSta *struct {
+		RSSI *float64 `json:"rssi"`
+	} `json:"wifi_sta"`
+	Uptime *float64 `json:"uptime"`
+}
+
+type gen1Settings struct {
+	Name   string `json:"name"`
+	Mode   string `json:"mode"`
+	Relays []struct {
+		Name string `json:"name"`
+	} `json:"relays"`
+	Lights []struct {
+		Name string `json:"name"`
+	} `json:"lights"`
+}
+
+func (t *gen1Device) Gen() int {
+	return 1
+}
+
+func (t *gen1Device) Info(ctx context.Context) (*Info, error) {
+
+	result := &struct {
+		Type string `json:"type"`
+		MAC  string `json:"mac"`
+		Auth bool   `json:"auth"`
+		FW   string `json:"fw"`
+	}{}
+
+	err := t.client.Get(ctx, "/shelly", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &gen1Settings{}
+
+	err = t.client.Get(ctx, "/settings", nil, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Hostname: t.hostname,
+		Gen:      1,
+		Name:     settings.Name,
+		Model:    result.Type,
+		MAC:      result.MAC,
+		Firmware: result.FW,
+		Auth:     result.Auth,
+	}, nil
+}
+
+func (t *gen1Device) Status(ctx context.Context) (*Status, error) {
+
+	status := &gen1Status{}
+
+	err := t.client.Get(ctx, "/status", nil, status)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &gen1Settings{}
+
+	err = t.client.Get(ctx, "/settings", nil, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Status{
+		Hostname:    t.hostname,
+		Gen:         1,
+		Outputs:     Outputs{},
+		Temperature: status.Temperature,
+		Uptime:      status.Uptime,
+	}
+
+	if result.Temperature == nil && status.Tmp != nil {
+		result.Temperature = status.Tmp.TC
+	}
+
+	if status.WifiSta != nil {
+		result.RSSI = status.WifiSta.RSSI
+	}
+
+	// meter returns the power and energy (Wh) of meter i
+	meter := func(i int) (*float64, *float64) {
+		if i >= len(status.Meters) {
+			return nil, nil
+		}
+		m := status.Meters[i]
+		if m.Total == nil {
+			return m.Power, nil
+		}
+		energy := *m.Total / 60
+		return m.Power, &energy
+	}
+
+	// Shelly 2.5 in roller mode also reports its relays
+	if settings.Mode != "roller" {
+		for i, relay := range status.Relays {
+			output := &Output{Component: "switch", ID: i, On: boolPtr(relay.IsOn)}
+			if i < len(settings.Relays) {
+				output.Name = settings.Relays[i].Name
+			}
+			if len(status.Meters) == len(status.Relays) {
+				output.Power, output.Energy = meter(i)
+			}
+			result.Outputs = append(result.Outputs, output)
+		}
+	}
+
+	for i, light := range status.Lights {
+		output := &Output{Component: "light", ID: i, On: boolPtr(light.IsOn), Brightness: light.Brightness}
+		if i < len(settings.Lights) {
+			output.Name = settings.Lights[i].Name
+		}
+		if len(status.Relays) == 0 {
+			output.Power, output.Energy = meter(i)
+		}
+		result.Outputs = append(result.Outputs, output)
+	}
+
+	for i, roller := range status.Rollers {
+		result.Outputs = append(result.Outputs, &Output{
+			Component: "cover",
+			ID:        i,
+			State:     roller.State,
+			Position:  roller.CurrentPos,
+			Power:     roller.Power,
+		})
+	}
+
+	return result, nil
+}
+
+func (t *gen1Device) Set(ctx context.Context, component string, id int, action Action) (*Output, error) {
+
+	if component == "" {
+
+		status, err := t.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, output := range status.Outputs {
+			if output.Component != "cover" {
+				component = output.Component
+				break
+			}
+		}
+
+		if component == "" {
+			return nil, fmt.Errorf("device %s has no relay or light", t.hostname)
+		}
+	}
+
+	var path string
+
+	switch component {
+	case "switch", "relay":
+		component = "switch"
+		path = "relay"
+	case "light":
+		path = "light"
+	default:
+		return nil, fmt.Errorf("component %s is invalid for Gen1 devices; expect switch (relay) or light", component)
+	}
+
+	switch action {
+	case On, Off, Toggle:
+	default:
+		return nil, fmt.Errorf("action %s is invalid", action)
+	}
+
+	return t.set(ctx, component, path, id, url.Values{"turn": {string(action)}})
+}
+
+func (t *gen1Device) SetBrightness(ctx context.Context, id int, brightness int) (*Output, error) {
+	return t.set(ctx, "light", "light", id, url.Values{"turn": {"on"}, "brightness": {strconv.Itoa(brightness)}})
+}
+
+// set sends params to output id at path and returns the output
+func (t *gen1Device) set(ctx context.Context, component, path string, id int, params url.Values) (*Output, error) {
+
+	result := &struct {
+		IsOn       bool     `json:"ison"`
+		Brightness *float64 `json:"brightness"`
+	}{}
+
+	err := t.client.Get(ctx, fmt.Sprintf("/%s/%d", path, id), params, result)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := &struct {
+		Name string `json:"name"`
+	}{}
+
+	err = t.client.Get(ctx, fmt.Sprintf("/settings/%s/%d", path, id), nil, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Output{
+		Component:  component,
+		ID:         id,
+		Name:       settings.Name,
+		On:         boolPtr(result.IsOn),
+		Brightness: result.Brightness,
+	}, nil
+}
+
+func (t *gen1Device) Reboot(ctx context.Context) error {
+	return t.client.Get(ctx, "/reboot", nil, nil)
+}
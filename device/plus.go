@@ -0,0 +1,282 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+// plusComponents are the output components in the order they are reported.
+// The first of switch, light, rgb, rgbw and cct found on a device is used if
+// no component is given.
+var plusComponents = []string{"switch", "light", "rgb", "rgbw", "cct", "cover"}
+
+type plusDevice struct {
+	gen      int
+	hostname string
+	client   *rpc.Client
+}
+
+// plusOutputStatus is the union of the output component status fields
+type plusOutputStatus struct {
+	ID         int      `json:"id"`
+	Output     *bool    `json:"output"`
+	Brightness *float64 `json:"brightness"`
+	State      string   `json:"state"`
+	CurrentPos *float64 `json:"current_pos"`
+	APower     *float64 `json:"apower"`
+	AEnergy    *struct {
+		Total *float64 `json:"total"`
+	} `json:"aenergy"`
+	Temperature *struct {
+		TC *float64 `json:"tC"`
+	} `json:"temperature"`
+}
+
+func (t *plusDevice) Gen() int {
+	return t.gen
+}
+
+func (t *plusDevice) Info(ctx context.Context) (*Info, error) {
+
+	result := &struct {
+		Name   string `json:"name"`
+		Model  string `json:"model"`
+		MAC    string `json:"mac"`
+		Ver    string `json:"ver"`
+		FwID   string `json:"fw_id"`
+		AuthEn bool   `json:"auth_en"`
+	}{}
+
+	err := t.client.Call(ctx, "Shelly.GetDeviceInfo", nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	firmware := result.Ver
+	if firmware == "" {
+		firmware = result.FwID
+	}
+
+	return &Info{
+		Hostname: t.hostname,
+		Gen:      t.gen,
+		Name:     result.Name,
+		Model:    result.Model,
+		MAC:      result.MAC,
+		Firmware: firmware,
+		Auth:     result.AuthEn,
+	}, nil
+}
+
+func (t *plusDevice) Status(ctx context.Context) (*Status, error) {
+
+	var status map[string]json.RawMessage
+
+	err := t.client.Call(ctx, "Shelly.GetStatus", nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]json.RawMessage
+
+	err = t.client.Call(ctx, "Shelly.GetConfig", nil, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Status{
+		Hostname: t.hostname,
+		Gen:      t.gen,
+		Outputs:  Outputs{},
+	}
+
+	for key, data := range status {
+
+		component, _, _ := strings.Cut(key, ":")
+
+		switch component {
+
+		case "sys":
+			sys := &struct {
+				Uptime *float64 `json:"uptime"`
+			}{}
+			json.Unmarshal(data, sys)
+			result.Uptime = sys.Uptime
+
+		case "wifi":
+			wifi := &struct {
+				RSSI *float64 `json:"rssi"`
+			}{}
+			json.Unmarshal(data, wifi)
+			result.RSSI = wifi.RSSI
+
+		default:
+			if !isPlusComponent(component) {
+				continue
+			}
+
+			output, err := newPlusOutput(component, data, config[key])
+			if err != nil {
+				return nil, fmt.Errorf("unable to decode status of %s: %w", key, err)
+			}
+
+			result.Outputs = append(result.Outputs, output)
+
+			// Plus devices report the internal temperature per output
+			if output.Temperature != nil && (result.Temperature == nil || *output.Temperature > *result.Temperature) {
+				result.Temperature = output.Temperature
+			}
+		}
+	}
+
+	sort.Slice(result.Outputs, func(i, j int) bool {
+		a, b := result.Outputs[i], result.Outputs[j]
+		if a.Component != b.Component {
+			return componentIndex(a.Component) < componentIndex(b.Component)
+		}
+		return a.ID < b.ID
+	})
+
+	return result, nil
+}
+
+func (t *plusDevice) Set(ctx context.Context, component string, id int, action Action) (*Output, error) {
+
+	if component == "" {
+
+		status, err := t.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, output := range status.Outputs {
+			if output.Component != "cover" {
+				component = output.Component
+				break
+			}
+		}
+
+		if component == "" {
+			return nil, fmt.Errorf("device %s has no switch or light", t.hostname)
+		}
+	}
+
+	if component == "relay" {
+		component = "switch"
+	}
+
+	if !isPlusComponent(component) || component == "cover" {
+		return nil, fmt.Errorf("component %s is invalid; expect switch, light, rgb, rgbw or cct", component)
+	}
+
+	var err error
+
+	switch action {
+	case On, Off:
+		err = t.client.Call(ctx, rpc.Method(component, "Set"), map[string]any{"id": id, "on": action == On}, nil)
+	case Toggle:
+		err = t.client.Call(ctx, rpc.Method(component, "Toggle"), map[string]any{"id": id}, nil)
+	default:
+		err = fmt.Errorf("action %s is invalid", action)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.output(ctx, component, id)
+}
+
+func (t *plusDevice) SetBrightness(ctx context.Context, id int, brightness int) (*Output, error) {
+
+	err := t.client.Call(ctx, "Light.Set", map[string]any{"id": id, "on": true, "brightness": brightness}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.output(ctx, "light", id)
+}
+
+// output returns the status of output id of component
+func (t *plusDevice) output(ctx context.Context, component string, id int) (*Output, error) {
+
+	var status json.RawMessage
+
+	err := t.client.Call(ctx, rpc.Method(component, "GetStatus"), map[string]any{"id": id}, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	var config json.RawMessage
+
+	err = t.client.Call(ctx, rpc.Method(component, "GetConfig"), map[string]any{"id": id}, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPlusOutput(component, status, config)
+}
+
+func (t *plusDevice) Reboot(ctx context.Context) error {
+	return t.client.Call(ctx, "Shelly.Reboot", nil, nil)
+}
+
+func newPlusOutput(component string, status, config json.RawMessage) (*Output, error) {
+
+	s := &plusOutputStatus{}
+
+	err := json.Unmarshal(status, s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &struct {
+		Name string `json:"name"`
+	}{}
+
+	if len(config) > 0 {
+		json.Unmarshal(config, c)
+	}
+
+	output := &Output{
+		Component:  component,
+		ID:         s.ID,
+		Name:       c.Name,
+		On:         s.Output,
+		Brightness: s.Brightness,
+		Power:      s.APower,
+	}
+
+	if component == "cover" {
+		output.State = s.State
+		output.Position = s.CurrentPos
+	}
+
+	if s.AEnergy != nil {
+		output.Energy = s.AEnergy.Total
+	}
+
+	if s.Temperature != nil {
+		output.Temperature = s.Temperature.TC
+	}
+
+	return output, nil
+}
+
+func isPlusComponent(component string) bool {
+	return componentIndex(component) < len(plusComponents)
+}
+
+func componentIndex(component string) int {
+	for i, c := range plusComponents {
+		if c == component {
+			return i
+		}
+	}
+	return len(plusComponents)
+}
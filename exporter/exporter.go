@@ -0,0 +1,260 @@
+package exporter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+const namespace = "shelly"
+
+var (
+	deviceLabels = []string{"device"}
+	outputLabels = []string{"device", "component", "id", "name"}
+
+	upDesc = prometheus.NewDesc(namespace+"_device_up",
+		"1 if the last collection from the device succeeded", deviceLabels, nil)
+	infoDesc = prometheus.NewDesc(namespace+"_device_info",
+		"Device info; always 1", []string{"device", "hostname", "gen"}, nil)
+	temperatureDesc = prometheus.NewDesc(namespace+"_device_temperature_celsius",
+		"Internal device temperature", deviceLabels, nil)
+	rssiDesc = prometheus.NewDesc(namespace+"_wifi_rssi_dbm",
+		"Wi-Fi signal strength", deviceLabels, nil)
+	uptimeDesc = prometheus.NewDesc(namespace+"_uptime_seconds",
+		"Device uptime", deviceLabels, nil)
+
+	outputOnDesc = prometheus.NewDesc(namespace+"_output_on",
+		"1 if the switch or light output is on", outputLabels, nil)
+	outputBrightnessDesc = prometheus.NewDesc(namespace+"_output_brightness_percent",
+		"Light brightness", outputLabels, nil)
+	outputPositionDesc = prometheus.NewDesc(namespace+"_output_position_percent",
+		"Cover position; 0 is closed", outputLabels, nil)
+	outputPowerDesc = prometheus.NewDesc(namespace+"_output_power_watts",
+		"Active power", outputLabels, nil)
+	outputEnergyDesc = prometheus.NewDesc(namespace+"_output_energy_watt_hours_total",
+		"Active energy since the counter was reset", outputLabels, nil)
+	outputTemperatureDesc = prometheus.NewDesc(namespace+"_output_temperature_celsius",
+		"Output (switch) temperature", outputLabels, nil)
+)
+
+type Config struct {
+	Devices  []*inventory.Device
+	Interval time.Duration
+	// Timeout is the timeout of a single device collection. Defaults to the
+	// interval.
+	Timeout time.Duration
+	// Errorf is called with collection errors
+	Errorf func(format string, args ...any)
+}
+
+// Exporter collects the status of the devices every interval and exposes the
+// last result as Prometheus metrics. Devices are collected concurrently; a
+// device that fails is reported as down and its previous metrics are dropped.
+type Exporter struct {
+	config    *Config
+	mutex     sync.RWMutex
+	snapshots map[string]*snapshot
+
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+	lastScrape *prometheus.GaugeVec
+}
+
+type snapshot struct {
+	device *inventory.Device
+	gen    int
+	status *device.Status
+}
+
+func New(config *Config) *Exporter {
+
+	if config.Timeout == 0 {
+		config.Timeout = config.Interval
+	}
+
+	if config.Errorf == nil {
+		config.Errorf = func(string, ...any) {}
+	}
+
+	return &Exporter{
+		config:    config,
+		snapshots: make(map[string]*snapshot),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collect_duration_seconds",
+			Help:      "Duration of the device status collection",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, deviceLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collect_errors_total",
+			Help:      "Failed device status collections",
+		}, deviceLabels),
+		lastScrape: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "last_collect_timestamp_seconds",
+			Help:      "Time of the last successful device status collection",
+		}, deviceLabels),
+	}
+}
+
+// Register registers the device metrics and the exporter self metrics
+func (t *Exporter) Register(registry prometheus.Registerer) error {
+
+	for _, collector := range []prometheus.Collector{t, t.duration, t.errors, t.lastScrape} {
+		err := registry.Register(collector)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run collects every interval until ctx is done
+func (t *Exporter) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+
+		t.CollectAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CollectAll collects the status of every device once
+func (t *Exporter) CollectAll(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for _, d := range t.config.Devices {
+		wg.Add(1)
+		go func(d *inventory.Device) {
+			defer wg.Done()
+			t.collect(ctx, d)
+		}(d)
+	}
+
+	wg.Wait()
+}
+
+func (t *Exporter) collect(ctx context.Context, d *inventory.Device) {
+
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	s := &snapshot{device: d, gen: d.Gen}
+
+	dev, err := device.New(ctx, &device.Config{
+		Hostname: d.Hostname,
+		Username: d.Username,
+		Password: d.Password,
+		Gen:      d.Gen,
+	})
+
+	if err == nil {
+		s.gen = dev.Gen()
+		s.status, err = dev.Status(ctx)
+	}
+
+	t.duration.WithLabelValues(d.Name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+			// Shutting down
+			return
+		}
+		t.errors.WithLabelValues(d.Name).Inc()
+		t.config.Errorf("device %s: %s", d.Name, err)
+	} else {
+		t.lastScrape.WithLabelValues(d.Name).SetToCurrentTime()
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Remember the detected generation so that it is detected once
+	if d.Gen == 0 && s.gen > 0 {
+		d.Gen = s.gen
+	}
+
+	t.snapshots[d.Name] = s
+}
+
+// Describe implements prometheus.Collector
+func (t *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{upDesc, infoDesc, temperatureDesc, rssiDesc, uptimeDesc,
+		outputOnDesc, outputBrightnessDesc, outputPositionDesc, outputPowerDesc, outputEnergyDesc, outputTemperatureDesc} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector. The last collected status is
+// returned; devices are not contacted.
+func (t *Exporter) Collect(ch chan<- prometheus.Metric) {
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	for name, s := range t.snapshots {
+
+		if s.status == nil {
+			ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0, name)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1, name)
+		ch <- prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, 1, name, s.device.Hostname, strconv.Itoa(s.gen))
+
+		gauge(ch, temperatureDesc, s.status.Temperature, name)
+		gauge(ch, rssiDesc, s.status.RSSI, name)
+		gauge(ch, uptimeDesc, s.status.Uptime, name)
+
+		for _, output := range s.status.Outputs {
+
+			labels := []string{name, output.Component, strconv.Itoa(output.ID), output.Name}
+
+			if output.On != nil {
+				on := 0.0
+				if *output.On {
+					on = 1
+				}
+				ch <- prometheus.MustNewConstMetric(outputOnDesc, prometheus.GaugeValue, on, labels...)
+			}
+
+			gauge(ch, outputBrightnessDesc, output.Brightness, labels...)
+			gauge(ch, outputPositionDesc, output.Position, labels...)
+			gauge(ch, outputPowerDesc, output.Power, labels...)
+			gauge(ch, outputTemperatureDesc, output.Temperature, labels...)
+
+			if output.Energy != nil {
+				ch <- prometheus.MustNewConstMetric(outputEnergyDesc, prometheus.CounterValue, *output.Energy, labels...)
+			}
+		}
+	}
+}
+
+// gauge writes the gauge if value is not nil
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, labels ...string) {
+	if value != nil {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, *value, labels...)
+	}
+}
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const metrics = `
+# HELP shelly_device_up 1 if the last collection from the device succeeded
+# TYPE shelly_device_up gauge
+shelly_device_up{device="kitchen"} 1
+# HELP shelly_output_on 1 if the switch or light output is on
+# TYPE shelly_output_on gauge
+shelly_output_on{component="switch",device="kitchen",id="0",name="Lamp"} 1
+# HELP shelly_output_power_watts Active power
+# TYPE shelly_output_power_watts gauge
+shelly_output_power_watts{component="switch",device="kitchen",id="0",name="Lamp"} 12.5
+# HELP shelly_output_energy_watt_hours_total Active energy since the counter was reset
+# TYPE shelly_output_energy_watt_hours_total counter
+shelly_output_energy_watt_hours_total{component="switch",device="kitchen",id="0",name="Lamp"} 1000
+`
+
+const down = `
+# HELP shelly_device_up 1 if the last collection from the device succeeded
+# TYPE shelly_device_up gauge
+shelly_device_up{device="kitchen"} 0
+`
+
+var names = []string{"shelly_device_up", "shelly_output_on", "shelly_output_power_watts", "shelly_output_energy_watt_hours_total"}
+
+func TestCollect(t *testing.T) {
+
+	var fail atomic.Bool
+
+	device := testdevice.New(t, &testdevice.Config{RPC: func(method string, params map[string]any) (any, error) {
+
+		if fail.Load() {
+			return nil, &rpc.Error{Code: -114, Message: "Device busy"}
+		}
+
+		switch method {
+		case "Shelly.GetStatus":
+			return map[string]any{
+				"sys":      map[string]any{"uptime": 100},
+				"wifi":     map[string]any{"rssi": -60},
+				"switch:0": map[string]any{"id": 0, "output": true, "apower": 12.5, "aenergy": map[string]any{"total": 1000}},
+			}, nil
+		case "Shelly.GetConfig":
+			return map[string]any{"switch:0": map[string]any{"name": "Lamp"}}, nil
+		}
+
+		return nil, testdevice.NotFound(method)
+	}})
+
+	d := device.Inventory("kitchen")
+
+	var errors []string
+
+	exporter := New(&Config{
+		Devices:  []*inventory.Device{d},
+		Interval: time.Second,
+		Errorf: func(format string, args ...any) {
+			errors = append(errors, format)
+		},
+	})
+
+	exporter.CollectAll(context.Background())
+
+	err := testutil.CollectAndCompare(exporter, strings.NewReader(metrics), names...)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if d.Gen != 2 {
+		t.Errorf("gen = %d; want the detected generation 2", d.Gen)
+	}
+
+	// A failed collection drops the previous metrics
+	fail.Store(true)
+
+	exporter.CollectAll(context.Background())
+
+	err = testutil.CollectAndCompare(exporter, strings.NewReader(down), names...)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := testutil.ToFloat64(exporter.errors.WithLabelValues("kitchen")); got != 1 || len(errors) != 1 {
+		t.Errorf("errors = %g, reported %d; want 1", got, len(errors))
+	}
+}
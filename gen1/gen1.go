@@ -0,0 +1,233 @@
+package gen1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultUsername is the default username of Gen1 devices
+	DefaultUsername = "admin"
+
+	defaultTimeout = 30 * time.Second
+)
+
+type Config struct {
+	Hostname string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client is a minimal client for the HTTP API of Gen1 devices (Shelly 1, 2.5,
+// Dimmer 2 and so on). Requests are GET requests with query params and basic
+// auth.
+type Client struct {
+	config     *Config
+	httpClient *http.Client
+}
+
+func New(config *Config) *Client {
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Client{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Hostname returns the hostname of the device
+func (t *Client) Hostname() string {
+	return t.config.Hostname
+}
+
+// Get requests path with params. If result is not nil the JSON response will be
+// unmarshalled into it.
+func (t *Client) Get(ctx context.Context, path string, params url.Values, result any) error {
+
+	if t.config.Hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+
+	u := "http://" + t.config.Hostname + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	if t.config.Password != "" {
+		username := t.config.Username
+		if username == "" {
+			username = DefaultUsername
+		}
+		req.SetBasicAuth(username, t.config.Password)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+
+	case http.StatusOK:
+
+	case http.StatusUnauthorized:
+		if t.config.Password == "" {
+			return fmt.Errorf("device %s requires a password", t.config.Hostname)
+		}
+		return fmt.Errorf("authentication to device %s failed", t.config.Hostname)
+
+	case http.StatusNotFound:
+		return fmt.Errorf("device %s does not support %s", t.config.Hostname, path)
+
+	default:
+		// Errors are returned as plain text such as 'Bad brightness!'
+		return fmt.Errorf("device %s returned HTTP %d: %s", t.config.Hostname, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return fmt.Errorf("unexpected response from device %s: %w", t.config.Hostname, err)
+	}
+
+	return nil
+}
+
+// readOnlySettings are the top level /settings keys that are reported but can
+// not be set
+var readOnlySettings = map[string]bool{
+	"device":     true,
+	"hwinfo":     true,
+	"fw":         true,
+	"build_info": true,
+	"time":       true,
+	"unixtime":   true,
+}
+
+// subEndpointSettings are the top level /settings keys that are set with their
+// own endpoint such as /settings/sta
+var subEndpointSettings = map[string]bool{
+	"wifi_ap":    true,
+	"wifi_sta":   true,
+	"wifi_sta1":  true,
+	"ap_roaming": true,
+	"login":      true,
+	"cloud":      true,
+	"actions":    true,
+	"relays":     true,
+	"rollers":    true,
+	"lights":     true,
+	"meters":     true,
+	"emeters":    true,
+	"inputs":     true,
+}
+
+// Params converts settings to query params. Nested objects are flattened with
+// an underscore as the Gen1 API expects (mqtt.enable becomes mqtt_enable) and
+// lists of values are joined with commas. Read-only keys such as device, keys
+// set with their own endpoint such as wifi_sta and login, and lists of objects
+// are not converted; they are returned as skipped.
+func Params(settings map[string]any) (url.Values, []string) {
+
+	params := url.Values{}
+	var skipped []string
+
+	var flatten func(prefix string, v any)
+
+	flatten = func(prefix string, v any) {
+
+		switch v := v.(type) {
+
+		case nil:
+
+		case map[string]any:
+			for key, value := range v {
+				flatten(join(prefix, key), value)
+			}
+
+		case []any:
+			var values []string
+			for _, value := range v {
+				s, ok := scalar(value)
+				if !ok {
+					skipped = append(skipped, prefix)
+					return
+				}
+				values = append(values, s)
+			}
+			params.Set(prefix, strings.Join(values, ","))
+
+		default:
+			s, ok := scalar(v)
+			if !ok {
+				skipped = append(skipped, prefix)
+				return
+			}
+			params.Set(prefix, s)
+		}
+	}
+
+	for key, value := range settings {
+		if readOnlySettings[key] || subEndpointSettings[key] {
+			skipped = append(skipped, key)
+			continue
+		}
+		flatten(key, value)
+	}
+
+	sort.Strings(skipped)
+	return params, skipped
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+func scalar(v any) (string, bool) {
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,65 @@
+package gen1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		params   map[string]string
+		skipped  []string
+	}{
+		{
+			name:     "flatten",
+			settings: map[string]any{"name": "porch", "mqtt": map[string]any{"enable": true, "keep_alive": 60.0}},
+			params:   map[string]string{"name": "porch", "mqtt_enable": "true", "mqtt_keep_alive": "60"},
+		},
+		{
+			name:     "list of values",
+			settings: map[string]any{"sntp": map[string]any{"server": "time.google.com"}, "coiot": map[string]any{"peer": []any{"a", "b"}}},
+			params:   map[string]string{"sntp_server": "time.google.com", "coiot_peer": "a,b"},
+		},
+		{
+			name: "get output",
+			settings: map[string]any{
+				"device":             map[string]any{"type": "SHSW-1", "mac": "AABBCC"},
+				"wifi_sta":           map[string]any{"enabled": true, "ssid": "home"},
+				"login":              map[string]any{"enabled": false, "username": "admin"},
+				"cloud":              map[string]any{"enabled": true, "connected": true},
+				"fw":                 "20230913-112003/v1.14.0-gcb84623",
+				"relays":             []any{map[string]any{"name": nil}},
+				"led_status_disable": false,
+				"eco":                []any{map[string]any{"x": 1.0}},
+			},
+			params:  map[string]string{"led_status_disable": "false"},
+			skipped: []string{"cloud", "device", "eco", "fw", "login", "relays", "wifi_sta"},
+		},
+		{
+			name:     "null",
+			settings: map[string]any{"name": nil},
+			params:   map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+
+		params, skipped := Params(test.settings)
+
+		got := make(map[string]string)
+		for key := range params {
+			got[key] = params.Get(key)
+		}
+
+		if !reflect.DeepEqual(got, test.params) {
+			t.Errorf("%s: params = %v; want %v", test.name, got, test.params)
+		}
+
+		if !reflect.DeepEqual(skipped, test.skipped) {
+			t.Errorf("%s: skipped = %v; want %v", test.name, skipped, test.skipped)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package testdevice provides fake Shelly devices on httptest servers for the
+// tests of this module
+package testdevice
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	// Plus is the /shelly response of a Plus device
+	Plus = `{"gen":2}`
+	// Gen1 is the /shelly response of a Gen1 device
+	Gen1 = `{"type":"SHSW-1","mac":"AABBCC"}`
+)
+
+// Handler answers an RPC request. The result is encoded as JSON; a
+// json.RawMessage is sent as is. An *rpc.Error is returned to the client as
+// the error of the response.
+type Handler func(method string, params map[string]any) (any, error)
+
+// Config is the config of a fake device
+type Config struct {
+	// Shelly is the /shelly response; defaults to Plus
+	Shelly string
+	// RPC answers POST /rpc requests; if nil every method is not found
+	RPC Handler
+	// Paths answers other requests by path, as the Gen1 HTTP API does
+	Paths map[string]string
+	// Websocket serves websocket requests such as /rpc and /debug/log. The
+	// connection is closed when it returns.
+	Websocket func(path string, conn *websocket.Conn)
+	// Hold, if set, holds every request until it is closed
+	Hold <-chan struct{}
+}
+
+// Call is an RPC request received by a fake device
+type Call struct {
+	Method string
+	Params map[string]any
+}
+
+// Device is a fake device. HTTP requests are answered one at a time, so a
+// Handler may keep state without locking.
+type Device struct {
+	Hostname string
+	mutex    sync.Mutex
+	calls    []*Call
+}
+
+// New starts a fake device that is stopped when the test ends
+func New(t testing.TB, config *Config) *Device {
+
+	d := &Device{}
+
+	shelly := config.Shelly
+	if shelly == "" {
+		shelly = Plus
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if config.Hold != nil {
+			<-config.Hold
+		}
+
+		if config.Websocket != nil && websocket.IsWebSocketUpgrade(r) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			config.Websocket(r.URL.Path, conn)
+			return
+		}
+
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		switch r.URL.Path {
+
+		case "/shelly":
+			w.Write([]byte(shelly))
+
+		case "/rpc":
+			d.serveRPC(w, r, config.RPC)
+
+		default:
+			response, ok := config.Paths[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(response))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	d.Hostname = strings.TrimPrefix(server.URL, "http://")
+	return d
+}
+
+func (t *Device) serveRPC(w http.ResponseWriter, r *http.Request, handler Handler) {
+
+	var request struct {
+		ID     int            `json:"id"`
+		Method string         `json:"method"`
+		Params map[string]any `json:"params"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t.calls = append(t.calls, &Call{Method: request.Method, Params: request.Params})
+
+	response := map[string]any{"id": request.ID}
+
+	var result any
+	if handler == nil {
+		err = NotFound(request.Method)
+	} else {
+		result, err = handler(request.Method, request.Params)
+	}
+
+	var rpcErr *rpc.Error
+
+	switch {
+	case errors.As(err, &rpcErr):
+		response["error"] = rpcErr
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	default:
+		response["result"] = result
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(data)
+}
+
+// Client returns an RPC client for the device
+func (t *Device) Client() *rpc.Client {
+	return rpc.New(&rpc.Config{Hostname: t.Hostname})
+}
+
+// Inventory returns an inventory device with name for the device
+func (t *Device) Inventory(name string) *inventory.Device {
+	return &inventory.Device{Name: name, Hostname: t.Hostname}
+}
+
+// Calls returns the RPC requests received so far
+func (t *Device) Calls() []*Call {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]*Call(nil), t.calls...)
+}
+
+// Methods returns the methods of the RPC requests received so far
+func (t *Device) Methods() []string {
+	var methods []string
+	for _, call := range t.Calls() {
+		methods = append(methods, call.Method)
+	}
+	return methods
+}
+
+// Results returns a handler that answers each method with the JSON in
+// results. Other methods are not found.
+func Results(results map[string]string) Handler {
+	return func(method string, params map[string]any) (any, error) {
+		result, ok := results[method]
+		if !ok {
+			return nil, NotFound(method)
+		}
+		return json.RawMessage(result), nil
+	}
+}
+
+// NotFound returns the error of a device for an unknown method
+func NotFound(method string) error {
+	return &rpc.Error{Code: 404, Message: "No handler for " + method}
+}
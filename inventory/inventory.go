@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	// ConfigDirEnvVar overrides the config dir
+	ConfigDirEnvVar = "SHELLY_CONFIG_DIR"
+
+	configDirName = "shelly-cli"
+	fileName      = "inventory.yaml"
+)
+
+type Device struct {
+	Name     string `json:"name" yaml:"name"`
+	Hostname string `json:"hostname" yaml:"hostname"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// Gen is the device generation; 1 for Gen1 devices. Zero if unknown.
+	Gen int `json:"gen,omitempty" yaml:"gen,omitempty"`
+}
+
+// Client returns a new RPC client for the device
+func (t *Device) Client() *rpc.Client {
+	return rpc.New(&rpc.Config{
+		Hostname: t.Hostname,
+		Password: t.Password,
+	})
+}
+
+type Inventory struct {
+	Devices []*Device `json:"devices" yaml:"devices"`
+}
+
+// ConfigDir returns the config dir. The dir is not created.
+func ConfigDir() (string, error) {
+
+	dir := os.Getenv(ConfigDirEnvVar)
+	if dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, configDirName), nil
+}
+
+// Load loads the inventory from the config dir. An empty inventory is returned
+// if the inventory file does not exist.
+func Load() (*Inventory, error) {
+
+	dir, err := ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &Inventory{}
+
+	data, err := os.ReadFile(filepath.Join(dir, fileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return inventory, nil
+		}
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, inventory)
+	if err != nil {
+		return nil, fmt.Errorf("inventory %s is invalid: %w", filepath.Join(dir, fileName), err)
+	}
+
+	return inventory, nil
+}
+
+// Save writes the inventory to the config dir. The file is only readable by the
+// user as it may contain passwords.
+func (t *Inventory) Save() error {
+
+	dir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(t.Devices, func(i, j int) bool {
+		return t.Devices[i].Name < t.Devices[j].Name
+	})
+
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, fileName), data, 0600)
+}
+
+// Get returns the device with name or nil if not found. Names are case
+// insensitive.
+func (t *Inventory) Get(name string) *Device {
+	for _, device := range t.Devices {
+		if strings.EqualFold(device.Name, name) {
+			return device
+		}
+	}
+	return nil
+}
+
+// Select returns the devices with names or all devices if names is empty. An
+// error is returned if any of the names is not found.
+func (t *Inventory) Select(names []string) ([]*Device, error) {
+
+	if len(names) == 0 {
+		if len(t.Devices) == 0 {
+			return nil, fmt.Errorf("inventory is empty")
+		}
+		return t.Devices, nil
+	}
+
+	var devices []*Device
+
+	for _, name := range names {
+		device := t.Get(name)
+		if device == nil {
+			return nil, fmt.Errorf("device %s not found in inventory", name)
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// Add adds or replaces device
+func (t *Inventory) Add(device *Device) {
+
+	for i, existing := range t.Devices {
+		if strings.EqualFold(existing.Name, device.Name) {
+			t.Devices[i] = device
+			return
+		}
+	}
+
+	t.Devices = append(t.Devices, device)
+}
+
+// Remove removes the device with name and returns true if it was found
+func (t *Inventory) Remove(name string) bool {
+
+	for i, existing := range t.Devices {
+		if strings.EqualFold(existing.Name, name) {
+			t.Devices = append(t.Devices[:i], t.Devices[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
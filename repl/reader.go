@@ -0,0 +1,433 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const defaultHistorySize = 1000
+
+// ErrInterrupted is returned by ReadLine if the line is cancelled with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+type Config struct {
+	// HistoryFile is loaded on start and appended to; optional
+	HistoryFile string
+	// HistorySize is the number of lines kept; defaults to 1000
+	HistorySize int
+	// Complete returns the candidates for the word of line that ends at pos
+	// and the start of that word
+	Complete func(line string, pos int) (start int, candidates []string)
+}
+
+// Reader reads lines from STDIN. If STDIN is a terminal the line can be edited
+// (Emacs keys and arrows), recalled from history (up and down) and completed
+// (tab). Otherwise lines are read as they are.
+type Reader struct {
+	config   *Config
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+	history  []string
+}
+
+func New(config *Config) *Reader {
+
+	if config.HistorySize == 0 {
+		config.HistorySize = defaultHistorySize
+	}
+
+	fd := int(os.Stdin.Fd())
+
+	t := &Reader{
+		config:   config,
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		fd:       fd,
+		terminal: term.IsTerminal(fd),
+	}
+
+	if config.HistoryFile != "" {
+		data, err := os.ReadFile(config.HistoryFile)
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					t.history = append(t.history, line)
+				}
+			}
+			t.trimHistory()
+		}
+	}
+
+	return t
+}
+
+// Terminal returns true if STDIN is a terminal
+func (t *Reader) Terminal() bool {
+	return t.terminal
+}
+
+// AddHistory adds line to the history and appends it to the history file.
+// Empty lines and repeats of the previous line are not added.
+func (t *Reader) AddHistory(line string) error {
+
+	if strings.TrimSpace(line) == "" || (len(t.history) > 0 && t.history[len(t.history)-1] == line) {
+		return nil
+	}
+
+	t.history = append(t.history, line)
+	t.trimHistory()
+
+	if t.config.HistoryFile == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(t.config.HistoryFile), 0700)
+	if err != nil {
+		return err
+	}
+
+	// The file is rewritten so that it is trimmed too
+	return os.WriteFile(t.config.HistoryFile, []byte(strings.Join(t.history, "\n")+"\n"), 0600)
+}
+
+func (t *Reader) trimHistory() {
+	if len(t.history) > t.config.HistorySize {
+		t.history = t.history[len(t.history)-t.config.HistorySize:]
+	}
+}
+
+// ReadLine reads a line. It returns io.EOF on Ctrl-D at an empty line or at
+// the end of the input and ErrInterrupted on Ctrl-C.
+func (t *Reader) ReadLine(prompt string) (string, error) {
+
+	if !t.terminal {
+		line, err := t.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+
+	defer term.Restore(t.fd, state)
+
+	e := &editor{reader: t, prompt: prompt, historyIndex: len(t.history)}
+	e.refresh()
+
+	return e.run()
+}
+
+// editor is the state of the line being edited
+type editor struct {
+	reader       *Reader
+	prompt       string
+	line         []rune
+	pos          int
+	historyIndex int
+	// edited is the line being edited while browsing the history
+	edited  []rune
+	lastTab bool
+}
+
+func (t *editor) run() (string, error) {
+
+	for {
+
+		r, _, err := t.reader.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		tab := false
+
+		switch r {
+
+		case '\r', '\n':
+			t.write("\r\n")
+			return string(t.line), nil
+
+		case 3: // Ctrl-C
+			t.write("^C\r\n")
+			return "", ErrInterrupted
+
+		case 4: // Ctrl-D
+			if len(t.line) == 0 {
+				t.write("\r\n")
+				return "", io.EOF
+			}
+			t.delete()
+
+		case 127, 8: // Backspace, Ctrl-H
+			if t.pos > 0 {
+				t.pos--
+				t.delete()
+			}
+
+		case 1: // Ctrl-A
+			t.pos = 0
+
+		case 5: // Ctrl-E
+			t.pos = len(t.line)
+
+		case 2: // Ctrl-B
+			t.left()
+
+		case 6: // Ctrl-F
+			t.right()
+
+		case 11: // Ctrl-K
+			t.line = t.line[:t.pos]
+
+		case 21: // Ctrl-U
+			t.line = t.line[t.pos:]
+			t.pos = 0
+
+		case 23: // Ctrl-W
+			start := t.pos
+			for start > 0 && t.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && t.line[start-1] != ' ' {
+				start--
+			}
+			t.line = append(t.line[:start], t.line[t.pos:]...)
+			t.pos = start
+
+		case 12: // Ctrl-L
+			t.write("\x1b[H\x1b[2J")
+
+		case 16: // Ctrl-P
+			t.history(-1)
+
+		case 14: // Ctrl-N
+			t.history(1)
+
+		case '\t':
+			tab = true
+			t.complete()
+
+		case 27: // Escape sequence
+			t.escape()
+
+		default:
+			if r >= 32 && r != utf8.RuneError {
+				t.line = append(t.line[:t.pos], append([]rune{r}, t.line[t.pos:]...)...)
+				t.pos++
+			}
+		}
+
+		t.lastTab = tab
+		t.refresh()
+	}
+}
+
+// escape handles the CSI and SS3 sequences of the arrow, home, end and delete
+// keys. Other sequences are ignored.
+func (t *editor) escape() {
+
+	r, _, err := t.reader.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+
+	var param []rune
+
+	for {
+		r, _, err = t.reader.in.ReadRune()
+		if err != nil {
+			return
+		}
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+		param = append(param, r)
+	}
+
+	switch r {
+	case 'A':
+		t.history(-1)
+	case 'B':
+		t.history(1)
+	case 'C':
+		t.right()
+	case 'D':
+		t.left()
+	case 'H':
+		t.pos = 0
+	case 'F':
+		t.pos = len(t.line)
+	case '~':
+		switch string(param) {
+		case "1", "7":
+			t.pos = 0
+		case "4", "8":
+			t.pos = len(t.line)
+		case "3":
+			t.delete()
+		}
+	}
+}
+
+func (t *editor) left() {
+	if t.pos > 0 {
+		t.pos--
+	}
+}
+
+func (t *editor) right() {
+	if t.pos < len(t.line) {
+		t.pos++
+	}
+}
+
+// delete deletes the rune at the cursor
+func (t *editor) delete() {
+	if t.pos < len(t.line) {
+		t.line = append(t.line[:t.pos], t.line[t.pos+1:]...)
+	}
+}
+
+// history moves by delta in the history. The edited line is kept when moving
+// past the last entry.
+func (t *editor) history(delta int) {
+
+	history := t.reader.history
+
+	index := t.historyIndex + delta
+	if index < 0 || index > len(history) {
+		return
+	}
+
+	if t.historyIndex == len(history) {
+		t.edited = t.line
+	}
+
+	t.historyIndex = index
+
+	if index == len(history) {
+		t.line = t.edited
+	} else {
+		t.line = []rune(history[index])
+	}
+
+	t.pos = len(t.line)
+}
+
+// complete completes the word at the cursor. A single candidate is inserted
+// with a trailing space, multiple candidates are completed to their common
+// prefix and listed on the second tab.
+func (t *editor) complete() {
+
+	if t.reader.config.Complete == nil {
+		return
+	}
+
+	line := string(t.line[:t.pos])
+
+	start, candidates := t.reader.config.Complete(line, len(line))
+	if len(candidates) == 0 {
+		return
+	}
+
+	word := line[start:]
+
+	replace := func(s string) {
+		rest := t.line[t.pos:]
+		t.line = append([]rune(line[:start]+s), rest...)
+		t.pos = utf8.RuneCountInString(line[:start] + s)
+	}
+
+	if len(candidates) == 1 {
+		replace(candidates[0] + " ")
+		return
+	}
+
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		replace(prefix)
+		return
+	}
+
+	if t.lastTab {
+		t.list(candidates)
+	}
+}
+
+// list writes the candidates in columns below the line
+func (t *editor) list(candidates []string) {
+
+	sort.Strings(candidates)
+
+	width := 0
+	for _, c := range candidates {
+		if utf8.RuneCountInString(c) > width {
+			width = utf8.RuneCountInString(c)
+		}
+	}
+	width += 2
+
+	columns := 80
+	if w, _, err := term.GetSize(t.reader.fd); err == nil && w > 0 {
+		columns = w
+	}
+
+	perRow := columns / width
+	if perRow < 1 {
+		perRow = 1
+	}
+
+	var b strings.Builder
+
+	b.WriteString("\r\n")
+	for i, c := range candidates {
+		b.WriteString(c)
+		if (i+1)%perRow == 0 || i == len(candidates)-1 {
+			b.WriteString("\r\n")
+		} else {
+			b.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(c)))
+		}
+	}
+
+	t.write(b.String())
+}
+
+// refresh redraws the prompt and line and places the cursor
+func (t *editor) refresh() {
+	s := "\r" + t.prompt + string(t.line) + "\x1b[K"
+	if back := len(t.line) - t.pos; back > 0 {
+		s += fmt.Sprintf("\x1b[%dD", back)
+	}
+	t.write(s)
+}
+
+func (t *editor) write(s string) {
+	io.WriteString(t.reader.out, s)
+}
+
+func commonPrefix(values []string) string {
+
+	prefix := values[0]
+
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
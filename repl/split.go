@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split splits line into words like a POSIX shell does for simple commands.
+// Words are separated by blanks; single quotes preserve everything, double
+// quotes preserve everything but backslash escapes of " and \, and a
+// backslash outside quotes escapes the next character. This allows JSON such
+// as '{"id": 0}' as a single word.
+func Split(line string) ([]string, error) {
+
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(line)
+
+	for i := 0; i < len(runes); i++ {
+
+		r := runes[i]
+
+		switch {
+
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+
+		case r == '\\':
+			inWord = true
+			if i+1 < len(runes) {
+				i++
+				word.WriteRune(runes[i])
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
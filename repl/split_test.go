@@ -0,0 +1,43 @@
+package repl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "", want: nil},
+		{line: "  status \t -o  yaml ", want: []string{"status", "-o", "yaml"}},
+		{line: `call Switch.Set '{"id": 0, "on": true}'`, want: []string{"call", "Switch.Set", `{"id": 0, "on": true}`}},
+		{line: `call Script.Eval "{\"id\": 1, \"code\": \"a \\\\ b\"}"`, want: []string{"call", "Script.Eval", `{"id": 1, "code": "a \\ b"}`}},
+		{line: `say "it's" 'a "b"'`, want: []string{"say", "it's", `a "b"`}},
+		{line: `a\ b c\'d`, want: []string{"a b", "c'd"}},
+		{line: `"\n" '\n'`, want: []string{`\n`, `\n`}},
+		{line: `--name=""`, want: []string{"--name="}},
+		{line: `''`, want: []string{""}},
+	}
+
+	for _, test := range tests {
+
+		got, err := Split(test.line)
+		if err != nil {
+			t.Errorf("Split(%q): %v", test.line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Split(%q) = %q; want %q", test.line, got, test.want)
+		}
+	}
+
+	for _, line := range []string{`call '{"id":0}`, `echo "a`, `echo "a\"`} {
+		if _, err := Split(line); err == nil {
+			t.Errorf("Split(%q) returned no error", line)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// authParams is the auth object of a request sent over the websocket
+type authParams struct {
+	Realm     string `json:"realm"`
+	Username  string `json:"username"`
+	Nonce     int64  `json:"nonce"`
+	CNonce    string `json:"cnonce"`
+	Response  string `json:"response"`
+	Algorithm string `json:"algorithm"`
+}
+
+type frameRequest struct {
+	ID     int         `json:"id"`
+	Src    string      `json:"src"`
+	Method string      `json:"method"`
+	Auth   *authParams `json:"auth,omitempty"`
+}
+
+// Notifications opens the /rpc websocket and registers src for device
+// notifications (NotifyStatus, NotifyFullStatus and NotifyEvent). Unlike HTTP,
+// the websocket authenticates each request; the challenge is returned as a 401
+// error and the request is repeated with an auth object. Frames read from the
+// returned connection include notifications and responses to requests sent by
+// the caller.
+func (t *Client) Notifications(ctx context.Context, src string) (*websocket.Conn, error) {
+
+	conn, err := t.Dial(ctx, "/rpc")
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	} else {
+		conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+	}
+
+	err = t.register(conn, src)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	return conn, nil
+}
+
+func (t *Client) register(conn *websocket.Conn, src string) error {
+
+	req := &frameRequest{ID: 1, Src: src, Method: "Shelly.GetDeviceInfo"}
+
+	for {
+
+		err := conn.WriteJSON(req)
+		if err != nil {
+			return err
+		}
+
+		resp, err := readResponse(conn, req.ID)
+		if err != nil {
+			return err
+		}
+
+		if resp.Error == nil {
+			return nil
+		}
+
+		if resp.Error.Code != 401 || req.Auth != nil {
+			if resp.Error.Code == 401 {
+				return fmt.Errorf("authentication to device %s failed", t.config.Hostname)
+			}
+			return resp.Error
+		}
+
+		if t.config.Password == "" {
+			return fmt.Errorf("device %s requires a password", t.config.Hostname)
+		}
+
+		c := &struct {
+			Realm     string `json:"realm"`
+			Nonce     int64  `json:"nonce"`
+			Algorithm string `json:"algorithm"`
+		}{}
+
+		err = json.Unmarshal([]byte(resp.Error.Message), c)
+		if err != nil {
+			return fmt.Errorf("invalid authentication challenge %q", resp.Error.Message)
+		}
+
+		cnonce := newCnonce()
+		ha1 := hash(Username + ":" + c.Realm + ":" + t.config.Password)
+		ha2 := hash("dummy_method:dummy_uri")
+
+		req = &frameRequest{
+			ID:     req.ID + 1,
+			Src:    src,
+			Method: req.Method,
+			Auth: &authParams{
+				Realm:     c.Realm,
+				Username:  Username,
+				Nonce:     c.Nonce,
+				CNonce:    cnonce,
+				Response:  hash(fmt.Sprintf("%s:%d:%d:%s:auth:%s", ha1, c.Nonce, 1, cnonce, ha2)),
+				Algorithm: "SHA-256",
+			},
+		}
+	}
+}
+
+// readResponse reads frames until the response to id. Notifications received
+// before the response are dropped.
+func readResponse(conn *websocket.Conn, id int) (*response, error) {
+
+	for {
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &response{}
+
+		err = json.Unmarshal(data, resp)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.ID == id {
+			return resp, nil
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"testing"
+)
+
+func TestMethod(t *testing.T) {
+
+	tests := map[string]string{
+		"switch": "Switch.Set",
+		"light":  "Light.Set",
+		"rgb":    "RGB.Set",
+		"rgbw":   "RGBW.Set",
+		"cct":    "CCT.Set",
+	}
+
+	for component, want := range tests {
+		if got := Method(component, "Set"); got != want {
+			t.Errorf("Method(%q) = %q; want %q", component, got, want)
+		}
+	}
+}
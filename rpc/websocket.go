@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// Dial opens a websocket to path (for example /debug/log) on the device. Digest
+// auth is performed if the device requires it.
+func (t *Client) Dial(ctx context.Context, path string) (*websocket.Conn, error) {
+
+	if t.config.Hostname == "" {
+		return nil, fmt.Errorf("hostname is required")
+	}
+
+	url := "ws://" + t.config.Hostname + path
+
+	header := http.Header{}
+
+	authorization := t.authorization(http.MethodGet, path)
+	if authorization != "" {
+		header.Set("Authorization", authorization)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err == nil {
+		return conn, nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		return nil, err
+	}
+
+	if t.config.Password == "" {
+		return nil, fmt.Errorf("device %s requires a password", t.config.Hostname)
+	}
+
+	c, err := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	t.challenge = c
+	t.nc = 0
+	t.mutex.Unlock()
+
+	header.Set("Authorization", t.authorization(http.MethodGet, path))
+
+	conn, _, err = websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection to device %s failed: %w", t.config.Hostname, err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/device"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxBodySize    = 1 << 20
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+type Config struct {
+	Inventory *inventory.Inventory
+	// Tokens are the accepted bearer tokens. Authentication is disabled if
+	// empty.
+	Tokens []string
+	// Timeout is the timeout of the device requests of an API request
+	Timeout time.Duration
+	// Logf is called with a line for each request
+	Logf func(format string, args ...any)
+}
+
+// Server is an HTTP handler for the REST API in front of the inventory devices.
+// Clients authenticate with a token; the device credentials stay with the
+// server.
+type Server struct {
+	config  *Config
+	mutex   sync.Mutex
+	devices map[string]*deviceEntry
+}
+
+// deviceEntry caches the device of an inventory entry. Its mutex is held while
+// the generation is detected so that a slow device only holds up its own
+// requests.
+type deviceEntry struct {
+	mutex  sync.Mutex
+	device device.Device
+}
+
+// DeviceInfo is the inventory entry of a device without its credentials
+type DeviceInfo struct {
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+	Gen      int    `json:"gen,omitempty"`
+}
+
+// RPCRequest is the body of POST /rpc
+type RPCRequest struct {
+	Device string          `json:"device"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse is the response of POST /rpc
+type RPCResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// ErrorResponse is the body of every error response. Code is the device RPC
+// error code if the device returned an error.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  int    `json:"code,omitempty"`
+}
+
+// httpError is an error with the HTTP status of the response
+type httpError struct {
+	status int
+	err    error
+}
+
+func (t *httpError) Error() string {
+	return t.err.Error()
+}
+
+func newError(status int, format string, args ...any) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func New(config *Config) *Server {
+
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.Logf == nil {
+		config.Logf = func(string, ...any) {}
+	}
+
+	return &Server{
+		config:  config,
+		devices: make(map[string]*deviceEntry),
+	}
+}
+
+// ServeHTTP authenticates, logs and routes the request. /openapi.json does not
+// require authentication.
+func (t *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	defer func() {
+		t.config.Logf("%s %s %s %d %s", r.RemoteAddr, r.Method, r.URL.Path, recorder.status,
+			time.Since(start).Round(time.Millisecond))
+	}()
+
+	if r.URL.Path == "/openapi.json" {
+		if r.Method != http.MethodGet {
+			writeError(recorder, newError(http.StatusMethodNotAllowed, "method %s is not allowed", r.Method))
+			return
+		}
+		recorder.Header().Set("Content-Type", "application/json")
+		recorder.Write(openAPI)
+		return
+	}
+
+	if !t.authorized(r) {
+		recorder.Header().Set("WWW-Authenticate", `Bearer realm="shelly-cli"`)
+		writeError(recorder, newError(http.StatusUnauthorized, "a valid bearer token is required"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), t.config.Timeout)
+	defer cancel()
+
+	result, err := t.route(ctx, r)
+	if err != nil {
+		writeError(recorder, err)
+		return
+	}
+
+	writeJSON(recorder, http.StatusOK, result)
+}
+
+func (t *Server) authorized(r *http.Request) bool {
+
+	if len(t.config.Tokens) == 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	for _, valid := range t.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// route dispatches the request on its path:
+//
+//	GET  /devices
+//	GET  /devices/{name}/info
+//	GET  /devices/{name}/status
+//	POST /devices/{name}/{component}/{id}/{on|off|toggle}
+//	POST /rpc
+func (t *Server) route(ctx context.Context, r *http.Request) (any, error) {
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+
+	case len(parts) == 1 && parts[0] == "devices":
+		if err := requireMethod(r, http.MethodGet); err != nil {
+			return nil, err
+		}
+		return t.list(), nil
+
+	case len(parts) == 3 && parts[0] == "devices" && (parts[2] == "info" || parts[2] == "status"):
+		if err := requireMethod(r, http.MethodGet); err != nil {
+			return nil, err
+		}
+		d, err := t.device(ctx, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if parts[2] == "info" {
+			return d.Info(ctx)
+		}
+		return d.Status(ctx)
+
+	case len(parts) == 5 && parts[0] == "devices":
+		if err := requireMethod(r, http.MethodPost); err != nil {
+			return nil, err
+		}
+		return t.set(ctx, parts[1], parts[2], parts[3], parts[4])
+
+	case len(parts) == 1 && parts[0] == "rpc":
+		if err := requireMethod(r, http.MethodPost); err != nil {
+			return nil, err
+		}
+		return t.rpc(ctx, r)
+	}
+
+	return nil, newError(http.StatusNotFound, "path %s not found", r.URL.Path)
+}
+
+func (t *Server) list() []*DeviceInfo {
+
+	devices := []*DeviceInfo{}
+
+	for _, d := range t.config.Inventory.Devices {
+		devices = append(devices, &DeviceInfo{Name: d.Name, Hostname: d.Hostname, Gen: d.Gen})
+	}
+
+	return devices
+}
+
+func (t *Server) set(ctx context.Context, name, component, id, action string) (*device.Output, error) {
+
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 0 {
+		return nil, newError(http.StatusBadRequest, "id %s is invalid", id)
+	}
+
+	switch component {
+	case "switch", "relay", "light", "rgb", "rgbw", "cct":
+	default:
+		return nil, newError(http.StatusBadRequest, "component %s is invalid; expect switch, relay, light, rgb, rgbw or cct", component)
+	}
+
+	switch device.Action(action) {
+	case device.On, device.Off, device.Toggle:
+	default:
+		return nil, newError(http.StatusBadRequest, "action %s is invalid; expect on, off or toggle", action)
+	}
+
+	d, err := t.device(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Set(ctx, component, i, device.Action(action))
+}
+
+func (t *Server) rpc(ctx context.Context, r *http.Request) (*RPCResponse, error) {
+
+	request := &RPCRequest{}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(request)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "body is invalid: %s", err)
+	}
+
+	if request.Method == "" {
+		return nil, newError(http.StatusBadRequest, "method is required")
+	}
+
+	inv, err := t.inventoryDevice(request.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	// The generation is detected if the inventory does not have it
+	d, err := t.device(ctx, inv.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Gen() == 1 {
+		return nil, newError(http.StatusBadRequest, "device %s is a Gen1 device and does not support RPC", inv.Name)
+	}
+
+	var params any
+	if len(request.Params) > 0 {
+		params = request.Params
+	}
+
+	response := &RPCResponse{}
+
+	err = inv.Client().Call(ctx, request.Method, params, &response.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// device returns the device with name. Devices are cached so that the
+// generation of devices without one in the inventory is detected once. A
+// failed detection is retried with the next request.
+func (t *Server) device(ctx context.Context, name string) (device.Device, error) {
+
+	inv, err := t.inventoryDevice(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	entry, ok := t.devices[inv.Name]
+	if !ok {
+		entry = &deviceEntry{}
+		t.devices[inv.Name] = entry
+	}
+	t.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if entry.device != nil {
+		return entry.device, nil
+	}
+
+	d, err := device.New(ctx, &device.Config{
+		Hostname: inv.Hostname,
+		Username: inv.Username,
+		Password: inv.Password,
+		Gen:      inv.Gen,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entry.device = d
+	return d, nil
+}
+
+func (t *Server) inventoryDevice(name string) (*inventory.Device, error) {
+
+	if name == "" {
+		return nil, newError(http.StatusBadRequest, "device is required")
+	}
+
+	d := t.config.Inventory.Get(name)
+	if d == nil {
+		return nil, newError(http.StatusNotFound, "device %s not found in inventory", name)
+	}
+
+	return d, nil
+}
+
+func requireMethod(r *http.Request, method string) error {
+	if r.Method != method {
+		return newError(http.StatusMethodNotAllowed, "method %s is not allowed; expect %s", r.Method, method)
+	}
+	return nil
+}
+
+// writeError writes err as an ErrorResponse. Errors of the device are reported
+// as bad gateway.
+func writeError(w http.ResponseWriter, err error) {
+
+	response := &ErrorResponse{Error: err.Error()}
+	status := http.StatusBadGateway
+
+	var httpErr *httpError
+	var rpcErr *rpc.Error
+
+	switch {
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	case errors.As(err, &rpcErr):
+		response.Code = rpcErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+
+	writeJSON(w, status, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// statusRecorder records the response status for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (t *statusRecorder) WriteHeader(status int) {
+	t.status = status
+	t.ResponseWriter.WriteHeader(status)
+}
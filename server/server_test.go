@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+// newDevice returns an inventory device that answers /shelly with shelly and
+// every RPC request with {"ok":true}. If release is set every request waits
+// for it to be closed.
+func newDevice(t *testing.T, name string, shelly string, release chan struct{}) *inventory.Device {
+	return testdevice.New(t, &testdevice.Config{
+		Shelly: shelly,
+		RPC: func(method string, params map[string]any) (any, error) {
+			return map[string]any{"ok": true}, nil
+		},
+		Hold: release,
+	}).Inventory(name)
+}
+
+// post sends a POST /rpc request to server and returns the status and body
+func post(server *Server, body string) (int, string) {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body)))
+	return recorder.Code, strings.TrimSpace(recorder.Body.String())
+}
+
+func TestRPC(t *testing.T) {
+
+	server := New(&Config{Inventory: &inventory.Inventory{Devices: []*inventory.Device{
+		newDevice(t, "plus", `{"gen":2}`, nil),
+		newDevice(t, "relay", `{"type":"SHSW-1"}`, nil),
+	}}})
+
+	tests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{body: `{"device":"plus","method":"Switch.GetStatus","params":{"id":0}}`, status: 200, want: `"ok":true`},
+		{body: `{"device":"relay","method":"Switch.GetStatus"}`, status: 400, want: "is a Gen1 device"},
+		{body: `{"device":"x","method":"Switch.GetStatus"}`, status: 404, want: "not found in inventory"},
+		{body: `{"device":"plus"}`, status: 400, want: "method is required"},
+		{body: `{"device":"plus","method":"x","extra":1}`, status: 400, want: "body is invalid"},
+	}
+
+	for _, test := range tests {
+		status, body := post(server, test.body)
+		if status != test.status || !strings.Contains(body, test.want) {
+			t.Errorf("%s: got %d %s; want %d %q", test.body, status, body, test.status, test.want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+
+	plus := testdevice.New(t, &testdevice.Config{RPC: testdevice.Results(map[string]string{
+		"Switch.Set":       `{"was_on":false}`,
+		"Switch.GetStatus": `{"id":0,"output":true}`,
+		"Switch.GetConfig": `{"id":0,"name":"Pump"}`,
+	})})
+
+	server := New(&Config{Inventory: &inventory.Inventory{Devices: []*inventory.Device{plus.Inventory("plus")}}})
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{path: "/devices/plus/switch/0/on", status: 200, want: `"name":"Pump"`},
+		{path: "/devices/plus/switch/0/blink", status: 400, want: "action blink is invalid"},
+		{path: "/devices/plus/dimmer/0/on", status: 400, want: "component dimmer is invalid"},
+		{path: "/devices/plus/switch/x/on", status: 400, want: "id x is invalid"},
+		{path: "/devices/other/switch/0/on", status: 404, want: "not found in inventory"},
+	}
+
+	for _, test := range tests {
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, test.path, nil))
+
+		if body := recorder.Body.String(); recorder.Code != test.status || !strings.Contains(body, test.want) {
+			t.Errorf("%s: got %d %s; want %d %q", test.path, recorder.Code, body, test.status, test.want)
+		}
+	}
+}
+
+func TestDeviceDetection(t *testing.T) {
+
+	release := make(chan struct{})
+
+	server := New(&Config{Inventory: &inventory.Inventory{Devices: []*inventory.Device{
+		newDevice(t, "slow", `{"gen":2}`, release),
+		newDevice(t, "fast", `{"gen":2}`, nil),
+	}}})
+
+	slow := make(chan int)
+
+	go func() {
+		status, _ := post(server, `{"device":"slow","method":"Shelly.GetStatus"}`)
+		slow <- status
+	}()
+
+	// Wait until the slow device is being detected
+	for {
+		server.mutex.Lock()
+		_, ok := server.devices["slow"]
+		server.mutex.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan int)
+
+	go func() {
+		status, _ := post(server, `{"device":"fast","method":"Shelly.GetStatus"}`)
+		done <- status
+	}()
+
+	select {
+	case status := <-done:
+		if status != http.StatusOK {
+			t.Errorf("fast device status = %d", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the detection of the slow device blocked the fast device")
+	}
+
+	close(release)
+
+	if status := <-slow; status != http.StatusOK {
+		t.Errorf("slow device status = %d", status)
+	}
+
+	var devices []*DeviceInfo
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/devices", nil))
+	json.NewDecoder(recorder.Body).Decode(&devices)
+
+	if len(devices) != 2 || devices[0].Name != "slow" {
+		t.Errorf("devices = %v", devices)
+	}
+}
@@ -0,0 +1,8 @@
+package types
+
+// Table is implemented by results that can be written in the table or csv output
+// formats
+type Table interface {
+	Header() []string
+	Rows() [][]string
+}
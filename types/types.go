@@ -1,11 +1,15 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
 )
 
 type File struct {
@@ -96,6 +100,14 @@ func (t *Files) GetFile(name string) *File {
 	return nil
 }
 
+// GetSingleFile returns the file or STDIN if exactly one input was provided
+func (t *Files) GetSingleFile() *File {
+	if len(t.Files) == 1 {
+		return t.Files[0]
+	}
+	return nil
+}
+
 func (t *Files) GetSTDIN() *File {
 	for _, file := range t.Files {
 		if file.STDIN {
@@ -167,3 +179,63 @@ func NewFiles(input string) (*Files, error) {
 
 	return nil, fmt.Errorf("data input is required. Use filename or pipe to STDIN")
 }
+
+// Unmarshal decodes the file as JSON or YAML into v. YAML is converted to JSON
+// first so that v only needs JSON tags.
+func (t *File) Unmarshal(v any) error {
+
+	var errors *multierror.Error
+
+	err := json.Unmarshal(t.Bytes, v)
+	if err == nil {
+		return nil
+	}
+
+	errors = multierror.Append(errors, err)
+
+	var raw any
+
+	err = yaml.Unmarshal(t.Bytes, &raw)
+	if err != nil {
+		errors = multierror.Append(errors, err)
+		errors = multierror.Append(errors, fmt.Errorf("invalid format. Expect JSON or YAML"))
+		return errors.ErrorOrNil()
+	}
+
+	data, err := json.Marshal(convertYAML(raw))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// convertYAML converts any map[interface{}]interface{} maps produced by the YAML
+// decoder into map[string]interface{} so the result can be encoded as JSON
+func convertYAML(v any) any {
+
+	switch v := v.(type) {
+
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertYAML(value)
+		}
+		return v
+
+	case map[interface{}]interface{}:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = convertYAML(value)
+		}
+		return m
+
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertYAML(value)
+		}
+		return v
+
+	}
+
+	return v
+}
@@ -0,0 +1,280 @@
+package watch
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+	"github.com/jodydadescott/shelly-go-cli/rpc"
+)
+
+const (
+	// Method of the events generated by the watcher
+	MethodConnected    = "connected"
+	MethodDisconnected = "disconnected"
+
+	keepalive    = 30 * time.Second
+	readTimeout  = 2 * keepalive
+	dialTimeout  = 10 * time.Second
+	keepaliveID  = math.MaxInt32
+	sourcePrefix = "shelly-cli-watch-"
+)
+
+// minBackoff and maxBackoff bound the reconnect delay; tests shorten them
+var (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Event is a device notification tagged with the device name. TS is the device
+// timestamp of the notification if it has one and the receive time otherwise.
+type Event struct {
+	TS     float64         `json:"ts"`
+	Device string          `json:"device"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type Config struct {
+	Devices []*inventory.Device
+	// Methods are the notification methods to emit such as NotifyEvent. All
+	// notifications are emitted if empty. Connection events are always emitted.
+	Methods []string
+	// Reorder is how long events are held after they are received so that
+	// events of other devices with an earlier device timestamp are emitted
+	// first. Ordering follows the device clocks; an event that arrives more
+	// than Reorder after an event with a later timestamp is emitted after it.
+	Reorder time.Duration
+}
+
+// Run watches the devices until ctx is done and calls emit with the events of
+// all devices ordered by timestamp within the Reorder window. A device that
+// drops off is reconnected with backoff; a connected and a disconnected event
+// is emitted for each connection.
+func Run(ctx context.Context, config *Config, emit func(*Event) error) error {
+
+	methods := make(map[string]bool)
+	for _, method := range config.Methods {
+		methods[method] = true
+	}
+
+	events := make(chan *Event, 64)
+
+	var wg sync.WaitGroup
+
+	for _, d := range config.Devices {
+		wg.Add(1)
+		go func(d *inventory.Device) {
+			defer wg.Done()
+			watchDevice(ctx, d, events)
+		}(d)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	buffer := &eventHeap{}
+	ticker := time.NewTicker(config.Reorder/2 + time.Millisecond)
+	defer ticker.Stop()
+
+	// flush emits the events received before the reorder window or all events
+	// if all is set
+	flush := func(all bool) error {
+		cutoff := float64(time.Now().Add(-config.Reorder).UnixMilli()) / 1000
+		for {
+			next := buffer.pop(cutoff, all)
+			if next == nil {
+				return nil
+			}
+			err := emit(next)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		select {
+
+		case event, ok := <-events:
+			if !ok {
+				return flush(true)
+			}
+			if len(methods) > 0 && !methods[event.Method] && event.Method != MethodConnected && event.Method != MethodDisconnected {
+				continue
+			}
+			heap.Push(buffer, &item{Event: event, received: now()})
+
+		case <-ticker.C:
+			err := flush(false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchDevice reads the notifications of the device until ctx is done,
+// reconnecting with backoff
+func watchDevice(ctx context.Context, d *inventory.Device, events chan<- *Event) {
+
+	send := func(event *Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	if d.Gen == 1 {
+		send(&Event{TS: now(), Device: d.Name, Method: MethodDisconnected, Error: "Gen1 devices do not support websocket notifications"})
+		return
+	}
+
+	client := d.Client()
+	src := fmt.Sprintf("%s%d-%s", sourcePrefix, os.Getpid(), d.Name)
+	backoff := minBackoff
+
+	for {
+
+		connected, err := readDevice(ctx, client, d.Name, src, send)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if connected {
+			backoff = minBackoff
+		}
+
+		send(&Event{TS: now(), Device: d.Name, Method: MethodDisconnected, Error: fmt.Sprintf("%s; reconnecting in %s", err, backoff)})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// readDevice connects to the device and sends its notifications until the
+// connection fails. It returns true if the connection was established.
+func readDevice(ctx context.Context, client *rpc.Client, name, src string, send func(*Event)) (bool, error) {
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := client.Notifications(dialCtx, src)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+
+	send(&Event{TS: now(), Device: name, Method: MethodConnected})
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// The connection is closed on cancel to unblock the read and a request is
+	// sent every keepalive so that a silent device is detected by the read
+	// deadline
+	go func() {
+		ticker := time.NewTicker(keepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				conn.WriteJSON(map[string]any{"id": keepaliveID, "src": src, "method": "Sys.GetStatus"})
+			}
+		}
+	}()
+
+	for {
+
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+
+		frame := &struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}{}
+
+		if json.Unmarshal(data, frame) != nil || frame.Method == "" {
+			// Responses to the keepalive requests
+			continue
+		}
+
+		ts := &struct {
+			TS float64 `json:"ts"`
+		}{}
+		json.Unmarshal(frame.Params, ts)
+
+		if ts.TS == 0 {
+			ts.TS = now()
+		}
+
+		send(&Event{TS: ts.TS, Device: name, Method: frame.Method, Params: frame.Params})
+	}
+}
+
+func now() float64 {
+	return float64(time.Now().UnixMilli()) / 1000
+}
+
+type item struct {
+	*Event
+	received float64
+}
+
+// eventHeap orders events by timestamp
+type eventHeap []*item
+
+func (t eventHeap) Len() int           { return len(t) }
+func (t eventHeap) Less(i, j int) bool { return t[i].TS < t[j].TS }
+func (t eventHeap) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func (t *eventHeap) Push(x any) {
+	*t = append(*t, x.(*item))
+}
+
+// pop removes and returns the event with the lowest timestamp if it was
+// received at or before cutoff, or regardless of cutoff if all is set. It
+// returns nil otherwise. An event received later holds back the events with a
+// higher timestamp even if they were received earlier.
+func (t *eventHeap) pop(cutoff float64, all bool) *Event {
+	if t.Len() == 0 || (!all && (*t)[0].received > cutoff) {
+		return nil
+	}
+	return heap.Pop(t).(*item).Event
+}
+
+func (t *eventHeap) Pop() any {
+	old := *t
+	n := len(old)
+	x := old[n-1]
+	*t = old[:n-1]
+	return x
+}
@@ -0,0 +1,147 @@
+package watch
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jodydadescott/shelly-go-cli/internal/testdevice"
+	"github.com/jodydadescott/shelly-go-cli/inventory"
+)
+
+func TestEventHeapPop(t *testing.T) {
+
+	buffer := &eventHeap{}
+
+	// Device b is ahead of device a; c arrives late with the oldest timestamp
+	for _, i := range []*item{
+		{Event: &Event{TS: 100.5, Device: "b"}, received: 10.0},
+		{Event: &Event{TS: 90.2, Device: "a"}, received: 10.1},
+		{Event: &Event{TS: 100.1, Device: "b"}, received: 10.2},
+		{Event: &Event{TS: 80.0, Device: "c"}, received: 10.4},
+	} {
+		heap.Push(buffer, i)
+	}
+
+	pop := func(cutoff float64, all bool) string {
+		var devices []string
+		for {
+			event := buffer.pop(cutoff, all)
+			if event == nil {
+				return strings.Join(devices, ",")
+			}
+			devices = append(devices, event.Device)
+		}
+	}
+
+	// c is the oldest by timestamp but not yet held long enough
+	if got := pop(10.3, false); got != "" {
+		t.Errorf("pop(10.3) = %s; want none", got)
+	}
+
+	if got := pop(10.4, false); got != "c,a,b,b" {
+		t.Errorf("pop(10.4) = %s; want c,a,b,b", got)
+	}
+
+	// The event of a that is still held back holds back b, which has a later
+	// timestamp, even though b was received before it
+	heap.Push(buffer, &item{Event: &Event{TS: 95.0, Device: "a"}, received: 10.5})
+	heap.Push(buffer, &item{Event: &Event{TS: 101.0, Device: "b"}, received: 10.5})
+	heap.Push(buffer, &item{Event: &Event{TS: 96.0, Device: "a"}, received: 10.6})
+
+	if got := pop(10.5, false); got != "a" {
+		t.Errorf("pop(10.5) = %s; want a", got)
+	}
+
+	if got := pop(0, true); got != "a,b" {
+		t.Errorf("pop(all) = %s; want a,b", got)
+	}
+}
+
+func TestRunReconnect(t *testing.T) {
+
+	savedMin, savedMax := minBackoff, maxBackoff
+	minBackoff, maxBackoff = 10*time.Millisecond, 40*time.Millisecond
+	t.Cleanup(func() { minBackoff, maxBackoff = savedMin, savedMax })
+
+	var connections atomic.Int32
+
+	// The first and fourth connections send a notification and drop, the
+	// second and third drop before the registration is answered and the fifth
+	// stays open
+	device := testdevice.New(t, &testdevice.Config{Websocket: func(path string, conn *websocket.Conn) {
+
+		n := connections.Add(1)
+
+		if n == 2 || n == 3 {
+			return
+		}
+
+		request := &struct {
+			ID int `json:"id"`
+		}{}
+
+		if conn.ReadJSON(request) != nil {
+			return
+		}
+
+		conn.WriteJSON(map[string]any{"id": request.ID, "result": map[string]any{}})
+
+		if n == 5 {
+			conn.ReadMessage()
+			return
+		}
+
+		conn.WriteJSON(map[string]any{"method": "NotifyEvent", "params": map[string]any{"events": []any{}}})
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var connected, notified int
+	var disconnected []string
+
+	config := &Config{Devices: []*inventory.Device{device.Inventory("kitchen")}, Methods: []string{"NotifyEvent"}}
+
+	err := Run(ctx, config, func(event *Event) error {
+		switch event.Method {
+		case MethodConnected:
+			connected++
+			if connected == 3 {
+				cancel()
+			}
+		case MethodDisconnected:
+			disconnected = append(disconnected, event.Error)
+		case "NotifyEvent":
+			notified++
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("timed out after %d connections", connections.Load())
+	}
+
+	// The backoff doubles while the device is unreachable and is reset by a
+	// connection
+	want := []string{"10ms", "20ms", "40ms", "10ms"}
+
+	if len(disconnected) != len(want) || notified != 2 {
+		t.Fatalf("disconnected %q and %d notifications; want %d and 2", disconnected, notified, len(want))
+	}
+
+	for i, backoff := range want {
+		if !strings.HasSuffix(disconnected[i], "reconnecting in "+backoff) {
+			t.Errorf("disconnected %d: %q; want reconnecting in %s", i, disconnected[i], backoff)
+		}
+	}
+}